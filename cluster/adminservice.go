@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"errors"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"time"
+)
+
+const ClusterAdminName = "ClusterAdmin"
+const SetVersionRouteMethod = ClusterAdminName + ".RPC_SetVersionRoute"
+const GetDrainStatusMethod = ClusterAdminName + ".RPC_GetDrainStatus"
+const InstallServiceMethod = ClusterAdminName + ".RPC_InstallService"
+const UninstallServiceMethod = ClusterAdminName + ".RPC_UninstallService"
+const GetClusterStatusMethod = ClusterAdminName + ".RPC_GetClusterStatus"
+const UpdateConfigMethod = ClusterAdminName + ".RPC_UpdateConfig"
+const SetTimeOffsetMethod = ClusterAdminName + ".RPC_SetTimeOffset"
+
+type ServiceOpFun func(serviceName string) error
+
+// InstallServiceFun 与UninstallServiceFun由node注册，用于运行期间安装与卸载服务
+var InstallServiceFun ServiceOpFun
+var UninstallServiceFun ServiceOpFun
+
+// ClusterAdminCfg 集群配置中ClusterAdmin的访问控制
+type ClusterAdminCfg struct {
+	Enable          bool //允许远程安装卸载服务、切换版本路由、下发配置与调整时间，默认不允许，只应在可信的内网集群中开启
+	AllowTimeOffset bool //允许GM调整时间，还需要开启Enable，只应在测试服中开启
+}
+
+// ClusterAdmin 每个结点内置的管理服务，通过CallNode指定结点调用
+type ClusterAdmin struct {
+	service.Service
+
+	cfg ClusterAdminCfg
+}
+
+var adminService ClusterAdmin
+
+func init() {
+	adminService.SetName(ClusterAdminName)
+}
+
+func (cls *Cluster) setupAdminService(setupServiceFun SetupServiceFun) error {
+	fileNodeInfoList, err := cls.ReadClusterConfig()
+	if err != nil {
+		return err
+	}
+
+	adminService.cfg = fileNodeInfoList.ClusterAdmin
+	setupServiceFun(&adminService)
+	cls.AddDiscoveryService(ClusterAdminName, false)
+	return nil
+}
+
+// checkEnable 管理操作需要在集群配置中开启ClusterAdmin.Enable
+func (ca *ClusterAdmin) checkEnable(method string) error {
+	if ca.cfg.Enable == false {
+		log.Warnf("cluster admin is disabled,reject %s", method)
+		return errors.New("cluster admin is disabled")
+	}
+
+	return nil
+}
+
+type VersionRouteReq struct {
+	ServiceName string
+	Route       *VersionRoute //为nil时清除路由
+	Cast        bool          //为true时由收到请求的结点转发给所有结点的ClusterAdmin
+}
+
+// RPC_SetVersionRoute 切换本结点调用某服务的版本路由，如逐步调整灰度流量百分比，Cast为true时切换所有结点
+func (ca *ClusterAdmin) RPC_SetVersionRoute(req *VersionRouteReq) error {
+	if err := ca.checkEnable(SetVersionRouteMethod); err != nil {
+		return err
+	}
+
+	if req.ServiceName == "" {
+		return errors.New("service name is empty")
+	}
+
+	err := cluster.SetVersionRoute(req.ServiceName, req.Route)
+	if err != nil || req.Cast == false {
+		return err
+	}
+
+	return ca.CastGo(SetVersionRouteMethod, &VersionRouteReq{ServiceName: req.ServiceName, Route: req.Route})
+}
+
+type DrainStatusReq struct {
+}
+
+// RPC_GetDrainStatus 获取本结点退休排空进度，InboundRpcNum中包含本次调用
+func (ca *ClusterAdmin) RPC_GetDrainStatus(_ *DrainStatusReq, status *DrainStatus) error {
+	*status = cluster.GetDrainStatus()
+	return nil
+}
+
+type ServiceReq struct {
+	ServiceName string //格式与NodeList中ServiceList一致，如"BattleService2:BattleService"
+}
+
+// RPC_InstallService 在本结点安装并启动服务
+func (ca *ClusterAdmin) RPC_InstallService(req *ServiceReq) error {
+	if err := ca.checkEnable(InstallServiceMethod); err != nil {
+		return err
+	}
+
+	if InstallServiceFun == nil {
+		return errors.New("install service is not supported")
+	}
+
+	return InstallServiceFun(req.ServiceName)
+}
+
+// RPC_UninstallService 停止并卸载本结点的服务
+func (ca *ClusterAdmin) RPC_UninstallService(req *ServiceReq) error {
+	if err := ca.checkEnable(UninstallServiceMethod); err != nil {
+		return err
+	}
+
+	if UninstallServiceFun == nil {
+		return errors.New("uninstall service is not supported")
+	}
+
+	return UninstallServiceFun(req.ServiceName)
+}
+
+type ClusterStatusReq struct {
+}
+
+// RPC_GetClusterStatus 获取该结点视角下的集群快照
+func (ca *ClusterAdmin) RPC_GetClusterStatus(_ *ClusterStatusReq, status *ClusterStatus) error {
+	*status = cluster.GetClusterStatus()
+	return nil
+}
+
+// RPC_UpdateConfig 下发配置到本结点，由配置中心Master推送，也可以用于手动修改单个结点的配置。
+// 配置中心为Origin时允许Master推送，否则需要开启ClusterAdmin.Enable
+func (ca *ClusterAdmin) RPC_UpdateConfig(req *ConfigData) error {
+	if cluster.isOriginConfigCenter() == false {
+		if err := ca.checkEnable(UpdateConfigMethod); err != nil {
+			return err
+		}
+	}
+
+	return cluster.UpdateConfig(req)
+}
+
+type TimeOffsetReq struct {
+	Offset time.Duration //相对系统时间的偏移，为0时恢复系统时间
+}
+
+// RPC_SetTimeOffset GM调整本结点的时间，只用于测试服，向后调整时已到期的定时器与cron会立即触发
+func (ca *ClusterAdmin) RPC_SetTimeOffset(req *TimeOffsetReq) error {
+	if err := ca.checkEnable(SetTimeOffsetMethod); err != nil {
+		return err
+	}
+
+	if ca.cfg.AllowTimeOffset == false {
+		log.Warnf("time offset is not allowed,reject %s", SetTimeOffsetMethod)
+		return errors.New("time offset is not allowed")
+	}
+
+	log.Warnf("set time offset %s", req.Offset)
+	return timer.SetTimeOffset(req.Offset)
+}
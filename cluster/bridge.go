@@ -0,0 +1,242 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/service"
+	jsoniter "github.com/json-iterator/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+// BridgeService 桥接结点将FromNetworkName网络中的服务导出到其他网络，其他网络的调用经桥接结点转发
+type BridgeService struct {
+	ServiceName     string
+	FromNetworkName string   //服务所在的网络
+	ToNetworkName   []string //导出到的网络，不配置时导出到除FromNetworkName外的所有网络
+	MethodList      []string //允许调用的方法，如"RPC_GetRank"，配置"*"时允许所有方法
+}
+
+// bridgeService 与被导出的服务同名，接收其他网络的调用并转发到FromNetworkName网络中的结点
+type bridgeService struct {
+	service.Service
+	cfg       *BridgeService
+	mapMethod map[string]struct{}
+}
+
+// isExportTo 服务是否导出到该网络
+func (bs *BridgeService) isExportTo(networkName string) bool {
+	if networkName == bs.FromNetworkName {
+		return false
+	}
+
+	return len(bs.ToNetworkName) == 0 || slices.Contains(bs.ToNetworkName, networkName)
+}
+
+func (cls *Cluster) getNetworkNameList() []string {
+	var networkNameList []string
+	switch cls.discoveryInfo.getDiscoveryType() {
+	case EtcdType:
+		for _, etcdList := range cls.discoveryInfo.Etcd.EtcdList {
+			networkNameList = append(networkNameList, etcdList.NetworkName...)
+		}
+	case RedisType:
+		networkNameList = cls.discoveryInfo.Redis.NetworkName
+	case GossipType:
+		networkNameList = cls.discoveryInfo.Gossip.NetworkName
+	}
+
+	return networkNameList
+}
+
+func (cls *Cluster) checkBridgeService() error {
+	bridgeList := cls.localNodeInfo.BridgeServiceList
+	if len(bridgeList) == 0 {
+		return nil
+	}
+
+	networkNameList := cls.getNetworkNameList()
+	if len(networkNameList) == 0 {
+		return errors.New("bridge service is only supported by etcd, redis or gossip discovery")
+	}
+
+	for i, bridge := range bridgeList {
+		if bridge.ServiceName == "" || len(bridge.MethodList) == 0 {
+			return fmt.Errorf("bridge service %d ServiceName or MethodList is empty", i)
+		}
+
+		if slices.Contains(networkNameList, bridge.FromNetworkName) == false {
+			return fmt.Errorf("bridge service %s FromNetworkName %s is not configured in discovery", bridge.ServiceName, bridge.FromNetworkName)
+		}
+
+		for _, networkName := range bridge.ToNetworkName {
+			if networkName == bridge.FromNetworkName || slices.Contains(networkNameList, networkName) == false {
+				return fmt.Errorf("bridge service %s ToNetworkName %s is invalid", bridge.ServiceName, networkName)
+			}
+		}
+
+		if slices.ContainsFunc(cls.localNodeInfo.ServiceList, func(s string) bool {
+			name, _, _ := strings.Cut(strings.TrimLeft(s, "_"), ":")
+			return name == bridge.ServiceName
+		}) {
+			return fmt.Errorf("bridge service %s cannot be configured in ServiceList", bridge.ServiceName)
+		}
+
+		for j := 0; j < i; j++ {
+			if bridgeList[j].ServiceName == bridge.ServiceName {
+				return fmt.Errorf("bridge service %s is repeat", bridge.ServiceName)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (cls *Cluster) setupBridgeService() error {
+	if err := cls.checkBridgeService(); err != nil {
+		return err
+	}
+
+	cls.mapBridgeService = make(map[string]*bridgeService, len(cls.localNodeInfo.BridgeServiceList))
+	for i := range cls.localNodeInfo.BridgeServiceList {
+		cfg := &cls.localNodeInfo.BridgeServiceList[i]
+		bs := &bridgeService{cfg: cfg, mapMethod: make(map[string]struct{}, len(cfg.MethodList))}
+		for _, method := range cfg.MethodList {
+			bs.mapMethod[method] = struct{}{}
+		}
+
+		bs.SetName(cfg.ServiceName)
+		bs.OnSetup(bs)
+		cls.mapBridgeService[cfg.ServiceName] = bs
+		cls.AddDiscoveryService(cfg.ServiceName, true)
+	}
+
+	return nil
+}
+
+// GetBridgeService 获取本结点配置的桥接服务，由node安装
+func (cls *Cluster) GetBridgeService(serviceName string) service.IService {
+	bs, ok := cls.mapBridgeService[serviceName]
+	if ok == false {
+		return nil
+	}
+
+	return bs
+}
+
+// GetNetworkPublicServiceList 获取发布到某个网络中的服务列表，桥接的服务只发布到导出的网络
+func (cls *Cluster) GetNetworkPublicServiceList(networkName string) []string {
+	publicServiceList := cls.GetPublicServiceList()
+	if len(cls.mapBridgeService) == 0 {
+		return publicServiceList
+	}
+
+	return slices.DeleteFunc(slices.Clone(publicServiceList), func(serviceName string) bool {
+		bs, ok := cls.mapBridgeService[serviceName]
+		return ok == true && bs.cfg.isExportTo(networkName) == false
+	})
+}
+
+// selectNodeId 在FromNetworkName网络中随机选择提供该服务的结点
+func (bs *bridgeService) selectNodeId() string {
+	cluster.locker.RLock()
+	defer cluster.locker.RUnlock()
+
+	serviceName := bs.cfg.ServiceName
+	nodeIdList := make([]string, 0, len(cluster.mapServiceNode[serviceName]))
+	for nodeId := range cluster.mapServiceNode[serviceName] {
+		nodeRpc, ok := cluster.mapRpc[nodeId]
+		if ok == false || nodeId == cluster.localNodeInfo.NodeId || nodeRpc.client == nil || nodeRpc.client.IsConnected() == false {
+			continue
+		}
+
+		nodeInfo := &nodeRpc.nodeInfo
+		if nodeInfo.NetworkName != bs.cfg.FromNetworkName || nodeInfo.Retire == true ||
+			nodeInfo.isServiceHealthy(serviceName) == false || nodeInfo.isSingletonStandby(serviceName) == true {
+			continue
+		}
+
+		nodeIdList = append(nodeIdList, nodeId)
+	}
+
+	if len(nodeIdList) == 0 {
+		return ""
+	}
+
+	return nodeIdList[rand.Intn(len(nodeIdList))]
+}
+
+// ForwardRpc 校验允许调用的方法后，以原始数据转发并在返回后回复调用方，参数与返回值都不解析
+func (bs *bridgeService) ForwardRpc(processor rpc.IRpcProcessor, serviceMethod string, rawArgs rpc.RawData, responder rpc.RequestHandler) {
+	reply := func(returns interface{}, err error) {
+		if responder != nil {
+			responder(returns, rpc.ConvertError(err))
+		}
+	}
+
+	_, method, _ := strings.Cut(serviceMethod, ".")
+	if _, ok := bs.mapMethod[method]; ok == false {
+		if _, ok = bs.mapMethod["*"]; ok == false {
+			log.Warnf("bridge method is not allowed,serviceMethod:%s", serviceMethod)
+			reply(nil, fmt.Errorf("bridge method %s is not allowed", serviceMethod))
+			return
+		}
+	}
+
+	nodeId := bs.selectNodeId()
+	if nodeId == "" {
+		reply(nil, fmt.Errorf("cannot find service %s in network %s", bs.cfg.ServiceName, bs.cfg.FromNetworkName))
+		return
+	}
+
+	args, err := newRawMessage(processor, rawArgs)
+	if err != nil {
+		reply(nil, err)
+		return
+	}
+
+	if responder == nil {
+		if err = bs.GoNode(nodeId, serviceMethod, args); err != nil {
+			log.Errorf("bridge forward fail,serviceMethod:%s,nodeId:%s,err:%s", serviceMethod, nodeId, err.Error())
+		}
+		return
+	}
+
+	//返回后在桥接服务的协程中回复调用方
+	if processor.GetProcessorType() == rpc.RpcProcessorPB {
+		err = bs.AsyncCallNode(nodeId, serviceMethod, args, func(rawReply *emptypb.Empty, err error) {
+			reply(rawReply, err)
+		})
+	} else {
+		err = bs.AsyncCallNode(nodeId, serviceMethod, args, func(rawReply *jsoniter.RawMessage, err error) {
+			reply(rawReply, err)
+		})
+	}
+
+	if err != nil {
+		reply(nil, err)
+	}
+}
+
+// newRawMessage 以原始数据构造参数，不需要知道具体类型即可原样序列化。
+// pb的字段都作为未知字段保留，序列化时原样写回；json直接使用原始数据
+func newRawMessage(processor rpc.IRpcProcessor, rawData rpc.RawData) (interface{}, error) {
+	if processor.GetProcessorType() == rpc.RpcProcessorPB {
+		msg := &emptypb.Empty{}
+		if err := proto.Unmarshal(rawData, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	if len(rawData) == 0 {
+		return jsoniter.RawMessage("null"), nil
+	}
+
+	return jsoniter.RawMessage(rawData), nil
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/duanhf2012/origin/v2/rpc"
 	"github.com/duanhf2012/origin/v2/service"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 var configDir = "./config/"
@@ -24,9 +26,10 @@ const (
 )
 
 type DiscoveryService struct {
-	MasterNodeId string   //要筛选的主结点Id，如果不配置或者配置成0，表示针对所有的主结点
-	NetworkName  string   //如果是etcd，指定要筛选的网络名中的服务，不配置，表示所有的网络
-	ServiceList  []string //只发现的服务列表
+	MasterNodeId string            //要筛选的主结点Id，如果不配置或者配置成0，表示针对所有的主结点
+	NetworkName  string            //如果是etcd，指定要筛选的网络名中的服务，不配置，表示所有的网络
+	ServiceList  []string          //只发现的服务列表
+	Labels       map[string]string //只发现标签匹配的结点，值为"*"时只要求存在该标签
 }
 
 type NodeInfo struct {
@@ -40,13 +43,25 @@ type NodeInfo struct {
 	DiscoveryService  []DiscoveryService //筛选发现的服务，如果不配置，不进行筛选
 	status            NodeStatus
 	Retire            bool
+	Labels            map[string]string       //结点标签，如区域、机型、版本等
+	ServiceVersion    map[string]string       //map[serviceName]版本号，随服务发现同步
+	VersionRoute      map[string]VersionRoute //map[serviceName]本结点调用该服务的版本路由
+
+	SingletonServiceList []string        //以单例模式运行的服务，多个结点中只有一个Leader处于工作状态
+	BridgeServiceList    []BridgeService //桥接结点从其他网络导出的服务
+	RetireDrainSecond    int             //收到退休信号后排空的最长时间(秒)，空闲后提前停止，0表示不排空
+
+	UnhealthyServiceList []string         `mapstructure:"-"` //健康检查失败的服务，随服务发现同步
+	ServiceLeader        map[string]int64 `mapstructure:"-"` //map[serviceName]成为Leader的时间(毫秒)，随服务发现同步
 
 	NetworkName string
 }
 
 type NodeRpcInfo struct {
-	nodeInfo NodeInfo
-	client   *rpc.Client
+	nodeInfo          NodeInfo
+	client            *rpc.Client
+	lastUpdateTime    time.Time //最后一次收到服务发现同步该结点信息的时间，结点信息没有变化时不更新
+	lastHeartbeatTime time.Time //服务发现最后一次观察到该结点存活的时间，见refreshNodeHeartbeat
 }
 
 var cluster Cluster
@@ -59,12 +74,15 @@ type Cluster struct {
 	globalCfg     interface{} //全局配置
 
 	localServiceCfg  map[string]interface{} //map[serviceName]配置数据*
+	cfgLocker        sync.RWMutex           //配置中心更新配置时保护globalCfg与localServiceCfg
 	serviceDiscovery IServiceDiscovery      //服务发现接口
 
 	locker                 sync.RWMutex                   //结点与服务关系保护锁
 	mapRpc                 map[string]*NodeRpcInfo        //nodeId
 	mapServiceNode         map[string]map[string]struct{} //map[serviceName]map[NodeId]
 	mapTemplateServiceNode map[string]map[string]struct{} //map[templateServiceName]map[serviceName]nodeId
+	mapVersionRoute        map[string]*VersionRoute       //map[serviceName]版本路由
+	cfgVersionRoute        map[string]VersionRoute        //最近一次应用的配置中的版本路由
 
 	callSet   rpc.CallSet
 	rpcNats   rpc.RpcNats
@@ -72,6 +90,19 @@ type Cluster struct {
 
 	rpcEventLocker           sync.RWMutex        //Rpc事件监听保护锁
 	mapServiceListenRpcEvent map[string]struct{} //ServiceName
+
+	mapBridgeService map[string]*bridgeService //map[serviceName]本结点的桥接服务
+
+	configCenter        ConfigCenter
+	etcdConfigCenter    etcdConfigCenter
+	configVersion       int64                  //当前已应用的配置中心版本
+	mapCenterServiceCfg map[string]interface{} //map[serviceName]配置中心下发的本结点服务配置
+
+	drainLocker    sync.RWMutex
+	drainStartTime time.Time //开始排空的时间，为零表示未在排空
+	drainDeadline  time.Time //排空截止时间
+
+	localHeartbeatTime int64 //本结点最后一次向服务发现续约成功的时间(UnixNano)
 }
 
 func GetCluster() *Cluster {
@@ -87,11 +118,18 @@ func SetServiceDiscovery(serviceDiscovery IServiceDiscovery) {
 }
 
 func (cls *Cluster) Start() error {
-	return cls.rpcServer.Start()
+	err := cls.rpcServer.Start()
+	if err != nil {
+		return err
+	}
+
+	cls.startSingletonElection()
+	return nil
 }
 
 func (cls *Cluster) Stop() {
 	cls.rpcServer.Stop()
+	cls.stopConfigCenter()
 }
 
 func (cls *Cluster) DiscardNode(nodeId string) {
@@ -173,8 +211,17 @@ func (cls *Cluster) serviceDiscoverySetNodeInfo(nodeInfo *NodeInfo) {
 	//先清一次的NodeId对应的所有服务清理
 	lastNodeInfo, ok := cls.mapRpc[nodeInfo.NodeId]
 	if ok == true {
+		var removeServiceList []string
 		for _, serviceName := range lastNodeInfo.nodeInfo.ServiceList {
 			cls.delServiceNode(serviceName, nodeInfo.NodeId)
+			if slices.Contains(nodeInfo.PublicServiceList, serviceName) == false {
+				removeServiceList = append(removeServiceList, serviceName)
+			}
+		}
+
+		//结点运行期间卸载的服务
+		if len(removeServiceList) > 0 {
+			cls.TriggerDiscoveryEvent(false, nodeInfo.NodeId, removeServiceList)
 		}
 	}
 
@@ -209,13 +256,17 @@ func (cls *Cluster) serviceDiscoverySetNodeInfo(nodeInfo *NodeInfo) {
 
 	if lastNodeInfo != nil {
 		log.Debugf("Discovery nodeId,NodeId:%s,services:%s,Retire:%t", nodeInfo.NodeId, nodeInfo.PublicServiceList, nodeInfo.Retire)
+		cls.triggerHealthChange(nodeInfo.NodeId, lastNodeInfo.nodeInfo.UnhealthyServiceList, nodeInfo.UnhealthyServiceList)
 		lastNodeInfo.nodeInfo = *nodeInfo
+		lastNodeInfo.lastUpdateTime = time.Now()
 		return
 	}
+	cls.triggerHealthChange(nodeInfo.NodeId, nil, nodeInfo.UnhealthyServiceList)
 
 	//不存在时，则建立连接
 	rpcInfo := NodeRpcInfo{}
 	rpcInfo.nodeInfo = *nodeInfo
+	rpcInfo.lastUpdateTime = time.Now()
 
 	if cls.IsNatsMode() {
 		rpcInfo.client = cls.rpcNats.NewNatsClient(nodeInfo.NodeId, cls.GetLocalNodeInfo().NodeId, &cls.callSet, cls.NotifyAllService)
@@ -237,6 +288,13 @@ func (cls *Cluster) Init(localNodeId string, setupServiceFun SetupServiceFun) er
 		return err
 	}
 
+	//从配置中心加载配置，服务Init时使用
+	err = cls.initConfigCenter()
+	if err != nil {
+		log.Errorf("initConfigCenter fail:%s", err)
+		return err
+	}
+
 	cls.callSet.Init()
 	if cls.IsNatsMode() {
 		cls.rpcNats.Init(cls.rpcMode.Nats.NatsUrl, cls.rpcMode.Nats.NoRandomize, cls.GetLocalNodeInfo().NodeId, cls.localNodeInfo.CompressBytesLen, cls, cluster.NotifyAllService)
@@ -253,8 +311,33 @@ func (cls *Cluster) Init(localNodeId string, setupServiceFun SetupServiceFun) er
 		log.Errorf("setupDiscovery fail:%s", err)
 		return err
 	}
+
+	err = cls.checkSingletonElection()
+	if err != nil {
+		log.Errorf("checkSingletonElection fail:%s", err)
+		return err
+	}
+
+	//3.安装结点管理服务
+	err = cls.setupAdminService(setupServiceFun)
+	if err != nil {
+		log.Errorf("setupAdminService fail:%s", err)
+		return err
+	}
+
+	//4.安装跨网络桥接服务
+	err = cls.setupBridgeService()
+	if err != nil {
+		log.Errorf("setupBridgeService fail:%s", err)
+		return err
+	}
+
 	service.RegRpcEventFun = cls.RegRpcEvent
 	service.UnRegRpcEventFun = cls.UnRegRpcEvent
+	rpc.SelectRpcClientFun = GetRpcClientBySelector
+	rpc.RouteRpcClientFun = RouteRpcClient
+	service.ServiceHealthFun = cls.setServiceHealth
+	service.IsServiceDiscoveredFun = cls.IsServiceDiscovered
 
 	err = cls.serviceDiscovery.InitDiscovery(localNodeId, cls.serviceDiscoveryDelNode, cls.serviceDiscoverySetNodeInfo)
 	if err != nil {
@@ -317,6 +400,31 @@ func GetRpcClient(nodeId string, serviceMethod string, filterRetire bool, client
 	return GetCluster().GetNodeIdByService(serviceName, clientList, filterRetire)
 }
 
+// GetRpcClientBySelector 按服务名查找结点，并用标签选择器筛选
+func GetRpcClientBySelector(serviceMethod string, selector rpc.LabelSelector, filterRetire bool, clientList []*rpc.Client) (error, []*rpc.Client) {
+	findIndex := strings.Index(serviceMethod, ".")
+	if findIndex == -1 {
+		return fmt.Errorf("servicemethod param  %s is error!", serviceMethod), nil
+	}
+	serviceName := serviceMethod[:findIndex]
+
+	return GetCluster().GetNodeIdByServiceSelector(serviceName, selector, clientList, filterRetire)
+}
+
+// PreferLocalLabels 返回优先选择与本结点标签值相同的结点的选择器，如PreferLocalLabels("zone")优先调用同区域的结点，
+// 本结点没有配置的标签被忽略
+func PreferLocalLabels(keys ...string) rpc.LabelSelector {
+	selector := rpc.LabelSelector{}
+	localLabels := cluster.GetLocalNodeInfo().Labels
+	for _, key := range keys {
+		if value, ok := localLabels[key]; ok {
+			selector.Prefer(key, value)
+		}
+	}
+
+	return selector
+}
+
 func GetRpcServer() rpc.IServer {
 	return cluster.rpcServer
 }
@@ -334,6 +442,20 @@ func (cls *Cluster) IsNodeRetire(nodeId string) bool {
 	return retire
 }
 
+// IsServiceDiscovered 服务是否已在未退休的结点上被发现
+func (cls *Cluster) IsServiceDiscovered(serviceName string) bool {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	for nodeId := range cls.mapServiceNode[serviceName] {
+		if _, retire := cls.getRpcClient(nodeId); retire == false {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (cls *Cluster) NotifyAllService(event event.IEvent) {
 	cls.rpcEventLocker.Lock()
 	defer cls.rpcEventLocker.Unlock()
@@ -430,20 +552,24 @@ func GetNodeByTemplateServiceName(templateServiceName string) map[string]string
 }
 
 func (cls *Cluster) GetGlobalCfg() interface{} {
+	cls.cfgLocker.RLock()
+	defer cls.cfgLocker.RUnlock()
+
 	return cls.globalCfg
 }
 
 func (cls *Cluster) ParseGlobalCfg(cfg interface{}) error {
-	if cls.globalCfg == nil {
+	globalCfg := cls.GetGlobalCfg()
+	if globalCfg == nil {
 		return errors.New("no service configuration found")
 	}
 
-	rv := reflect.ValueOf(cls.globalCfg)
+	rv := reflect.ValueOf(globalCfg)
 	if rv.Kind() == reflect.Ptr && rv.IsNil() {
 		return errors.New("no service configuration found")
 	}
 
-	bytes, err := json.Marshal(cls.globalCfg)
+	bytes, err := json.Marshal(globalCfg)
 	if err != nil {
 		return err
 	}
@@ -463,7 +589,7 @@ func (cls *Cluster) GetNodeInfo(nodeId string) (NodeInfo, bool) {
 	return nodeInfo.nodeInfo, true
 }
 
-func (cls *Cluster) CanDiscoveryService(fromMasterNodeId string, serviceName string) bool {
+func (cls *Cluster) CanDiscoveryService(fromMasterNodeId string, serviceName string, labels map[string]string) bool {
 	canDiscovery := true
 
 	splitServiceName := strings.Split(serviceName, ":")
@@ -473,13 +599,19 @@ func (cls *Cluster) CanDiscoveryService(fromMasterNodeId string, serviceName str
 
 	for i := 0; i < len(cls.GetLocalNodeInfo().DiscoveryService); i++ {
 		masterNodeId := cls.GetLocalNodeInfo().DiscoveryService[i].MasterNodeId
+		selector := rpc.LabelSelector(cls.GetLocalNodeInfo().DiscoveryService[i].Labels)
 		//无效的配置，则跳过
-		if masterNodeId == rpc.NodeIdNull && len(cls.GetLocalNodeInfo().DiscoveryService[i].ServiceList) == 0 {
+		if masterNodeId == rpc.NodeIdNull && len(cls.GetLocalNodeInfo().DiscoveryService[i].ServiceList) == 0 && len(selector) == 0 {
 			continue
 		}
 
 		canDiscovery = false
-		if masterNodeId == fromMasterNodeId || masterNodeId == rpc.NodeIdNull {
+		if (masterNodeId == fromMasterNodeId || masterNodeId == rpc.NodeIdNull) && selector.Match(labels) {
+			//只配置标签时，发现匹配结点的所有服务
+			if len(selector) > 0 && len(cls.GetLocalNodeInfo().DiscoveryService[i].ServiceList) == 0 {
+				return true
+			}
+
 			for _, discoveryService := range cls.GetLocalNodeInfo().DiscoveryService[i].ServiceList {
 				if discoveryService == serviceName {
 					return true
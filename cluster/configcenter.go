@@ -0,0 +1,349 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/config"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/go-viper/mapstructure/v2"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/client/v3"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	ConfigCenterEtcd   = "Etcd"
+	ConfigCenterOrigin = "Origin"
+)
+
+const defaultConfigCenterKey = originDir + "/config"
+const configCenterRetryInterval = 3 * time.Second
+
+// ConfigCenter 配置中心，运行期间向结点下发Global、Service与NodeService配置
+type ConfigCenter struct {
+	Typ  string `json:"Type" mapstructure:"Type"` //Etcd:从etcd读取并监听，Origin:由origin服务发现的Master监听配置文件并推送，为空不启用
+	Etcd *EtcdConfigCenter
+}
+
+type EtcdConfigCenter struct {
+	Endpoints              []string
+	UserName               string
+	Password               string
+	Cert                   string
+	CertKey                string
+	Ca                     string
+	DialTimeoutMillisecond time.Duration
+	Key                    string //配置存放的key，默认为/origin/config
+}
+
+// ConfigData 配置中心下发的配置，结构与配置文件中的Global、Service、NodeService、VersionRoute一致
+// 配置中心中不存在的项使用本地配置文件中的配置
+type ConfigData struct {
+	Version      int64 //版本号，非0时结点只接受比当前更新的版本
+	Global       interface{}
+	Service      map[string]interface{}
+	NodeService  []interface{}
+	VersionRoute map[string]VersionRoute //map[serviceName]所有结点调用该服务的版本路由，不配置时使用NodeList中本结点的VersionRoute
+}
+
+type etcdConfigCenter struct {
+	cfg    *EtcdConfigCenter
+	client *clientv3.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (cls *Cluster) initConfigCenter() error {
+	fileNodeInfoList, err := cls.ReadClusterConfig()
+	if err != nil {
+		return err
+	}
+
+	cls.configCenter = fileNodeInfoList.ConfigCenter
+	switch cls.configCenter.Typ {
+	case "":
+		return nil
+	case ConfigCenterOrigin:
+		if cls.discoveryInfo.getDiscoveryType() != OriginType {
+			return errors.New("config center Origin must be used with origin discovery")
+		}
+		return nil
+	case ConfigCenterEtcd:
+		etcdCfg := cls.configCenter.Etcd
+		if etcdCfg == nil || len(etcdCfg.Endpoints) == 0 {
+			return errors.New("config center Etcd.Endpoints is empty")
+		}
+		if etcdCfg.Key == "" {
+			etcdCfg.Key = defaultConfigCenterKey
+		}
+		etcdCfg.DialTimeoutMillisecond = etcdCfg.DialTimeoutMillisecond * time.Millisecond
+
+		return cls.etcdConfigCenter.init(etcdCfg)
+	}
+
+	return fmt.Errorf("config center type %s is not support", cls.configCenter.Typ)
+}
+
+func (cls *Cluster) stopConfigCenter() {
+	if cls.etcdConfigCenter.cancel != nil {
+		cls.etcdConfigCenter.cancel()
+		cls.etcdConfigCenter.client.Close()
+	}
+}
+
+func (cls *Cluster) isOriginConfigCenter() bool {
+	return cls.configCenter.Typ == ConfigCenterOrigin
+}
+
+// newConfigData 从配置中取出Global、Service、NodeService与VersionRoute
+func newConfigData(c map[string]interface{}, version int64) (*ConfigData, error) {
+	globalCfg, serviceConfig, _, err := parseServiceConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &ConfigData{Version: version, Global: globalCfg, Service: serviceConfig}
+	data.NodeService, _ = c["NodeService"].([]interface{})
+	if err = mapstructure.Decode(c["VersionRoute"], &data.VersionRoute); err != nil {
+		return nil, fmt.Errorf("VersionRoute config is error:%s", err)
+	}
+
+	return data, nil
+}
+
+// getServiceCfg 合并出本结点各服务的配置，NodeService中本结点的配置优先
+func (data *ConfigData) getServiceCfg(localNodeId string) (interface{}, map[string]interface{}, error) {
+	globalCfg, serviceConfig, mapNodeService, err := parseServiceConfig(map[string]interface{}{
+		"Global":      data.Global,
+		"Service":     data.Service,
+		"NodeService": data.NodeService,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapServiceCfg := make(map[string]interface{}, len(serviceConfig))
+	for serviceName, serviceCfg := range serviceConfig {
+		mapServiceCfg[serviceName] = serviceCfg
+	}
+	for serviceName, serviceCfg := range mapNodeService[localNodeId] {
+		if serviceName != "NodeId" {
+			mapServiceCfg[serviceName] = serviceCfg
+		}
+	}
+
+	return globalCfg, mapServiceCfg, nil
+}
+
+// readFileServiceCfg 读取本地配置文件中的服务配置，优先使用NodeService中本结点的配置
+func (cls *Cluster) readFileServiceCfg(serviceName string) interface{} {
+	_, serviceConfig, mapNodeService, _ := cls.readServiceConfig()
+	if nodeServiceCfg, ok := mapNodeService[cls.localNodeInfo.NodeId]; ok == true {
+		if nodeCfg, ok := nodeServiceCfg[serviceName]; ok == true {
+			return nodeCfg
+		}
+	}
+
+	return serviceConfig[serviceName]
+}
+
+// getLocalServiceNameList 获取本结点的服务名，去掉模板服务名
+func (cls *Cluster) getLocalServiceNameList() []string {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	serviceNameList := make([]string, 0, len(cls.localNodeInfo.ServiceList))
+	for _, serviceName := range cls.localNodeInfo.ServiceList {
+		name, _, _ := strings.Cut(serviceName, ":")
+		serviceNameList = append(serviceNameList, name)
+	}
+
+	return serviceNameList
+}
+
+// UpdateConfig 应用配置中心下发的配置，配置有变化的服务在各自协程中回调OnConfigChanged，
+// Global配置有变化时所有服务回调OnGlobalConfigChanged
+func (cls *Cluster) UpdateConfig(data *ConfigData) error {
+	globalCfg, mapServiceCfg, err := data.getServiceCfg(cls.localNodeInfo.NodeId)
+	if err != nil {
+		return err
+	}
+	if data.Global == nil {
+		globalCfg, _, _, _ = cls.readServiceConfig()
+	}
+
+	cfgVersionRoute := data.VersionRoute
+	if cfgVersionRoute == nil {
+		cfgVersionRoute = cls.localNodeInfo.VersionRoute
+	}
+	mapVersionRoute, err := newVersionRouteMap(cfgVersionRoute)
+	if err != nil {
+		return err
+	}
+
+	var changedServiceList []string
+	serviceNameList := cls.getLocalServiceNameList()
+
+	cls.cfgLocker.Lock()
+	if data.Version != 0 && data.Version <= cls.configVersion {
+		cls.cfgLocker.Unlock()
+		log.Debugf("ignore expired config,version:%d,current version:%d", data.Version, cls.configVersion)
+		return nil
+	}
+	if data.Version != 0 {
+		cls.configVersion = data.Version
+	}
+	cls.mapCenterServiceCfg = mapServiceCfg
+
+	for _, serviceName := range serviceNameList {
+		serviceCfg, ok := mapServiceCfg[serviceName]
+		if ok == false {
+			serviceCfg = cls.readFileServiceCfg(serviceName)
+		}
+
+		if reflect.DeepEqual(cls.localServiceCfg[serviceName], serviceCfg) {
+			continue
+		}
+
+		cls.localServiceCfg[serviceName] = serviceCfg
+		changedServiceList = append(changedServiceList, serviceName)
+	}
+
+	oldGlobalCfg := cls.globalCfg
+	globalChanged := reflect.DeepEqual(oldGlobalCfg, globalCfg) == false
+	cls.globalCfg = globalCfg
+	cls.cfgLocker.Unlock()
+
+	cls.updateVersionRoute(cfgVersionRoute, mapVersionRoute)
+
+	//通知服务，尚未安装的服务在Init时直接使用新配置
+	for _, serviceName := range changedServiceList {
+		log.Infof("service config is changed by config center,serviceName:%s,version:%d", serviceName, data.Version)
+		if s := service.GetService(serviceName); s != nil {
+			s.UpdateServiceCfg(cls.GetServiceCfg(serviceName))
+		}
+	}
+
+	if globalChanged {
+		log.Infof("global config is changed by config center,version:%d", data.Version)
+		for _, s := range service.GetServiceList() {
+			s.NotifyGlobalCfgChanged(oldGlobalCfg, globalCfg)
+		}
+	}
+
+	return nil
+}
+
+// reloadConfigData 重新读取本地配置文件，生成用于下发的配置
+func reloadConfigData() (*ConfigData, error) {
+	value, err := config.ClusterReload()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConfigData(value, time.Now().UnixNano())
+}
+
+func (ec *etcdConfigCenter) init(cfg *EtcdConfigCenter) error {
+	tlsConfig, err := loadEtcdTLSConfig(cfg.Cert, cfg.CertKey, cfg.Ca)
+	if err != nil {
+		return err
+	}
+
+	ec.cfg = cfg
+	ec.client, err = clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		DialTimeout: cfg.DialTimeoutMillisecond,
+		Username:    cfg.UserName,
+		Password:    cfg.Password,
+		TLS:         tlsConfig,
+	})
+	if err != nil {
+		log.Errorf("etcd config center init fail:%s", err)
+		return err
+	}
+
+	//启动时同步读取一次，服务Init时即可使用配置中心的配置
+	revision, err := ec.load()
+	if err != nil {
+		ec.client.Close()
+		log.Errorf("etcd config center load fail,endpoint:%s,key:%s,err:%s", cfg.Endpoints, cfg.Key, err)
+		return err
+	}
+
+	ec.ctx, ec.cancel = context.WithCancel(context.Background())
+	go ec.watch(revision)
+	return nil
+}
+
+// load 读取配置并应用，返回读取时etcd的revision
+func (ec *etcdConfigCenter) load() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	resp, err := ec.client.Get(ctx, ec.cfg.Key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		ec.apply(nil, resp.Header.Revision)
+	} else {
+		ec.apply(resp.Kvs[0].Value, resp.Kvs[0].ModRevision)
+	}
+
+	return resp.Header.Revision, nil
+}
+
+func (ec *etcdConfigCenter) watch(revision int64) {
+	for {
+		watchChan := ec.client.Watch(ec.ctx, ec.cfg.Key, clientv3.WithRev(revision+1))
+		for resp := range watchChan {
+			if err := resp.Err(); err != nil {
+				log.Errorf("etcd config center watch fail,key:%s,err:%s", ec.cfg.Key, err)
+				break
+			}
+
+			for _, ev := range resp.Events {
+				revision = ev.Kv.ModRevision
+				if ev.Type == mvccpb.DELETE {
+					ec.apply(nil, revision)
+				} else {
+					ec.apply(ev.Kv.Value, revision)
+				}
+			}
+		}
+
+		if ec.ctx.Err() != nil {
+			return
+		}
+
+		//重新全量读取，避免revision被压缩后无法继续监听
+		time.Sleep(configCenterRetryInterval)
+		if rev, err := ec.load(); err == nil {
+			revision = rev
+		} else {
+			log.Errorf("etcd config center load fail,key:%s,err:%s", ec.cfg.Key, err)
+		}
+	}
+}
+
+// apply 应用etcd中的配置，value为nil表示配置被删除，恢复使用本地配置文件
+func (ec *etcdConfigCenter) apply(value []byte, revision int64) {
+	data := &ConfigData{}
+	if value != nil {
+		if err := json.Unmarshal(value, data); err != nil {
+			log.Errorf("etcd config center unmarshal config fail,key:%s,err:%s", ec.cfg.Key, err)
+			return
+		}
+	}
+	data.Version = revision
+
+	if err := cluster.UpdateConfig(data); err != nil {
+		log.Errorf("etcd config center update config fail,key:%s,err:%s", ec.cfg.Key, err)
+	}
+}
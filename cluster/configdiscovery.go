@@ -1,34 +1,196 @@
 package cluster
 
-import "github.com/duanhf2012/origin/v2/rpc"
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/config"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
 
+const configWatchInterval = 3 * time.Second
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// ConfigDiscovery 未配置动态服务发现时从本地配置文件发现结点，服务的定时器每3秒检查一次配置文件，修改后增删结点
 type ConfigDiscovery struct {
-	funDelNode FunDelNode
-	funSetNode FunSetNode
+	service.Service
+
+	funDelNode  FunDelNode
+	funSetNode  FunSetNode
 	localNodeId string
-}
 
+	mapNodeInfo  map[string]NodeInfo  //map[nodeId]NodeInfo 当前已发现的结点
+	mapFileStamp map[string]fileStamp //map[fileName]fileStamp 配置文件的修改信息
+}
 
-func (discovery *ConfigDiscovery) InitDiscovery(localNodeId string,funDelNode FunDelNode,funSetNode FunSetNode) error{
+func (discovery *ConfigDiscovery) InitDiscovery(localNodeId string, funDelNode FunDelNode, funSetNode FunSetNode) error {
 	discovery.localNodeId = localNodeId
 	discovery.funDelNode = funDelNode
 	discovery.funSetNode = funSetNode
-	
+	discovery.mapNodeInfo = map[string]NodeInfo{}
+
 	//解析本地其他服务配置
-	_,nodeInfoList,_,err := GetCluster().readLocalClusterConfig(rpc.NodeIdNull)
+	_, nodeInfoList, _, err := GetCluster().readLocalClusterConfig(rpc.NodeIdNull)
 	if err != nil {
 		return err
 	}
 
-	for _,nodeInfo := range nodeInfoList {
+	for _, nodeInfo := range nodeInfoList {
 		if nodeInfo.NodeId == localNodeId {
 			continue
 		}
 
+		discovery.mapNodeInfo[nodeInfo.NodeId] = nodeInfo
 		discovery.funSetNode(&nodeInfo)
 	}
 
+	discovery.mapFileStamp, err = readClusterFileStamp()
+	if err != nil {
+		discovery.mapFileStamp = nil
+		log.Errorf("config discovery cannot watch cluster path %s:%s", config.GetClusterPath(), err)
+	}
+
 	return nil
 }
 
+func (discovery *ConfigDiscovery) OnStart() {
+	if discovery.mapFileStamp == nil {
+		return
+	}
+
+	discovery.NewTicker(configWatchInterval, discovery.watch)
+}
+
+// readClusterFileStamp 读取集群配置目录下所有配置文件的修改时间与大小
+func readClusterFileStamp() (map[string]fileStamp, error) {
+	mapFileStamp := map[string]fileStamp{}
+	err := filepath.Walk(config.GetClusterPath(), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ext := filepath.Ext(p)
+		if info.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		mapFileStamp[p] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+
+	return mapFileStamp, err
+}
+
+func isFileStampChanged(mapLastFileStamp map[string]fileStamp, mapFileStamp map[string]fileStamp) bool {
+	if len(mapFileStamp) != len(mapLastFileStamp) {
+		return true
+	}
+
+	for fileName, stamp := range mapFileStamp {
+		lastStamp, ok := mapLastFileStamp[fileName]
+		if ok == false || lastStamp != stamp {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (discovery *ConfigDiscovery) watch(_ *timer.Ticker) {
+	mapFileStamp, err := readClusterFileStamp()
+	if err != nil {
+		log.Errorf("config discovery read cluster path %s fail:%s", config.GetClusterPath(), err)
+		return
+	}
+
+	if isFileStampChanged(discovery.mapFileStamp, mapFileStamp) == false {
+		return
+	}
+
+	discovery.mapFileStamp = mapFileStamp
+	err = discovery.reload()
+	if err != nil {
+		//配置有误时保留当前的集群结点，等待下一次修改
+		log.Errorf("config discovery reload fail,keep the running cluster:%s", err)
+	}
+}
+
+// reload 重新加载NodeList，对比差异后增删结点
+func (discovery *ConfigDiscovery) reload() error {
+	value, err := config.ClusterReload()
+	if err != nil {
+		return err
+	}
+
+	fileNodeInfoList, err := decodeClusterConfig(value)
+	if err != nil {
+		return err
+	}
+
+	_, nodeInfoList, _, err := GetCluster().parseClusterConfig(rpc.NodeIdNull, fileNodeInfoList)
+	if err != nil {
+		return err
+	}
+
+	mapNodeInfo := make(map[string]NodeInfo, len(nodeInfoList))
+	hasLocalNode := false
+	for _, nodeInfo := range nodeInfoList {
+		if _, ok := mapNodeInfo[nodeInfo.NodeId]; ok == true {
+			return fmt.Errorf("nodeid %s is repeat in NodeList", nodeInfo.NodeId)
+		}
+
+		if nodeInfo.NodeId == discovery.localNodeId {
+			hasLocalNode = true
+			continue
+		}
+
+		mapNodeInfo[nodeInfo.NodeId] = nodeInfo
+	}
+
+	if hasLocalNode == false {
+		return fmt.Errorf("local nodeid %s cannot find in NodeList", discovery.localNodeId)
+	}
+
+	//删除不存在的结点
+	for nodeId := range discovery.mapNodeInfo {
+		if _, ok := mapNodeInfo[nodeId]; ok == true {
+			continue
+		}
+
+		log.Infof("config discovery remove node,NodeId:%s", nodeId)
+		discovery.funDelNode(nodeId)
+		delete(discovery.mapNodeInfo, nodeId)
+	}
+
+	//新增或者变化的结点
+	for nodeId, nodeInfo := range mapNodeInfo {
+		lastNodeInfo, ok := discovery.mapNodeInfo[nodeId]
+		if ok == true && reflect.DeepEqual(lastNodeInfo, nodeInfo) {
+			continue
+		}
 
+		if ok == true {
+			//地址等信息变化时，先断开再重新连接
+			log.Infof("config discovery update node,NodeId:%s,ListenAddr:%s,services:%s", nodeId, nodeInfo.ListenAddr, nodeInfo.PublicServiceList)
+			if lastNodeInfo.ListenAddr != nodeInfo.ListenAddr {
+				discovery.funDelNode(nodeId)
+			}
+		} else {
+			log.Infof("config discovery add node,NodeId:%s,ListenAddr:%s,services:%s", nodeId, nodeInfo.ListenAddr, nodeInfo.PublicServiceList)
+		}
+
+		discovery.mapNodeInfo[nodeId] = nodeInfo
+		discovery.funSetNode(&nodeInfo)
+	}
+
+	return nil
+}
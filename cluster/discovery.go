@@ -10,6 +10,10 @@ func (cls *Cluster) setupDiscovery(localNodeId string, setupServiceFun SetupServ
 		return cls.setupOriginDiscovery(localNodeId,setupServiceFun)
 	}else if cls.discoveryInfo.getDiscoveryType() ==  EtcdType{//etcd类型服务发现
 		return cls.setupEtcdDiscovery(localNodeId,setupServiceFun)
+	}else if cls.discoveryInfo.getDiscoveryType() ==  RedisType{//redis类型服务发现
+		return cls.setupRedisDiscovery(localNodeId,setupServiceFun)
+	}else if cls.discoveryInfo.getDiscoveryType() ==  GossipType{//gossip类型服务发现
+		return cls.setupGossipDiscovery(localNodeId,setupServiceFun)
 	}
 
 	return cls.setupConfigDiscovery(localNodeId,setupServiceFun)
@@ -53,12 +57,42 @@ func (cls *Cluster) setupEtcdDiscovery(localNodeId string, setupServiceFun Setup
 	return nil
 }
 
+func (cls *Cluster) setupRedisDiscovery(localNodeId string, setupServiceFun SetupServiceFun) error{
+	if cls.serviceDiscovery != nil {
+		return errors.New("service discovery has been setup")
+	}
+
+	//setup redis service
+	cls.serviceDiscovery = getRedisDiscovery()
+	setupServiceFun(cls.serviceDiscovery.(service.IService))
+
+	cls.AddDiscoveryService(cls.serviceDiscovery.(service.IService).GetName(),false)
+	return nil
+}
+
+func (cls *Cluster) setupGossipDiscovery(localNodeId string, setupServiceFun SetupServiceFun) error{
+	if cls.serviceDiscovery != nil {
+		return errors.New("service discovery has been setup")
+	}
+
+	//setup gossip service
+	cls.serviceDiscovery = getGossipDiscovery()
+	setupServiceFun(cls.serviceDiscovery.(service.IService))
+
+	cls.AddDiscoveryService(cls.serviceDiscovery.(service.IService).GetName(),false)
+	return nil
+}
+
 func (cls *Cluster) setupConfigDiscovery(localNodeId string, setupServiceFun SetupServiceFun) error{
 	if cls.serviceDiscovery != nil {
 		return errors.New("service discovery has been setup")
 	}
 
-	cls.serviceDiscovery = &ConfigDiscovery{}
+	configDiscovery := &ConfigDiscovery{}
+	cls.serviceDiscovery = configDiscovery
+	setupServiceFun(configDiscovery)
+
+	cls.AddDiscoveryService(configDiscovery.GetName(),false)
 	return nil
 }
 
@@ -69,3 +103,11 @@ func (cls *Cluster) GetOriginDiscovery() *OriginDiscovery {
 func (cls *Cluster) GetEtcdDiscovery() *EtcdDiscovery {
 	return cls.discoveryInfo.Etcd
 }
+
+func (cls *Cluster) GetRedisDiscovery() *RedisDiscovery {
+	return cls.discoveryInfo.Redis
+}
+
+func (cls *Cluster) GetGossipDiscovery() *GossipDiscovery {
+	return cls.discoveryInfo.Gossip
+}
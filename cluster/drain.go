@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"github.com/duanhf2012/origin/v2/network"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/service"
+	"time"
+)
+
+// DrainStatus 结点退休排空进度
+type DrainStatus struct {
+	Draining        bool
+	StartTime       time.Time
+	Deadline        time.Time
+	InboundRpcNum   int64 //在途的入站Rpc请求数
+	PendingCallNum  int   //等待返回的出站调用数
+	ClientConnNum   int64 //网络模块上的客户端连接数
+	PendingEventNum int   //服务中等待处理的事件数
+}
+
+// IsIdle 所有在途的请求、调用、连接与事件均已处理完成
+func (status *DrainStatus) IsIdle() bool {
+	return status.InboundRpcNum == 0 && status.PendingCallNum == 0 && status.ClientConnNum == 0 && status.PendingEventNum == 0
+}
+
+// StartDrain 开始排空，timeout为排空的最长时间
+func (cls *Cluster) StartDrain(timeout time.Duration) {
+	cls.drainLocker.Lock()
+	defer cls.drainLocker.Unlock()
+
+	if cls.drainStartTime.IsZero() == false {
+		return
+	}
+
+	cls.drainStartTime = time.Now()
+	cls.drainDeadline = cls.drainStartTime.Add(timeout)
+}
+
+// GetDrainStatus 获取本结点当前的排空进度，未开始排空时也会返回各项计数
+func (cls *Cluster) GetDrainStatus() DrainStatus {
+	var status DrainStatus
+	cls.drainLocker.RLock()
+	status.Draining = cls.drainStartTime.IsZero() == false
+	status.StartTime = cls.drainStartTime
+	status.Deadline = cls.drainDeadline
+	cls.drainLocker.RUnlock()
+
+	status.InboundRpcNum = rpc.GetInboundRequestNum()
+	status.PendingCallNum = cls.callSet.GetPendingNum()
+	status.ClientConnNum = network.GetClientConnNum()
+	status.PendingEventNum = service.GetPendingEventNum()
+
+	return status
+}
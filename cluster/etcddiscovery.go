@@ -23,10 +23,13 @@ import (
 
 const originDir = "/origin"
 
+const etcdHeartbeatTimeout = 3 * time.Second
+
 type etcdClientInfo struct {
-	watchKeys     []string
-	leaseID       clientv3.LeaseID
-	keepAliveChan <-chan *clientv3.LeaseKeepAliveResponse
+	watchKeys         []string
+	leaseID           clientv3.LeaseID
+	keepAliveChan     <-chan *clientv3.LeaseKeepAliveResponse
+	checkingHeartbeat int32 //正在查询其他结点的租约
 }
 
 type EtcdDiscoveryService struct {
@@ -35,11 +38,12 @@ type EtcdDiscoveryService struct {
 	funSetNode  FunSetNode
 	localNodeId string
 
-	byteLocalNodeInfo  string
-	mapClient          map[*clientv3.Client]*etcdClientInfo
-	isClose            int32
-	bRetire            bool
-	mapDiscoveryNodeId map[string]map[string]struct{} //map[networkName]map[nodeId]
+	mapByteLocalNodeInfo map[string]string //map[networkName]本结点信息，桥接的服务只发布到导出的网络
+	mapClient            map[*clientv3.Client]*etcdClientInfo
+	isClose              int32
+	bRetire              bool
+	mapDiscoveryNodeId   map[string]map[string]struct{} //map[networkName]map[nodeId]
+	election             etcdElection
 }
 
 var etcdDiscovery *EtcdDiscoveryService
@@ -82,6 +86,7 @@ func (ed *EtcdDiscoveryService) OnInit() error {
 	ed.mapDiscoveryNodeId = make(map[string]map[string]struct{})
 
 	ed.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_EtcdDiscovery, ed.GetEventHandler(), ed.OnEtcdDiscovery)
+	ed.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_LocalNodeInfo, ed.GetEventHandler(), ed.OnLocalNodeInfo)
 
 	err := ed.marshalNodeInfo()
 	if err != nil {
@@ -95,28 +100,9 @@ func (ed *EtcdDiscoveryService) OnInit() error {
 
 	for i := 0; i < len(etcdDiscoveryCfg.EtcdList); i++ {
 		var client *clientv3.Client
-		var tlsConfig *tls.Config
-
-		if etcdDiscoveryCfg.EtcdList[i].Cert != "" {
-			// load cert
-			cert, cerr := tls.LoadX509KeyPair(etcdDiscoveryCfg.EtcdList[i].Cert, etcdDiscoveryCfg.EtcdList[i].CertKey)
-			if cerr != nil {
-				log.Errorf("load cert error:%s", cerr)
-				return cerr
-			}
-
-			// load root ca
-			caData, cerr := ioutil.ReadFile(etcdDiscoveryCfg.EtcdList[i].Ca)
-			if cerr != nil {
-				log.Errorf("load root ca error:%s", cerr)
-				return cerr
-			}
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM(caData)
-			tlsConfig = &tls.Config{
-				Certificates: []tls.Certificate{cert},
-				RootCAs:      pool,
-			}
+		tlsConfig, err := loadEtcdTLSConfig(etcdDiscoveryCfg.EtcdList[i].Cert, etcdDiscoveryCfg.EtcdList[i].CertKey, etcdDiscoveryCfg.EtcdList[i].Ca)
+		if err != nil {
+			return err
 		}
 
 		client, err = clientv3.New(clientv3.Config{
@@ -146,11 +132,43 @@ func (ed *EtcdDiscoveryService) OnInit() error {
 		}
 
 		ed.mapClient[client] = ec
+		//单例服务使用第一个etcd选举
+		if i == 0 {
+			ed.election.init(client, etcdDiscoveryCfg.TTLSecond)
+		}
 	}
 
 	return nil
 }
 
+// loadEtcdTLSConfig 加载etcd客户端证书，cert为空时不使用TLS
+func loadEtcdTLSConfig(cert string, certKey string, ca string) (*tls.Config, error) {
+	if cert == "" {
+		return nil, nil
+	}
+
+	// load cert
+	certificate, err := tls.LoadX509KeyPair(cert, certKey)
+	if err != nil {
+		log.Errorf("load cert error:%s", err)
+		return nil, err
+	}
+
+	// load root ca
+	caData, err := ioutil.ReadFile(ca)
+	if err != nil {
+		log.Errorf("load root ca error:%s", err)
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caData)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      pool,
+	}, nil
+}
+
 func (ed *EtcdDiscoveryService) getRegisterKey(watchkey string) string {
 	return watchkey + "/" + ed.localNodeId
 }
@@ -169,7 +187,7 @@ func (ed *EtcdDiscoveryService) registerServiceByClient(client *clientv3.Client,
 	etcdClient.leaseID = resp.ID
 	for _, watchKey := range etcdClient.watchKeys {
 		// 注册服务节点到 etcd
-		_, err = client.Put(context.Background(), ed.getRegisterKey(watchKey), ed.byteLocalNodeInfo, clientv3.WithLease(resp.ID))
+		_, err = client.Put(context.Background(), ed.getRegisterKey(watchKey), ed.mapByteLocalNodeInfo[ed.getNetworkNameByWatchKey(watchKey)], clientv3.WithLease(resp.ID))
 		if err != nil {
 			log.Errorf("etcd Put fail:%s", err)
 			ed.tryRegisterService(client, etcdClient)
@@ -194,6 +212,7 @@ func (ed *EtcdDiscoveryService) registerServiceByClient(client *clientv3.Client,
 					ed.tryRegisterService(client, etcdClient)
 					return
 				}
+				cluster.refreshLocalHeartbeat()
 			}
 		}
 	}()
@@ -231,7 +250,7 @@ func (ed *EtcdDiscoveryService) retire() error {
 	for c, ec := range ed.mapClient {
 		for _, watchKey := range ec.watchKeys {
 			// 注册服务节点到 etcd
-			_, err := c.Put(context.Background(), ed.getRegisterKey(watchKey), ed.byteLocalNodeInfo, clientv3.WithLease(ec.leaseID))
+			_, err := c.Put(context.Background(), ed.getRegisterKey(watchKey), ed.mapByteLocalNodeInfo[ed.getNetworkNameByWatchKey(watchKey)], clientv3.WithLease(ec.leaseID))
 			if err != nil {
 				log.Errorf("etcd Put fail:%s", err)
 				return err
@@ -251,8 +270,18 @@ func (ed *EtcdDiscoveryService) OnRetire() {
 	}
 }
 
+// OnLocalNodeInfo 本结点信息变化(如服务健康状态)，重新写入etcd
+func (ed *EtcdDiscoveryService) OnLocalNodeInfo(ev event.IEvent) {
+	ed.marshalNodeInfo()
+
+	if ed.retire() != nil {
+		ed.tryLaterRetire()
+	}
+}
+
 func (ed *EtcdDiscoveryService) OnRelease() {
 	atomic.StoreInt32(&ed.isClose, 1)
+	ed.election.resign()
 	ed.close()
 }
 
@@ -265,6 +294,51 @@ func (ed *EtcdDiscoveryService) OnStart() {
 		ed.tryRegisterService(c, ec)
 		ed.tryWatch(c, ec)
 	}
+
+	ed.NewTicker(time.Duration(cluster.GetEtcdDiscovery().TTLSecond)*time.Second, func(t *timer.Ticker) {
+		for c, ec := range ed.mapClient {
+			ed.checkHeartbeat(c, ec)
+		}
+	})
+}
+
+// checkHeartbeat 查询其他结点租约最后一次续约的时间，作为结点的心跳时间。在独立的协程中查询，避免阻塞服务
+func (ed *EtcdDiscoveryService) checkHeartbeat(client *clientv3.Client, etcdClient *etcdClientInfo) {
+	if ed.isStop() || atomic.CompareAndSwapInt32(&etcdClient.checkingHeartbeat, 0, 1) == false {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&etcdClient.checkingHeartbeat, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), etcdHeartbeatTimeout)
+		defer cancel()
+
+		mapLeaseNodeId := map[clientv3.LeaseID]string{}
+		for _, watchKey := range etcdClient.watchKeys {
+			resp, err := client.Get(ctx, watchKey, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+			if err != nil {
+				log.Errorf("etcd get heartbeat fail,watchKey:%s,err:%s", watchKey, err)
+				return
+			}
+
+			for _, kv := range resp.Kvs {
+				nodeId := ed.getNodeId(string(kv.Key))
+				if kv.Lease != 0 && nodeId != ed.localNodeId {
+					mapLeaseNodeId[clientv3.LeaseID(kv.Lease)] = nodeId
+				}
+			}
+		}
+
+		for leaseID, nodeId := range mapLeaseNodeId {
+			resp, err := client.TimeToLive(ctx, leaseID)
+			if err != nil || resp.TTL <= 0 {
+				continue
+			}
+
+			//剩余时间为GrantedTTL时刚完成续约
+			cluster.refreshNodeHeartbeat(nodeId, time.Now().Add(-time.Duration(resp.GrantedTTL-resp.TTL)*time.Second))
+		}
+	}()
 }
 
 func (ed *EtcdDiscoveryService) marshalNodeInfo() error {
@@ -273,15 +347,30 @@ func (ed *EtcdDiscoveryService) marshalNodeInfo() error {
 	nodeInfo.NodeId = nInfo.NodeId
 	nodeInfo.ListenAddr = nInfo.ListenAddr
 	nodeInfo.Retire = ed.bRetire
-	nodeInfo.PublicServiceList = nInfo.PublicServiceList
 	nodeInfo.MaxRpcParamLen = nInfo.MaxRpcParamLen
+	nodeInfo.Labels = nInfo.Labels
+	nodeInfo.ServiceVersion = nInfo.ServiceVersion
+	nodeInfo.UnhealthyServiceList = cluster.GetUnhealthyServiceList()
+	nodeInfo.SingletonServiceList = nInfo.SingletonServiceList
+	nodeInfo.ServiceLeader = cluster.GetServiceLeader()
+
+	mapByteLocalNodeInfo := make(map[string]string, len(ed.mapByteLocalNodeInfo))
+	for _, ec := range ed.mapClient {
+		for _, watchKey := range ec.watchKeys {
+			networkName := ed.getNetworkNameByWatchKey(watchKey)
+			nodeInfo.PublicServiceList = cluster.GetNetworkPublicServiceList(networkName)
 
-	byteLocalNodeInfo, err := proto.Marshal(&nodeInfo)
-	if err == nil {
-		ed.byteLocalNodeInfo = string(byteLocalNodeInfo)
+			//标签为map,使用确定的序列化顺序，保证结点信息无变化时序列化结果一致
+			byteLocalNodeInfo, err := proto.MarshalOptions{Deterministic: true}.Marshal(&nodeInfo)
+			if err != nil {
+				return err
+			}
+			mapByteLocalNodeInfo[networkName] = string(byteLocalNodeInfo)
+		}
 	}
 
-	return err
+	ed.mapByteLocalNodeInfo = mapByteLocalNodeInfo
+	return nil
 }
 
 func (ed *EtcdDiscoveryService) setNodeInfo(networkName string, nodeInfo *rpc.NodeInfo) bool {
@@ -292,7 +381,7 @@ func (ed *EtcdDiscoveryService) setNodeInfo(networkName string, nodeInfo *rpc.No
 	//筛选关注的服务
 	var discoverServiceSlice = make([]string, 0, 24)
 	for _, pubService := range nodeInfo.PublicServiceList {
-		if cluster.CanDiscoveryService(networkName, pubService) == true {
+		if cluster.CanDiscoveryService(networkName, pubService, nodeInfo.Labels) == true {
 			discoverServiceSlice = append(discoverServiceSlice, pubService)
 		}
 	}
@@ -309,6 +398,12 @@ func (ed *EtcdDiscoveryService) setNodeInfo(networkName string, nodeInfo *rpc.No
 	nInfo.MaxRpcParamLen = nodeInfo.MaxRpcParamLen
 	nInfo.Retire = nodeInfo.Retire
 	nInfo.Private = nodeInfo.Private
+	nInfo.Labels = nodeInfo.Labels
+	nInfo.ServiceVersion = nodeInfo.ServiceVersion
+	nInfo.UnhealthyServiceList = nodeInfo.UnhealthyServiceList
+	nInfo.SingletonServiceList = nodeInfo.SingletonServiceList
+	nInfo.ServiceLeader = nodeInfo.ServiceLeader
+	nInfo.NetworkName = networkName
 
 	ed.funSetNode(&nInfo)
 
@@ -545,3 +640,12 @@ func (ed *EtcdDiscoveryService) RPC_ServiceRecord(etcdServiceRecord *service.Etc
 
 	return nil
 }
+
+// GetEtcdClient 获取etcd服务发现使用的第一个etcd客户端，未使用etcd服务发现时返回nil
+func GetEtcdClient() *clientv3.Client {
+	if etcdDiscovery == nil {
+		return nil
+	}
+
+	return etcdDiscovery.election.client
+}
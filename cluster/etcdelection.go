@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"context"
+	"github.com/duanhf2012/origin/v2/log"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"sync"
+	"time"
+)
+
+// etcdElection 基于etcd租约的单例服务选举，Leader结点失联时租约过期，由其他结点接管
+type etcdElection struct {
+	locker     sync.Mutex
+	client     *clientv3.Client
+	ttlSecond  int
+	mapSession map[string]*electionSession //map[serviceName]竞选使用的会话
+	isResign   bool
+}
+
+type electionSession struct {
+	*concurrency.Session
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// close 先释放租约，再中止正在进行的竞选
+func (es *electionSession) close() error {
+	err := es.Session.Close()
+	es.cancel()
+	return err
+}
+
+func (ee *etcdElection) init(client *clientv3.Client, ttlSecond int64) {
+	ee.client = client
+	ee.ttlSecond = int(ttlSecond)
+	ee.mapSession = map[string]*electionSession{}
+}
+
+func (ed *EtcdDiscoveryService) ElectSingleton(serviceList []string) {
+	for _, serviceName := range serviceList {
+		go ed.election.campaign(ed.localNodeId, serviceName)
+	}
+}
+
+func (ed *EtcdDiscoveryService) ResignSingleton() {
+	ed.election.resign()
+}
+
+func (ee *etcdElection) campaign(localNodeId string, serviceName string) {
+	for {
+		session := ee.newSession(serviceName)
+		if session == nil {
+			return
+		}
+
+		election := concurrency.NewElection(session.Session, originDir+"/singleton/"+serviceName)
+		err := election.Campaign(session.ctx, localNodeId)
+		if err != nil {
+			if session.ctx.Err() == nil {
+				log.Errorf("etcd campaign fail,serviceName:%s,err:%s", serviceName, err)
+			}
+			session.close()
+			time.Sleep(time.Second * 3)
+			continue
+		}
+
+		cluster.setServiceLeader(serviceName, true)
+
+		//租约过期或主动退出竞选
+		<-session.Done()
+		session.close()
+		cluster.setServiceLeader(serviceName, false)
+	}
+}
+
+// newSession 创建竞选会话，已退出竞选时返回nil
+func (ee *etcdElection) newSession(serviceName string) *electionSession {
+	for {
+		ee.locker.Lock()
+		if ee.isResign == true {
+			ee.locker.Unlock()
+			return nil
+		}
+		ee.locker.Unlock()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		session, err := concurrency.NewSession(ee.client, concurrency.WithTTL(ee.ttlSecond), concurrency.WithContext(ctx))
+		if err != nil {
+			cancel()
+			log.Errorf("etcd new session fail,serviceName:%s,err:%s", serviceName, err)
+			time.Sleep(time.Second * 3)
+			continue
+		}
+
+		es := &electionSession{Session: session, ctx: ctx, cancel: cancel}
+
+		ee.locker.Lock()
+		defer ee.locker.Unlock()
+		if ee.isResign == true {
+			es.close()
+			return nil
+		}
+
+		ee.mapSession[serviceName] = es
+		return es
+	}
+}
+
+// resign 退出竞选，关闭会话后租约立即释放，其他结点可以马上接管
+func (ee *etcdElection) resign() {
+	ee.locker.Lock()
+	defer ee.locker.Unlock()
+
+	ee.isResign = true
+	for serviceName, session := range ee.mapSession {
+		if err := session.close(); err != nil {
+			log.Errorf("etcd close session fail,serviceName:%s,err:%s", serviceName, err)
+		}
+	}
+	ee.mapSession = map[string]*electionSession{}
+}
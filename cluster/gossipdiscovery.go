@@ -0,0 +1,794 @@
+package cluster
+
+import (
+	"errors"
+	"github.com/duanhf2012/origin/v2/event"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"google.golang.org/protobuf/proto"
+	"math"
+	"math/rand"
+	"net"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+type gossipMessageType int8
+
+const (
+	gossipPing    gossipMessageType = 0 //探测，目标结点回复gossipAck
+	gossipAck     gossipMessageType = 1
+	gossipPingReq gossipMessageType = 2 //请求其他结点代为探测目标结点
+	gossipSync    gossipMessageType = 3 //发送全量成员，接收方以gossipState回复自己的全量成员
+	gossipState   gossipMessageType = 4 //发送全量成员或主动离开，不需要回复
+)
+
+type gossipMemberState int8
+
+const (
+	gossipAlive   gossipMemberState = 0
+	gossipSuspect gossipMemberState = 1 //探测失败，超时前未反驳则认为失败
+	gossipDead    gossipMemberState = 2 //结点失败或主动离开
+)
+
+const (
+	gossipIndirectNum    = 3                //间接探测的结点数
+	gossipRetransmitMult = 4                //每条变更的传播次数为gossipRetransmitMult*log10(n+1)
+	gossipMaxPacketLen   = 60 * 1024        //UDP包的最大长度
+	gossipMaxPiggyback   = 8 * 1024         //探测消息附带的变更最大字节数
+	gossipDeadRetainTime = 60 * time.Second //失败结点的保留时长，防止过期的消息使其复活
+	gossipJoinRetry      = 3 * time.Second  //未发现任何结点时重新连接种子的间隔
+)
+
+// gossipUpdate 成员状态变更，Incarnation由结点自己递增，用于反驳怀疑与发布结点信息的变化
+type gossipUpdate struct {
+	NodeId      string
+	Addr        string
+	Incarnation int64
+	State       gossipMemberState
+	NodeInfo    []byte `json:",omitempty"` //rpc.NodeInfo,失败状态不携带
+}
+
+type gossipMessage struct {
+	Type       gossipMessageType
+	Network    string
+	Seq        uint64
+	Target     string //探测的目标结点
+	TargetAddr string //gossipPingReq中目标结点的地址
+	Updates    []gossipUpdate
+}
+
+type gossipMember struct {
+	update    gossipUpdate
+	stateTime time.Time
+}
+
+type gossipBroadcast struct {
+	update   gossipUpdate
+	transmit int
+}
+
+// gossipNetwork 每个网络独立维护成员与传播队列，结点只会发现相同网络中的结点
+type gossipNetwork struct {
+	name          string
+	localNodeInfo []byte
+	mapMember     map[string]*gossipMember //map[nodeId]
+	mapNotified   map[string]string        //map[nodeId]已通知cluster的结点信息
+	probeList     []string                 //本轮待探测的结点
+	broadcastList []*gossipBroadcast
+}
+
+type gossipProbe struct {
+	acked  bool
+	nodeId string //被探测的结点
+
+	//代其他结点探测时，收到回复后转发给请求者
+	relayAddr *net.UDPAddr
+	relaySeq  uint64
+}
+
+type gossipDiscoveryEvent struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+func (ge *gossipDiscoveryEvent) GetEventType() event.EventType {
+	return event.Sys_Event_GossipDiscovery
+}
+
+// GossipDiscoveryService 基于SWIM协议的P2P服务发现，结点通过任意种子加入，探测失败后经其他结点间接探测，
+// 仍失败时标记为怀疑状态，超时未反驳则认为失败。成员变更附带在探测消息中传播，并定期与随机结点全量同步
+type GossipDiscoveryService struct {
+	service.Service
+	funDelNode  FunDelNode
+	funSetNode  FunSetNode
+	localNodeId string
+	cfg         *GossipDiscovery
+
+	conn        *net.UDPConn
+	isClose     int32
+	bRetire     bool
+	incarnation int64
+	seq         uint64
+	mapNetwork  map[string]*gossipNetwork //map[networkName]
+	mapProbe    map[uint64]*gossipProbe   //map[seq]等待回复的探测
+	mapUDPAddr  map[string]*net.UDPAddr
+}
+
+var gossipDiscovery *GossipDiscoveryService
+
+func getGossipDiscovery() IServiceDiscovery {
+	if gossipDiscovery == nil {
+		gossipDiscovery = &GossipDiscoveryService{}
+	}
+
+	return gossipDiscovery
+}
+
+func (gd *GossipDiscoveryService) InitDiscovery(localNodeId string, funDelNode FunDelNode, funSetNode FunSetNode) error {
+	gd.localNodeId = localNodeId
+	gd.cfg = cluster.GetGossipDiscovery()
+
+	gd.funDelNode = funDelNode
+	gd.funSetNode = funSetNode
+
+	return nil
+}
+
+func (gd *GossipDiscoveryService) OnInit() error {
+	cfg := gd.cfg
+	if cfg == nil {
+		return errors.New("gossip discovery config is nil")
+	}
+
+	gd.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_GossipDiscovery, gd.GetEventHandler(), gd.OnGossipDiscovery)
+	gd.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_LocalNodeInfo, gd.GetEventHandler(), gd.OnLocalNodeInfo)
+
+	//重启后使用更大的Incarnation，覆盖其他结点记录的失败状态
+	gd.incarnation = time.Now().UnixMilli()
+	gd.mapProbe = map[uint64]*gossipProbe{}
+	gd.mapUDPAddr = map[string]*net.UDPAddr{}
+	gd.mapNetwork = make(map[string]*gossipNetwork, len(cfg.NetworkName))
+	for _, networkName := range cfg.NetworkName {
+		gd.mapNetwork[networkName] = &gossipNetwork{name: networkName, mapMember: map[string]*gossipMember{}, mapNotified: map[string]string{}}
+	}
+
+	err := gd.marshalNodeInfo()
+	if err != nil {
+		return err
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	gd.conn, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		log.Errorf("gossip discovery listen fail,addr:%s,err:%s", cfg.ListenAddr, err)
+		return err
+	}
+
+	go gd.read(gd.conn)
+	return nil
+}
+
+func (gd *GossipDiscoveryService) OnStart() {
+	cfg := gd.cfg
+	gd.join()
+
+	gd.NewTicker(cfg.ProbeIntervalMillisecond, func(t *timer.Ticker) {
+		for _, gn := range gd.mapNetwork {
+			gd.probe(gn)
+		}
+	})
+
+	gd.NewTicker(time.Second, func(t *timer.Ticker) {
+		for _, gn := range gd.mapNetwork {
+			gd.checkMember(gn)
+		}
+	})
+
+	gd.NewTicker(time.Duration(cfg.SyncIntervalSecond)*time.Second, func(t *timer.Ticker) {
+		for _, gn := range gd.mapNetwork {
+			gd.pushPull(gn)
+		}
+	})
+
+	gd.NewTicker(gossipJoinRetry, func(t *timer.Ticker) {
+		gd.join()
+	})
+}
+
+func (gd *GossipDiscoveryService) OnRetire() {
+	gd.bRetire = true
+	gd.refreshLocalNodeInfo()
+}
+
+// OnLocalNodeInfo 本结点信息变化(如服务健康状态)，递增Incarnation后传播
+func (gd *GossipDiscoveryService) OnLocalNodeInfo(ev event.IEvent) {
+	gd.refreshLocalNodeInfo()
+}
+
+// OnRelease 通知所有结点本结点主动离开
+func (gd *GossipDiscoveryService) OnRelease() {
+	atomic.StoreInt32(&gd.isClose, 1)
+
+	leave := gossipUpdate{NodeId: gd.localNodeId, Addr: gd.cfg.AdvertiseAddr, Incarnation: gd.incarnation, State: gossipDead}
+	for _, gn := range gd.mapNetwork {
+		for _, member := range gn.mapMember {
+			if member.update.State != gossipDead {
+				gd.sendTo(gn, member.update.Addr, &gossipMessage{Type: gossipState, Updates: []gossipUpdate{leave}})
+			}
+		}
+	}
+
+	if gd.conn != nil {
+		gd.conn.Close()
+	}
+}
+
+func (gd *GossipDiscoveryService) isStop() bool {
+	return atomic.LoadInt32(&gd.isClose) == 1
+}
+
+// read 在独立协程中接收UDP包，转到服务协程处理
+func (gd *GossipDiscoveryService) read(conn *net.UDPConn) {
+	buf := make([]byte, math.MaxUint16)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if gd.isStop() {
+				return
+			}
+
+			log.Errorf("gossip discovery read fail:%s", err)
+			continue
+		}
+
+		gd.NotifyEvent(&gossipDiscoveryEvent{addr: addr, data: slices.Clone(buf[:n])})
+	}
+}
+
+func (gd *GossipDiscoveryService) marshalNodeInfo() error {
+	nInfo := cluster.GetLocalNodeInfo()
+	var nodeInfo rpc.NodeInfo
+	nodeInfo.NodeId = gd.localNodeId
+	nodeInfo.ListenAddr = nInfo.ListenAddr
+	nodeInfo.Retire = gd.bRetire
+	nodeInfo.MaxRpcParamLen = nInfo.MaxRpcParamLen
+	nodeInfo.Labels = nInfo.Labels
+	nodeInfo.ServiceVersion = nInfo.ServiceVersion
+	nodeInfo.UnhealthyServiceList = cluster.GetUnhealthyServiceList()
+	nodeInfo.SingletonServiceList = nInfo.SingletonServiceList
+	nodeInfo.ServiceLeader = cluster.GetServiceLeader()
+	nodeInfo.Private = nInfo.Private
+
+	for _, gn := range gd.mapNetwork {
+		nodeInfo.PublicServiceList = cluster.GetNetworkPublicServiceList(gn.name)
+
+		//标签为map,使用确定的序列化顺序，保证结点信息无变化时序列化结果一致
+		byteLocalNodeInfo, err := proto.MarshalOptions{Deterministic: true}.Marshal(&nodeInfo)
+		if err != nil {
+			return err
+		}
+		gn.localNodeInfo = byteLocalNodeInfo
+	}
+
+	return nil
+}
+
+func (gd *GossipDiscoveryService) refreshLocalNodeInfo() {
+	if err := gd.marshalNodeInfo(); err != nil {
+		log.Errorf("gossip discovery marshal node info fail:%s", err)
+		return
+	}
+
+	gd.incarnation++
+	for _, gn := range gd.mapNetwork {
+		gd.queueBroadcast(gn, gd.localUpdate(gn))
+	}
+}
+
+func (gd *GossipDiscoveryService) localUpdate(gn *gossipNetwork) gossipUpdate {
+	return gossipUpdate{NodeId: gd.localNodeId, Addr: gd.cfg.AdvertiseAddr, Incarnation: gd.incarnation, State: gossipAlive, NodeInfo: gn.localNodeInfo}
+}
+
+func (gd *GossipDiscoveryService) nextSeq() uint64 {
+	gd.seq++
+	return gd.seq
+}
+
+func (gd *GossipDiscoveryService) resolveAddr(addr string) (*net.UDPAddr, error) {
+	if udpAddr, ok := gd.mapUDPAddr[addr]; ok == true {
+		return udpAddr, nil
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	gd.mapUDPAddr[addr] = udpAddr
+	return udpAddr, nil
+}
+
+func (gd *GossipDiscoveryService) sendTo(gn *gossipNetwork, addr string, msg *gossipMessage) {
+	udpAddr, err := gd.resolveAddr(addr)
+	if err != nil {
+		log.Errorf("gossip discovery resolve addr fail,addr:%s,err:%s", addr, err)
+		return
+	}
+
+	gd.send(gn, udpAddr, msg)
+}
+
+// send 探测相关的消息附带待传播的变更
+func (gd *GossipDiscoveryService) send(gn *gossipNetwork, addr *net.UDPAddr, msg *gossipMessage) {
+	msg.Network = gn.name
+	if msg.Type == gossipPing || msg.Type == gossipAck || msg.Type == gossipPingReq {
+		msg.Updates = gd.piggyback(gn)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("gossip discovery marshal fail:%s", err)
+		return
+	}
+
+	if len(data) > gossipMaxPacketLen {
+		log.Errorf("gossip discovery message is too long,networkName:%s,len:%d", gn.name, len(data))
+		return
+	}
+
+	_, err = gd.conn.WriteToUDP(data, addr)
+	if err != nil && gd.isStop() == false {
+		log.Debugf("gossip discovery send fail,addr:%s,err:%s", addr.String(), err)
+	}
+}
+
+// sendState 发送本结点视角的全量成员，超过单个包的长度时拆分发送，只有第一个包使用typ
+func (gd *GossipDiscoveryService) sendState(gn *gossipNetwork, addr *net.UDPAddr, typ gossipMessageType) {
+	updates := []gossipUpdate{gd.localUpdate(gn)}
+	size := gossipUpdateSize(&updates[0])
+	for _, member := range gn.mapMember {
+		updateSize := gossipUpdateSize(&member.update)
+		if size+updateSize > gossipMaxPacketLen/2 {
+			gd.send(gn, addr, &gossipMessage{Type: typ, Updates: updates})
+			typ = gossipState
+			updates = nil
+			size = 0
+		}
+
+		updates = append(updates, member.update)
+		size += updateSize
+	}
+
+	gd.send(gn, addr, &gossipMessage{Type: typ, Updates: updates})
+}
+
+// gossipUpdateSize 估算变更序列化后的长度，NodeInfo以base64编码
+func gossipUpdateSize(update *gossipUpdate) int {
+	return len(update.NodeId) + len(update.Addr) + len(update.NodeInfo)*4/3 + 96
+}
+
+// join 向种子发送全量同步，未发现任何结点的网络会定时重试
+func (gd *GossipDiscoveryService) join() {
+	if gd.isStop() {
+		return
+	}
+
+	cfg := gd.cfg
+	for _, gn := range gd.mapNetwork {
+		if gd.getAliveNum(gn) > 0 {
+			continue
+		}
+
+		for _, seed := range cfg.SeedList {
+			if seed == cfg.AdvertiseAddr {
+				continue
+			}
+
+			udpAddr, err := gd.resolveAddr(seed)
+			if err != nil {
+				log.Errorf("gossip discovery resolve seed fail,seed:%s,err:%s", seed, err)
+				continue
+			}
+
+			gd.sendState(gn, udpAddr, gossipSync)
+		}
+	}
+}
+
+func (gd *GossipDiscoveryService) getAliveNum(gn *gossipNetwork) int {
+	num := 0
+	for _, member := range gn.mapMember {
+		if member.update.State != gossipDead {
+			num++
+		}
+	}
+
+	return num
+}
+
+// pushPull 与随机结点交换全量成员，修复丢失的变更。同时与随机种子交换，使网络分区恢复后能重新合并
+func (gd *GossipDiscoveryService) pushPull(gn *gossipNetwork) {
+	var addrList []string
+	for _, member := range gd.randomMembers(gn, 1, "") {
+		addrList = append(addrList, member.update.Addr)
+	}
+
+	cfg := gd.cfg
+	if len(cfg.SeedList) > 0 {
+		if seed := cfg.SeedList[rand.Intn(len(cfg.SeedList))]; seed != cfg.AdvertiseAddr && slices.Contains(addrList, seed) == false {
+			addrList = append(addrList, seed)
+		}
+	}
+
+	for _, addr := range addrList {
+		udpAddr, err := gd.resolveAddr(addr)
+		if err != nil {
+			log.Errorf("gossip discovery resolve addr fail,addr:%s,err:%s", addr, err)
+			continue
+		}
+
+		gd.sendState(gn, udpAddr, gossipSync)
+	}
+}
+
+// randomMembers 随机选择num个未失败的结点
+func (gd *GossipDiscoveryService) randomMembers(gn *gossipNetwork, num int, excludeNodeId string) []*gossipMember {
+	memberList := make([]*gossipMember, 0, len(gn.mapMember))
+	for nodeId, member := range gn.mapMember {
+		if nodeId != excludeNodeId && member.update.State != gossipDead {
+			memberList = append(memberList, member)
+		}
+	}
+
+	rand.Shuffle(len(memberList), func(i, j int) {
+		memberList[i], memberList[j] = memberList[j], memberList[i]
+	})
+
+	if len(memberList) > num {
+		memberList = memberList[:num]
+	}
+
+	return memberList
+}
+
+// nextProbeMember 每轮随机打乱后依次探测，保证每个结点在有限时间内被探测到
+func (gd *GossipDiscoveryService) nextProbeMember(gn *gossipNetwork) *gossipMember {
+	for {
+		if len(gn.probeList) == 0 {
+			for nodeId, member := range gn.mapMember {
+				if member.update.State != gossipDead {
+					gn.probeList = append(gn.probeList, nodeId)
+				}
+			}
+
+			if len(gn.probeList) == 0 {
+				return nil
+			}
+
+			rand.Shuffle(len(gn.probeList), func(i, j int) {
+				gn.probeList[i], gn.probeList[j] = gn.probeList[j], gn.probeList[i]
+			})
+		}
+
+		nodeId := gn.probeList[0]
+		gn.probeList = gn.probeList[1:]
+		if member, ok := gn.mapMember[nodeId]; ok == true && member.update.State != gossipDead {
+			return member
+		}
+	}
+}
+
+// probe 直接探测超时后请求其他结点间接探测，探测周期内都没有回复则将结点标记为怀疑状态
+func (gd *GossipDiscoveryService) probe(gn *gossipNetwork) {
+	member := gd.nextProbeMember(gn)
+	if member == nil {
+		return
+	}
+
+	nodeId := member.update.NodeId
+	targetAddr := member.update.Addr
+	seq := gd.nextSeq()
+	probe := &gossipProbe{nodeId: nodeId}
+	gd.mapProbe[seq] = probe
+	gd.sendTo(gn, targetAddr, &gossipMessage{Type: gossipPing, Seq: seq, Target: nodeId})
+
+	probeInterval := gd.cfg.ProbeIntervalMillisecond
+	gd.AfterFunc(probeInterval/2, func(t *timer.Timer) {
+		if probe.acked == true || gd.isStop() {
+			return
+		}
+
+		for _, relay := range gd.randomMembers(gn, gossipIndirectNum, nodeId) {
+			gd.sendTo(gn, relay.update.Addr, &gossipMessage{Type: gossipPingReq, Seq: seq, Target: nodeId, TargetAddr: targetAddr})
+		}
+	})
+
+	gd.AfterFunc(probeInterval, func(t *timer.Timer) {
+		delete(gd.mapProbe, seq)
+		if probe.acked == true || gd.isStop() {
+			return
+		}
+
+		log.Debugf("gossip discovery probe fail,networkName:%s,nodeId:%s", gn.name, nodeId)
+		gd.suspect(gn, nodeId)
+	})
+}
+
+func (gd *GossipDiscoveryService) suspect(gn *gossipNetwork, nodeId string) {
+	member, ok := gn.mapMember[nodeId]
+	if ok == false || member.update.State != gossipAlive {
+		return
+	}
+
+	update := member.update
+	update.State = gossipSuspect
+	gd.applyUpdate(gn, &update)
+}
+
+// checkMember 怀疑超时的结点标记为失败，清理保留期已过的失败结点
+func (gd *GossipDiscoveryService) checkMember(gn *gossipNetwork) {
+	now := time.Now()
+	suspectTimeout := time.Duration(gd.cfg.SuspectTimeoutSecond) * time.Second
+	for nodeId, member := range gn.mapMember {
+		switch member.update.State {
+		case gossipSuspect:
+			if now.Sub(member.stateTime) < suspectTimeout {
+				continue
+			}
+
+			log.Warnf("gossip discovery node is dead,networkName:%s,nodeId:%s", gn.name, nodeId)
+			update := member.update
+			update.State = gossipDead
+			update.NodeInfo = nil
+			gd.applyUpdate(gn, &update)
+		case gossipDead:
+			if now.Sub(member.stateTime) >= gossipDeadRetainTime {
+				delete(gn.mapMember, nodeId)
+			}
+		}
+	}
+}
+
+func (gd *GossipDiscoveryService) OnGossipDiscovery(ev event.IEvent) {
+	gossipEvent := ev.(*gossipDiscoveryEvent)
+	var msg gossipMessage
+	err := json.Unmarshal(gossipEvent.data, &msg)
+	if err != nil {
+		log.Errorf("gossip discovery unmarshal fail,addr:%s,err:%s", gossipEvent.addr.String(), err)
+		return
+	}
+
+	//不在该网络中
+	gn, ok := gd.mapNetwork[msg.Network]
+	if ok == false || gd.isStop() {
+		return
+	}
+
+	for i := range msg.Updates {
+		gd.applyUpdate(gn, &msg.Updates[i])
+	}
+
+	switch msg.Type {
+	case gossipPing:
+		if msg.Target == gd.localNodeId {
+			gd.send(gn, gossipEvent.addr, &gossipMessage{Type: gossipAck, Seq: msg.Seq})
+		}
+	case gossipAck:
+		gd.onAck(gn, msg.Seq)
+	case gossipPingReq:
+		gd.onPingReq(gn, gossipEvent.addr, &msg)
+	case gossipSync:
+		gd.sendState(gn, gossipEvent.addr, gossipState)
+	}
+}
+
+func (gd *GossipDiscoveryService) onAck(gn *gossipNetwork, seq uint64) {
+	probe, ok := gd.mapProbe[seq]
+	if ok == false {
+		return
+	}
+
+	probe.acked = true
+	if probe.relayAddr != nil {
+		delete(gd.mapProbe, seq)
+		gd.send(gn, probe.relayAddr, &gossipMessage{Type: gossipAck, Seq: probe.relaySeq})
+		return
+	}
+
+	//直接或间接探测得到回复，都说明结点存活
+	cluster.refreshNodeHeartbeat(probe.nodeId, time.Now())
+	cluster.refreshLocalHeartbeat()
+}
+
+// onPingReq 代请求者探测目标结点，收到回复后转发
+func (gd *GossipDiscoveryService) onPingReq(gn *gossipNetwork, addr *net.UDPAddr, msg *gossipMessage) {
+	seq := gd.nextSeq()
+	gd.mapProbe[seq] = &gossipProbe{relayAddr: addr, relaySeq: msg.Seq}
+	gd.sendTo(gn, msg.TargetAddr, &gossipMessage{Type: gossipPing, Seq: seq, Target: msg.Target})
+
+	gd.AfterFunc(gd.cfg.ProbeIntervalMillisecond, func(t *timer.Timer) {
+		delete(gd.mapProbe, seq)
+	})
+}
+
+// applyUpdate 按Incarnation合并成员变更，生效的变更会继续传播
+func (gd *GossipDiscoveryService) applyUpdate(gn *gossipNetwork, update *gossipUpdate) {
+	if update.NodeId == "" {
+		return
+	}
+
+	//其他结点认为本结点可疑或失败，递增Incarnation反驳
+	if update.NodeId == gd.localNodeId {
+		if update.State != gossipAlive && update.Incarnation >= gd.incarnation && gd.isStop() == false {
+			gd.incarnation = update.Incarnation + 1
+			for _, network := range gd.mapNetwork {
+				gd.queueBroadcast(network, gd.localUpdate(network))
+			}
+		}
+		return
+	}
+
+	member, ok := gn.mapMember[update.NodeId]
+	if ok == true {
+		last := &member.update
+		switch update.State {
+		case gossipAlive:
+			if update.Incarnation <= last.Incarnation {
+				return
+			}
+		case gossipSuspect:
+			if last.State == gossipDead || update.Incarnation < last.Incarnation || (update.Incarnation == last.Incarnation && last.State != gossipAlive) {
+				return
+			}
+		case gossipDead:
+			if last.State == gossipDead || update.Incarnation < last.Incarnation {
+				return
+			}
+		default:
+			return
+		}
+	} else {
+		member = &gossipMember{}
+		gn.mapMember[update.NodeId] = member
+	}
+
+	nodeInfo := member.update.NodeInfo
+	member.update = *update
+	if len(member.update.NodeInfo) == 0 && update.State != gossipDead {
+		member.update.NodeInfo = nodeInfo
+	}
+	member.stateTime = time.Now()
+	gd.queueBroadcast(gn, member.update)
+
+	if update.State == gossipDead {
+		gd.delNode(gn, update.NodeId)
+	} else {
+		gd.setNode(gn, member.update.NodeInfo)
+	}
+}
+
+func (gd *GossipDiscoveryService) queueBroadcast(gn *gossipNetwork, update gossipUpdate) {
+	if gd.isStop() {
+		return
+	}
+
+	gn.broadcastList = slices.DeleteFunc(gn.broadcastList, func(b *gossipBroadcast) bool {
+		return b.update.NodeId == update.NodeId
+	})
+	gn.broadcastList = append(gn.broadcastList, &gossipBroadcast{update: update})
+}
+
+// piggyback 优先附带传播次数少的变更，达到传播次数后移除
+func (gd *GossipDiscoveryService) piggyback(gn *gossipNetwork) []gossipUpdate {
+	if len(gn.broadcastList) == 0 {
+		return nil
+	}
+
+	retransmitLimit := gossipRetransmitMult * int(math.Ceil(math.Log10(float64(len(gn.mapMember)+2))))
+	sort.SliceStable(gn.broadcastList, func(i, j int) bool {
+		return gn.broadcastList[i].transmit < gn.broadcastList[j].transmit
+	})
+
+	var updates []gossipUpdate
+	size := 0
+	for _, b := range gn.broadcastList {
+		updateSize := gossipUpdateSize(&b.update)
+		if len(updates) > 0 && size+updateSize > gossipMaxPiggyback {
+			break
+		}
+
+		updates = append(updates, b.update)
+		size += updateSize
+		b.transmit++
+	}
+
+	gn.broadcastList = slices.DeleteFunc(gn.broadcastList, func(b *gossipBroadcast) bool {
+		return b.transmit >= retransmitLimit
+	})
+
+	return updates
+}
+
+func (gd *GossipDiscoveryService) setNode(gn *gossipNetwork, byteNode []byte) {
+	if len(byteNode) == 0 {
+		return
+	}
+
+	var nodeInfo rpc.NodeInfo
+	err := proto.Unmarshal(byteNode, &nodeInfo)
+	if err != nil {
+		log.Errorf("Unmarshal fail,networkName:%s,err:%s", gn.name, err)
+		return
+	}
+
+	//结点信息无变化时不重复通知
+	if lastNodeInfo, ok := gn.mapNotified[nodeInfo.NodeId]; ok == true && lastNodeInfo == string(byteNode) {
+		return
+	}
+
+	gn.mapNotified[nodeInfo.NodeId] = string(byteNode)
+	gd.setNodeInfo(gn.name, &nodeInfo)
+}
+
+// delNode 结点在所有网络中都失败后才移除
+func (gd *GossipDiscoveryService) delNode(gn *gossipNetwork, nodeId string) {
+	delete(gn.mapNotified, nodeId)
+	for _, network := range gd.mapNetwork {
+		if _, ok := network.mapNotified[nodeId]; ok == true {
+			return
+		}
+	}
+
+	gd.funDelNode(nodeId)
+}
+
+func (gd *GossipDiscoveryService) setNodeInfo(networkName string, nodeInfo *rpc.NodeInfo) bool {
+	if nodeInfo == nil || nodeInfo.Private == true || nodeInfo.NodeId == gd.localNodeId {
+		return false
+	}
+
+	//筛选关注的服务
+	var discoverServiceSlice = make([]string, 0, 24)
+	for _, pubService := range nodeInfo.PublicServiceList {
+		if cluster.CanDiscoveryService(networkName, pubService, nodeInfo.Labels) == true {
+			discoverServiceSlice = append(discoverServiceSlice, pubService)
+		}
+	}
+
+	if len(discoverServiceSlice) == 0 {
+		return false
+	}
+
+	var nInfo NodeInfo
+	nInfo.ServiceList = discoverServiceSlice
+	nInfo.PublicServiceList = discoverServiceSlice
+	nInfo.NodeId = nodeInfo.NodeId
+	nInfo.ListenAddr = nodeInfo.ListenAddr
+	nInfo.MaxRpcParamLen = nodeInfo.MaxRpcParamLen
+	nInfo.Retire = nodeInfo.Retire
+	nInfo.Private = nodeInfo.Private
+	nInfo.Labels = nodeInfo.Labels
+	nInfo.ServiceVersion = nodeInfo.ServiceVersion
+	nInfo.UnhealthyServiceList = nodeInfo.UnhealthyServiceList
+	nInfo.SingletonServiceList = nodeInfo.SingletonServiceList
+	nInfo.ServiceLeader = nodeInfo.ServiceLeader
+	nInfo.NetworkName = networkName
+
+	gd.funSetNode(&nInfo)
+
+	return true
+}
+
+func (gd *GossipDiscoveryService) OnNodeDisconnect(nodeId string) {
+	//将Discard结点清理
+	cluster.DiscardNode(nodeId)
+}
@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"github.com/duanhf2012/origin/v2/event"
+	"github.com/duanhf2012/origin/v2/service"
+	"slices"
+)
+
+// GetUnhealthyServiceList 获取本结点不健康的服务列表
+func (cls *Cluster) GetUnhealthyServiceList() []string {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	return cls.localNodeInfo.UnhealthyServiceList
+}
+
+// setServiceHealth 本结点服务健康状态变化，更新结点信息并通过服务发现发布
+func (cls *Cluster) setServiceHealth(serviceName string, err error) {
+	healthy := err == nil
+
+	cls.locker.Lock()
+	unhealthyList := cls.localNodeInfo.UnhealthyServiceList
+	idx := slices.Index(unhealthyList, serviceName)
+	if (idx == -1) == healthy {
+		cls.locker.Unlock()
+		return
+	}
+
+	//重新分配切片，避免影响正在读取的调用方
+	if healthy {
+		unhealthyList = slices.Delete(slices.Clone(unhealthyList), idx, idx+1)
+	} else {
+		unhealthyList = append(slices.Clone(unhealthyList), serviceName)
+	}
+
+	cls.localNodeInfo.UnhealthyServiceList = unhealthyList
+	cls.syncLocalNodeRpcInfo()
+	cls.locker.Unlock()
+
+	cls.TriggerHealthEvent(healthy, cls.localNodeInfo.NodeId, []string{serviceName})
+	cls.notifyLocalNodeInfoChanged()
+}
+
+// notifyLocalNodeInfoChanged 通知服务发现重新发布本结点信息
+func (cls *Cluster) notifyLocalNodeInfoChanged() {
+	discoveryService, ok := cls.serviceDiscovery.(service.IModule)
+	if ok == false {
+		return
+	}
+
+	ev := event.NewEvent()
+	ev.Type = event.Sys_Event_LocalNodeInfo
+	discoveryService.NotifyEvent(ev)
+}
+
+func (cls *Cluster) TriggerHealthEvent(healthy bool, nodeId string, serviceName []string) {
+	var eventData service.ServiceHealthEvent
+	eventData.Healthy = healthy
+	eventData.NodeId = nodeId
+	eventData.ServiceName = serviceName
+
+	cls.NotifyAllService(&eventData)
+}
+
+// triggerHealthChange 对比结点更新前后的不健康服务，通知健康状态变化
+func (cls *Cluster) triggerHealthChange(nodeId string, lastUnhealthyList []string, unhealthyList []string) {
+	var recoverList, failList []string
+	for _, serviceName := range lastUnhealthyList {
+		if slices.Contains(unhealthyList, serviceName) == false {
+			recoverList = append(recoverList, serviceName)
+		}
+	}
+
+	for _, serviceName := range unhealthyList {
+		if slices.Contains(lastUnhealthyList, serviceName) == false {
+			failList = append(failList, serviceName)
+		}
+	}
+
+	if len(recoverList) > 0 {
+		cls.TriggerHealthEvent(true, nodeId, recoverList)
+	}
+
+	if len(failList) > 0 {
+		cls.TriggerHealthEvent(false, nodeId, failList)
+	}
+}
+
+func (nodeInfo *NodeInfo) isServiceHealthy(serviceName string) bool {
+	return slices.Contains(nodeInfo.UnhealthyServiceList, serviceName) == false
+}
@@ -0,0 +1,142 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/service"
+	"slices"
+	"strings"
+)
+
+// ReadServiceCfg 读取服务配置，优先使用配置中心下发的配置，其次是NodeService中本结点的配置，用于运行期间安装的服务
+func (cls *Cluster) ReadServiceCfg(serviceName string) interface{} {
+	cls.cfgLocker.Lock()
+	defer cls.cfgLocker.Unlock()
+
+	serviceCfg, ok := cls.mapCenterServiceCfg[serviceName]
+	if ok == false {
+		serviceCfg = cls.readFileServiceCfg(serviceName)
+	}
+
+	//记录为当前生效的配置，配置中心下发时用于对比
+	cls.localServiceCfg[serviceName] = serviceCfg
+	return serviceCfg
+}
+
+// isSystemService 服务发现与结点管理服务不允许运行期间卸载
+func (cls *Cluster) isSystemService(serviceName string) bool {
+	if _, ok := cls.mapBridgeService[serviceName]; ok == true || serviceName == ClusterAdminName {
+		return true
+	}
+
+	discoveryService, ok := cls.serviceDiscovery.(service.IService)
+	return ok == true && discoveryService.GetName() == serviceName
+}
+
+// GetPublicServiceList 获取本结点对外公开的服务列表
+func (cls *Cluster) GetPublicServiceList() []string {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	return cls.localNodeInfo.PublicServiceList
+}
+
+// AddLocalService 运行期间在本结点新增服务，并通过服务发现发布
+// serviceName格式与NodeList中ServiceList一致，如"BattleService2:BattleService"，以_开头表示不公开
+func (cls *Cluster) AddLocalService(serviceName string) error {
+	bPublic := strings.HasPrefix(serviceName, "_") == false && cls.localNodeInfo.Private == false
+	serviceName = strings.TrimLeft(serviceName, "_")
+
+	name, templateServiceName, _ := strings.Cut(serviceName, ":")
+	localNodeId := cls.localNodeInfo.NodeId
+
+	cls.locker.Lock()
+	if _, ok := cls.mapServiceNode[name][localNodeId]; ok == true {
+		cls.locker.Unlock()
+		return fmt.Errorf("duplicate service %s is configured in node %s", name, localNodeId)
+	}
+
+	//重新分配切片，避免影响正在读取的调用方
+	cls.localNodeInfo.ServiceList = append(slices.Clone(cls.localNodeInfo.ServiceList), serviceName)
+	if bPublic == true {
+		cls.localNodeInfo.PublicServiceList = append(slices.Clone(cls.localNodeInfo.PublicServiceList), serviceName)
+	}
+	cls.syncLocalNodeRpcInfo()
+
+	if templateServiceName != "" {
+		if _, ok := cls.mapTemplateServiceNode[templateServiceName]; ok == false {
+			cls.mapTemplateServiceNode[templateServiceName] = map[string]struct{}{}
+		}
+		cls.mapTemplateServiceNode[templateServiceName][name] = struct{}{}
+	}
+
+	if _, ok := cls.mapServiceNode[name]; ok == false {
+		cls.mapServiceNode[name] = make(map[string]struct{}, 1)
+	}
+	cls.mapServiceNode[name][localNodeId] = struct{}{}
+	cls.locker.Unlock()
+
+	if bPublic == true {
+		cls.notifyLocalNodeInfoChanged()
+	}
+
+	return nil
+}
+
+// RemoveLocalService 运行期间移除本结点的服务，并通知服务发现不再发布
+func (cls *Cluster) RemoveLocalService(serviceName string) error {
+	if cls.isSystemService(serviceName) {
+		return fmt.Errorf("system service %s cannot be removed", serviceName)
+	}
+
+	isService := func(s string) bool {
+		name, _, _ := strings.Cut(s, ":")
+		return name == serviceName
+	}
+
+	cls.locker.Lock()
+	idx := slices.IndexFunc(cls.localNodeInfo.ServiceList, isService)
+	if idx == -1 {
+		cls.locker.Unlock()
+		return fmt.Errorf("service %s is not found in node %s", serviceName, cls.localNodeInfo.NodeId)
+	}
+
+	_, templateServiceName, _ := strings.Cut(cls.localNodeInfo.ServiceList[idx], ":")
+	bPublic := slices.ContainsFunc(cls.localNodeInfo.PublicServiceList, isService)
+	cls.localNodeInfo.ServiceList = slices.DeleteFunc(slices.Clone(cls.localNodeInfo.ServiceList), isService)
+	cls.localNodeInfo.PublicServiceList = slices.DeleteFunc(slices.Clone(cls.localNodeInfo.PublicServiceList), isService)
+	cls.localNodeInfo.UnhealthyServiceList = slices.DeleteFunc(slices.Clone(cls.localNodeInfo.UnhealthyServiceList), isService)
+	cls.syncLocalNodeRpcInfo()
+
+	if templateServiceName != "" {
+		delete(cls.mapTemplateServiceNode[templateServiceName], serviceName)
+		if len(cls.mapTemplateServiceNode[templateServiceName]) == 0 {
+			delete(cls.mapTemplateServiceNode, templateServiceName)
+		}
+	}
+
+	delete(cls.mapServiceNode[serviceName], cls.localNodeInfo.NodeId)
+	if len(cls.mapServiceNode[serviceName]) == 0 {
+		delete(cls.mapServiceNode, serviceName)
+	}
+	cls.locker.Unlock()
+
+	cls.UnRegRpcEvent(serviceName)
+	if bPublic == true {
+		cls.notifyLocalNodeInfoChanged()
+	}
+
+	return nil
+}
+
+// syncLocalNodeRpcInfo 同步本结点信息到mapRpc中，调用方需加锁
+func (cls *Cluster) syncLocalNodeRpcInfo() {
+	nodeRpc, ok := cls.mapRpc[cls.localNodeInfo.NodeId]
+	if ok == false {
+		return
+	}
+
+	nodeRpc.nodeInfo.ServiceList = cls.localNodeInfo.ServiceList
+	nodeRpc.nodeInfo.PublicServiceList = cls.localNodeInfo.PublicServiceList
+	nodeRpc.nodeInfo.UnhealthyServiceList = cls.localNodeInfo.UnhealthyServiceList
+	nodeRpc.nodeInfo.ServiceLeader = cls.localNodeInfo.ServiceLeader
+}
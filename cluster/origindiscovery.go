@@ -2,6 +2,9 @@ package cluster
 
 import (
 	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/config"
+	"github.com/duanhf2012/origin/v2/event"
 	"github.com/duanhf2012/origin/v2/log"
 	"github.com/duanhf2012/origin/v2/rpc"
 	"github.com/duanhf2012/origin/v2/service"
@@ -17,6 +20,10 @@ const SubServiceDiscover = OriginDiscoveryClientName + ".RPC_SubServiceDiscover"
 const NodeRetireRpcMethod = OriginDiscoveryMasterName + ".RPC_NodeRetire"
 const RpcPingMethod = OriginDiscoveryMasterName + ".RPC_Ping"
 const UnRegServiceDiscover = OriginDiscoveryMasterName + ".RPC_UnRegServiceDiscover"
+const MasterSyncMethod = OriginDiscoveryMasterName + ".RPC_MasterSync"
+
+// 超过masterSyncTimeoutTimes个同步周期未收到其他Master的同步，清理该Master同步过来的结点
+const masterSyncTimeoutTimes = 3
 
 type OriginDiscoveryMaster struct {
 	service.Service
@@ -24,7 +31,16 @@ type OriginDiscoveryMaster struct {
 	mapNodeInfo map[string]struct{}
 	nodeInfo    []*rpc.NodeInfo
 
+	mapNodeSource     map[string]map[string]struct{} //map[nodeId]map[masterNodeId] 结点信息的来源Master,本Master表示直接注册
+	mapMasterSyncTime map[string]time.Time           //map[masterNodeId]最后一次收到同步的时间
+
 	nsTTL nodeSetTTL
+
+	configData   *ConfigData          //配置中心为Origin时，最近一次推送的配置
+	mapFileStamp map[string]fileStamp //配置中心为Origin时，本地配置文件的修改信息
+
+	startTime         time.Time
+	mapSingletonLease map[string]singletonLease //map[serviceName]授予的单例服务租约
 }
 
 type OriginDiscoveryClient struct {
@@ -37,6 +53,10 @@ type OriginDiscoveryClient struct {
 	mapDiscovery map[string]map[string][]string //map[masterNodeId]map[nodeId]struct{}
 	bRetire      bool
 	isRegisterOk bool
+
+	singletonState       int32
+	singletonServiceList []string
+	mapSingletonLease    map[string]map[string]time.Time //map[serviceName]map[masterNodeId]本结点认为租约有效的截止时间
 }
 
 var masterService OriginDiscoveryMaster
@@ -56,18 +76,23 @@ func (ds *OriginDiscoveryMaster) isRegNode(nodeId string) bool {
 	return ok
 }
 
-func (ds *OriginDiscoveryMaster) updateNodeInfo(nInfo *rpc.NodeInfo) {
+func (ds *OriginDiscoveryMaster) updateNodeInfo(nInfo *rpc.NodeInfo) bool {
 	if _, ok := ds.mapNodeInfo[nInfo.NodeId]; ok == false {
-		return
+		return false
 	}
 
 	nodeInfo := proto.Clone(nInfo).(*rpc.NodeInfo)
 	for i := 0; i < len(ds.nodeInfo); i++ {
 		if ds.nodeInfo[i].NodeId == nodeInfo.NodeId {
+			if proto.Equal(ds.nodeInfo[i], nodeInfo) {
+				return false
+			}
 			ds.nodeInfo[i] = nodeInfo
 			break
 		}
 	}
+
+	return true
 }
 
 func (ds *OriginDiscoveryMaster) addNodeInfo(nInfo *rpc.NodeInfo) {
@@ -99,10 +124,50 @@ func (ds *OriginDiscoveryMaster) removeNodeInfo(nodeId string) {
 
 	ds.nsTTL.removeNode(nodeId)
 	delete(ds.mapNodeInfo, nodeId)
+	delete(ds.mapNodeSource, nodeId)
+}
+
+// addNodeSource 记录结点信息来源，返回结点信息是否新增或者有变化
+func (ds *OriginDiscoveryMaster) addNodeSource(nInfo *rpc.NodeInfo, masterNodeId string) bool {
+	if len(nInfo.PublicServiceList) == 0 {
+		return false
+	}
+
+	if _, ok := ds.mapNodeSource[nInfo.NodeId]; ok == false {
+		ds.mapNodeSource[nInfo.NodeId] = map[string]struct{}{}
+	}
+	ds.mapNodeSource[nInfo.NodeId][masterNodeId] = struct{}{}
+
+	if ds.isRegNode(nInfo.NodeId) == false {
+		ds.addNodeInfo(nInfo)
+		return true
+	}
+
+	return ds.updateNodeInfo(nInfo)
+}
+
+// removeNodeSource 删除结点信息来源，所有来源都不存在时返回true
+func (ds *OriginDiscoveryMaster) removeNodeSource(nodeId string, masterNodeId string) bool {
+	mapSource, ok := ds.mapNodeSource[nodeId]
+	if ok == false {
+		return ds.isRegNode(nodeId)
+	}
+
+	delete(mapSource, masterNodeId)
+	return len(mapSource) == 0
+}
+
+func (ds *OriginDiscoveryMaster) isLocalSource(nodeId string) bool {
+	_, ok := ds.mapNodeSource[nodeId][cluster.GetLocalNodeInfo().NodeId]
+	return ok
 }
 
 func (ds *OriginDiscoveryMaster) OnInit() error {
 	ds.mapNodeInfo = make(map[string]struct{}, 20)
+	ds.mapNodeSource = make(map[string]map[string]struct{}, 20)
+	ds.mapMasterSyncTime = make(map[string]time.Time, len(cluster.GetOriginDiscovery().MasterNodeList))
+	ds.mapSingletonLease = map[string]singletonLease{}
+	ds.startTime = time.Now()
 	ds.RegNodeConnListener(ds)
 	ds.RegNatsConnListener(ds)
 
@@ -137,13 +202,241 @@ func (ds *OriginDiscoveryMaster) OnStart() {
 	localNodeInfo := cluster.GetLocalNodeInfo()
 	nodeInfo.NodeId = localNodeInfo.NodeId
 	nodeInfo.ListenAddr = localNodeInfo.ListenAddr
-	nodeInfo.PublicServiceList = localNodeInfo.PublicServiceList
+	nodeInfo.PublicServiceList = cluster.GetPublicServiceList()
 	nodeInfo.MaxRpcParamLen = localNodeInfo.MaxRpcParamLen
 	nodeInfo.Private = localNodeInfo.Private
 	nodeInfo.Retire = localNodeInfo.Retire
-	ds.addNodeInfo(&nodeInfo)
+	nodeInfo.Labels = localNodeInfo.Labels
+	nodeInfo.ServiceVersion = localNodeInfo.ServiceVersion
+	nodeInfo.UnhealthyServiceList = cluster.GetUnhealthyServiceList()
+	nodeInfo.SingletonServiceList = localNodeInfo.SingletonServiceList
+	nodeInfo.ServiceLeader = cluster.GetServiceLeader()
+	ds.addNodeSource(&nodeInfo, localNodeInfo.NodeId)
 
 	ds.checkTTL()
+
+	//从其他Master恢复结点信息,并定时与其他Master互相同步
+	ds.syncAllMaster()
+	ds.NewTicker(time.Duration(cluster.GetOriginDiscovery().TTLSecond)*time.Second, func(t *timer.Ticker) {
+		ds.checkMasterSyncTimeout()
+		ds.syncAllMaster()
+	})
+
+	ds.watchConfig()
+}
+
+// watchConfig 配置中心为Origin时，监听本地配置文件，变化后推送给所有结点
+func (ds *OriginDiscoveryMaster) watchConfig() {
+	if cluster.isOriginConfigCenter() == false {
+		return
+	}
+
+	var err error
+	ds.mapFileStamp, err = readClusterFileStamp()
+	if err != nil {
+		log.Errorf("config center cannot watch cluster path %s:%s", config.GetClusterPath(), err)
+		return
+	}
+
+	ds.NewTicker(configWatchInterval, func(t *timer.Ticker) {
+		mapFileStamp, err := readClusterFileStamp()
+		if err != nil {
+			log.Errorf("config center read cluster path %s fail:%s", config.GetClusterPath(), err)
+			return
+		}
+
+		if isFileStampChanged(ds.mapFileStamp, mapFileStamp) == false {
+			return
+		}
+		ds.mapFileStamp = mapFileStamp
+
+		configData, err := reloadConfigData()
+		if err != nil {
+			//配置有误时不推送，等待下一次修改
+			log.Errorf("config center reload config fail:%s", err)
+			return
+		}
+
+		ds.pushConfig(configData)
+	})
+}
+
+// pushConfig 应用到本结点并推送给所有已注册的结点
+func (ds *OriginDiscoveryMaster) pushConfig(configData *ConfigData) {
+	log.Infof("config center push config,version:%d", configData.Version)
+	ds.configData = configData
+
+	err := cluster.UpdateConfig(configData)
+	if err != nil {
+		log.Errorf("config center update local config fail:%s", err)
+	}
+	ds.RpcCastGo(UpdateConfigMethod, configData)
+}
+
+func (ds *OriginDiscoveryMaster) isPeerMaster(nodeId string) bool {
+	return nodeId != cluster.GetLocalNodeInfo().NodeId && cluster.IsOriginMasterDiscoveryNode(nodeId)
+}
+
+// getLocalSourceNodeInfo 获取直接注册到本Master的结点信息
+func (ds *OriginDiscoveryMaster) getLocalSourceNodeInfo() []*rpc.NodeInfo {
+	nodeInfoList := make([]*rpc.NodeInfo, 0, len(ds.nodeInfo))
+	for _, nodeInfo := range ds.nodeInfo {
+		if ds.isLocalSource(nodeInfo.NodeId) {
+			nodeInfoList = append(nodeInfoList, nodeInfo)
+		}
+	}
+
+	return nodeInfoList
+}
+
+func (ds *OriginDiscoveryMaster) syncAllMaster() {
+	masterNodeList := cluster.GetOriginDiscovery().MasterNodeList
+	for i := 0; i < len(masterNodeList); i++ {
+		if ds.isPeerMaster(masterNodeList[i].NodeId) == false {
+			continue
+		}
+
+		ds.syncMaster(masterNodeList[i].NodeId)
+	}
+}
+
+// syncMaster 将本Master直接注册的结点全量同步给其他Master,并合并对方返回的全量信息
+func (ds *OriginDiscoveryMaster) syncMaster(masterNodeId string) {
+	var req rpc.MasterSyncReq
+	req.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
+	req.IsFull = true
+	req.NodeInfo = ds.getLocalSourceNodeInfo()
+
+	_, err := ds.AsyncCallNodeWithTimeout(3*time.Second, masterNodeId, MasterSyncMethod, &req, func(res *rpc.MasterSyncReq, err error) {
+		if err != nil {
+			log.Debugf("sync master fail,masterNodeId:%s,err:%s", masterNodeId, err)
+			return
+		}
+
+		ds.mergeMasterSync(res)
+	})
+
+	if err != nil {
+		log.Debugf("sync master fail,masterNodeId:%s,err:%s", masterNodeId, err)
+	}
+}
+
+// notifyMasterChange 本Master直接注册的结点有变化时，增量通知其他Master
+func (ds *OriginDiscoveryMaster) notifyMasterChange(nodeInfo *rpc.NodeInfo, delNodeId string) {
+	var req rpc.MasterSyncReq
+	req.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
+	if nodeInfo != nil {
+		req.NodeInfo = append(req.NodeInfo, nodeInfo)
+	}
+	if delNodeId != rpc.NodeIdNull {
+		req.DelNodeId = append(req.DelNodeId, delNodeId)
+	}
+
+	masterNodeList := cluster.GetOriginDiscovery().MasterNodeList
+	for i := 0; i < len(masterNodeList); i++ {
+		if ds.isPeerMaster(masterNodeList[i].NodeId) == false || cluster.IsNodeConnected(masterNodeList[i].NodeId) == false {
+			continue
+		}
+
+		ds.GoNode(masterNodeList[i].NodeId, MasterSyncMethod, &req)
+	}
+}
+
+// mergeMasterSync 合并其他Master同步过来的结点信息
+func (ds *OriginDiscoveryMaster) mergeMasterSync(req *rpc.MasterSyncReq) {
+	if ds.isPeerMaster(req.MasterNodeId) == false {
+		return
+	}
+	ds.mapMasterSyncTime[req.MasterNodeId] = time.Now()
+
+	var delNodeIdList []string
+	if req.IsFull == true {
+		mapNodeId := make(map[string]struct{}, len(req.NodeInfo))
+		for _, nodeInfo := range req.NodeInfo {
+			mapNodeId[nodeInfo.NodeId] = struct{}{}
+		}
+
+		for nodeId, mapSource := range ds.mapNodeSource {
+			if _, ok := mapSource[req.MasterNodeId]; ok == false {
+				continue
+			}
+
+			if _, ok := mapNodeId[nodeId]; ok == false {
+				delNodeIdList = append(delNodeIdList, nodeId)
+			}
+		}
+	}
+	delNodeIdList = append(delNodeIdList, req.DelNodeId...)
+
+	for _, nodeId := range delNodeIdList {
+		if ds.removeNodeSource(nodeId, req.MasterNodeId) == true {
+			ds.removeNode(nodeId)
+		}
+	}
+
+	for _, nodeInfo := range req.NodeInfo {
+		if nodeInfo.NodeId == cluster.GetLocalNodeInfo().NodeId {
+			continue
+		}
+
+		if ds.addNodeSource(nodeInfo, req.MasterNodeId) == false {
+			continue
+		}
+
+		log.Debugf("sync node from master,masterNodeId:%s,nodeId:%s", req.MasterNodeId, nodeInfo.NodeId)
+		var notifyDiscover rpc.SubscribeDiscoverNotify
+		notifyDiscover.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
+		notifyDiscover.NodeInfo = append(notifyDiscover.NodeInfo, nodeInfo)
+		ds.RpcCastGo(SubServiceDiscover, &notifyDiscover)
+	}
+}
+
+// checkMasterSyncTimeout 长时间未同步的Master，清理由它同步过来的结点
+func (ds *OriginDiscoveryMaster) checkMasterSyncTimeout() {
+	timeout := time.Duration(cluster.GetOriginDiscovery().TTLSecond) * time.Second * masterSyncTimeoutTimes
+	for masterNodeId, syncTime := range ds.mapMasterSyncTime {
+		if time.Since(syncTime) < timeout {
+			continue
+		}
+
+		log.Infof("master sync timeout,masterNodeId:%s", masterNodeId)
+
+		//以空的全量同步清理该Master同步过来的结点
+		var req rpc.MasterSyncReq
+		req.MasterNodeId = masterNodeId
+		req.IsFull = true
+		ds.mergeMasterSync(&req)
+		delete(ds.mapMasterSyncTime, masterNodeId)
+	}
+}
+
+// removeNode 所有Master都不存在该结点时，删除并广播
+func (ds *OriginDiscoveryMaster) removeNode(nodeId string) {
+	ds.removeNodeInfo(nodeId)
+
+	//主动删除已经存在的结点,确保先断开，再连接
+	var notifyDiscover rpc.SubscribeDiscoverNotify
+	notifyDiscover.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
+	notifyDiscover.DelNodeId = nodeId
+
+	//删除结点
+	cluster.DelNode(nodeId)
+
+	//无注册过的结点不广播，避免非当前Master网络中的连接断开时通知到本网络
+	ds.CastGo(SubServiceDiscover, &notifyDiscover)
+}
+
+func (ds *OriginDiscoveryMaster) RPC_MasterSync(req *rpc.MasterSyncReq, res *rpc.MasterSyncReq) error {
+	if ds.isPeerMaster(req.MasterNodeId) == false {
+		return fmt.Errorf("node %s is not a discovery master", req.MasterNodeId)
+	}
+
+	ds.mergeMasterSync(req)
+
+	res.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
+	res.IsFull = true
+	res.NodeInfo = ds.getLocalSourceNodeInfo()
+	return nil
 }
 
 func (ds *OriginDiscoveryMaster) OnNatsConnected() {
@@ -159,6 +452,11 @@ func (ds *OriginDiscoveryMaster) OnNatsDisconnect() {
 }
 
 func (ds *OriginDiscoveryMaster) OnNodeConnected(nodeId string) {
+	//其他Master连接上时，立即互相同步
+	if ds.isPeerMaster(nodeId) {
+		ds.syncMaster(nodeId)
+	}
+
 	var notifyDiscover rpc.SubscribeDiscoverNotify
 	notifyDiscover.IsFull = true
 	notifyDiscover.NodeInfo = ds.nodeInfo
@@ -168,22 +466,19 @@ func (ds *OriginDiscoveryMaster) OnNodeConnected(nodeId string) {
 }
 
 func (ds *OriginDiscoveryMaster) OnNodeDisconnect(nodeId string) {
-	if ds.isRegNode(nodeId) == false {
+	if ds.isRegNode(nodeId) == false || ds.isLocalSource(nodeId) == false {
 		return
 	}
 
-	ds.removeNodeInfo(nodeId)
-
-	//主动删除已经存在的结点,确保先断开，再连接
-	var notifyDiscover rpc.SubscribeDiscoverNotify
-	notifyDiscover.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
-	notifyDiscover.DelNodeId = nodeId
+	ds.nsTTL.removeNode(nodeId)
+	ds.notifyMasterChange(nil, nodeId)
 
-	//删除结点
-	cluster.DelNode(nodeId)
+	//其他Master仍然存在该结点时，保留结点信息
+	if ds.removeNodeSource(nodeId, cluster.GetLocalNodeInfo().NodeId) == false {
+		return
+	}
 
-	//无注册过的结点不广播，避免非当前Master网络中的连接断开时通知到本网络
-	ds.CastGo(SubServiceDiscover, &notifyDiscover)
+	ds.removeNode(nodeId)
 }
 
 func (ds *OriginDiscoveryMaster) RpcCastGo(serviceMethod string, args interface{}) {
@@ -204,6 +499,7 @@ func (ds *OriginDiscoveryMaster) RPC_Ping(req *rpc.Ping, res *rpc.Pong) error {
 
 	res.Ok = true
 	ds.nsTTL.addAndRefreshNode(req.NodeId)
+	cluster.refreshNodeHeartbeat(req.NodeId, time.Now())
 	return nil
 }
 
@@ -211,6 +507,9 @@ func (ds *OriginDiscoveryMaster) RPC_NodeRetire(req *rpc.NodeRetireReq, _ *rpc.E
 	log.Debugf("node is retire,NodeId:%s,retire:%t", req.NodeInfo.NodeId, req.NodeInfo.Retire)
 
 	ds.updateNodeInfo(req.NodeInfo)
+	if ds.isLocalSource(req.NodeInfo.NodeId) {
+		ds.notifyMasterChange(req.NodeInfo, rpc.NodeIdNull)
+	}
 
 	var notifyDiscover rpc.SubscribeDiscoverNotify
 	notifyDiscover.MasterNodeId = cluster.GetLocalNodeInfo().NodeId
@@ -239,8 +538,9 @@ func (ds *OriginDiscoveryMaster) RPC_RegServiceDiscover(req *rpc.RegServiceDisco
 	notifyDiscover.NodeInfo = append(notifyDiscover.NodeInfo, req.NodeInfo)
 	ds.RpcCastGo(SubServiceDiscover, &notifyDiscover)
 
-	//存入本地
-	ds.addNodeInfo(req.NodeInfo)
+	//存入本地,并同步给其他Master
+	ds.addNodeSource(req.NodeInfo, cluster.GetLocalNodeInfo().NodeId)
+	ds.notifyMasterChange(req.NodeInfo, rpc.NodeIdNull)
 
 	//初始化结点信息
 	var nodeInfo NodeInfo
@@ -251,12 +551,23 @@ func (ds *OriginDiscoveryMaster) RPC_RegServiceDiscover(req *rpc.RegServiceDisco
 	nodeInfo.ListenAddr = req.NodeInfo.ListenAddr
 	nodeInfo.MaxRpcParamLen = req.NodeInfo.MaxRpcParamLen
 	nodeInfo.Retire = req.NodeInfo.Retire
+	nodeInfo.Labels = req.NodeInfo.Labels
+	nodeInfo.ServiceVersion = req.NodeInfo.ServiceVersion
+	nodeInfo.UnhealthyServiceList = req.NodeInfo.UnhealthyServiceList
+	nodeInfo.SingletonServiceList = req.NodeInfo.SingletonServiceList
+	nodeInfo.ServiceLeader = req.NodeInfo.ServiceLeader
 
 	//主动删除已经存在的结点,确保先断开，再连接
 	cluster.serviceDiscoveryDelNode(nodeInfo.NodeId)
 
 	//加入到本地Cluster模块中，将连接该结点
 	cluster.serviceDiscoverySetNodeInfo(&nodeInfo)
+	cluster.refreshNodeHeartbeat(nodeInfo.NodeId, time.Now())
+
+	//配置已经修改过时，新注册的结点同步最新配置
+	if ds.configData != nil && nodeInfo.NodeId != cluster.GetLocalNodeInfo().NodeId {
+		ds.GoNode(nodeInfo.NodeId, UpdateConfigMethod, ds.configData)
+	}
 
 	res.IsFull = true
 	res.NodeInfo = ds.nodeInfo
@@ -273,6 +584,7 @@ func (ds *OriginDiscoveryMaster) RPC_UnRegServiceDiscover(req *rpc.UnRegServiceD
 func (dc *OriginDiscoveryClient) OnInit() error {
 	dc.RegNodeConnListener(dc)
 	dc.RegNatsConnListener(dc)
+	dc.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_LocalNodeInfo, dc.GetEventHandler(), dc.OnLocalNodeInfo)
 
 	dc.mapDiscovery = map[string]map[string][]string{}
 	//dc.mapMasterNetwork = map[string]string{}
@@ -325,8 +637,9 @@ func (dc *OriginDiscoveryClient) ping() {
 		interval = time.Second
 	}
 
+	//Tcp模式下由连接断开发现结点失效并重新注册，Ping只用于记录心跳时间
 	dc.NewTicker(interval, func(t *timer.Ticker) {
-		if cluster.IsNatsMode() == false || dc.isRegisterOk == false {
+		if dc.isRegisterOk == false {
 			return
 		}
 		var ping rpc.Ping
@@ -339,7 +652,14 @@ func (dc *OriginDiscoveryClient) ping() {
 
 			masterNodeId := masterNodes[i].NodeId
 			dc.AsyncCallNodeWithTimeout(3*time.Second, masterNodeId, RpcPingMethod, &ping, func(empty *rpc.Pong, err error) {
-				if err == nil && empty.Ok == false {
+				if err != nil {
+					return
+				}
+
+				if empty.Ok == true {
+					cluster.refreshNodeHeartbeat(masterNodeId, time.Now())
+					cluster.refreshLocalHeartbeat()
+				} else if cluster.IsNatsMode() == true {
 					//断开master重
 					dc.regServiceDiscover(masterNodeId)
 				}
@@ -352,6 +672,7 @@ func (dc *OriginDiscoveryClient) OnStart() {
 	//2.添加并连接发现主结点
 	dc.addDiscoveryMaster()
 	dc.ping()
+	dc.keepSingletonLease()
 }
 
 func (dc *OriginDiscoveryClient) addDiscoveryMaster() {
@@ -412,6 +733,11 @@ func (dc *OriginDiscoveryClient) RPC_SubServiceDiscover(req *rpc.SubscribeDiscov
 				nInfo.MaxRpcParamLen = nodeInfo.MaxRpcParamLen
 				nInfo.Retire = nodeInfo.Retire
 				nInfo.Private = nodeInfo.Private
+				nInfo.Labels = nodeInfo.Labels
+				nInfo.ServiceVersion = nodeInfo.ServiceVersion
+				nInfo.UnhealthyServiceList = nodeInfo.UnhealthyServiceList
+				nInfo.SingletonServiceList = nodeInfo.SingletonServiceList
+				nInfo.ServiceLeader = nodeInfo.ServiceLeader
 
 				mapNodeInfo[nodeInfo.NodeId] = nInfo
 			}
@@ -434,10 +760,12 @@ func (dc *OriginDiscoveryClient) RPC_SubServiceDiscover(req *rpc.SubscribeDiscov
 		willDelNodeId = append(willDelNodeId, req.DelNodeId)
 	}
 
-	//删除不必要的结点
+	//删除不必要的结点，其他Master仍然存在该结点时不删除
 	for _, nodeId := range willDelNodeId {
-		dc.funDelNode(nodeId)
 		dc.removeMasterNode(req.MasterNodeId, nodeId)
+		if dc.findNodeId(nodeId) == false {
+			dc.funDelNode(nodeId)
+		}
 	}
 
 	//设置新结点
@@ -478,7 +806,15 @@ func (dc *OriginDiscoveryClient) OnRelease() {
 
 func (dc *OriginDiscoveryClient) OnRetire() {
 	dc.bRetire = true
+	dc.notifyNodeInfo()
+}
 
+// OnLocalNodeInfo 本结点信息变化(如服务健康状态)，同步给所有的Master
+func (dc *OriginDiscoveryClient) OnLocalNodeInfo(ev event.IEvent) {
+	dc.notifyNodeInfo()
+}
+
+func (dc *OriginDiscoveryClient) notifyNodeInfo() {
 	masterNodeList := cluster.GetOriginDiscovery()
 	for i := 0; i < len(masterNodeList.MasterNodeList); i++ {
 		var nodeRetireReq rpc.NodeRetireReq
@@ -487,9 +823,14 @@ func (dc *OriginDiscoveryClient) OnRetire() {
 		nodeRetireReq.NodeInfo.NodeId = cluster.localNodeInfo.NodeId
 		nodeRetireReq.NodeInfo.ListenAddr = cluster.localNodeInfo.ListenAddr
 		nodeRetireReq.NodeInfo.MaxRpcParamLen = cluster.localNodeInfo.MaxRpcParamLen
-		nodeRetireReq.NodeInfo.PublicServiceList = cluster.localNodeInfo.PublicServiceList
+		nodeRetireReq.NodeInfo.PublicServiceList = cluster.GetPublicServiceList()
 		nodeRetireReq.NodeInfo.Retire = dc.bRetire
 		nodeRetireReq.NodeInfo.Private = cluster.localNodeInfo.Private
+		nodeRetireReq.NodeInfo.Labels = cluster.localNodeInfo.Labels
+		nodeRetireReq.NodeInfo.ServiceVersion = cluster.localNodeInfo.ServiceVersion
+		nodeRetireReq.NodeInfo.UnhealthyServiceList = cluster.GetUnhealthyServiceList()
+		nodeRetireReq.NodeInfo.SingletonServiceList = cluster.localNodeInfo.SingletonServiceList
+		nodeRetireReq.NodeInfo.ServiceLeader = cluster.GetServiceLeader()
 
 		err := dc.GoNode(masterNodeList.MasterNodeList[i].NodeId, NodeRetireRpcMethod, &nodeRetireReq)
 		if err != nil {
@@ -518,9 +859,14 @@ func (dc *OriginDiscoveryClient) regServiceDiscover(nodeId string) {
 	req.NodeInfo.NodeId = cluster.localNodeInfo.NodeId
 	req.NodeInfo.ListenAddr = cluster.localNodeInfo.ListenAddr
 	req.NodeInfo.MaxRpcParamLen = cluster.localNodeInfo.MaxRpcParamLen
-	req.NodeInfo.PublicServiceList = cluster.localNodeInfo.PublicServiceList
+	req.NodeInfo.PublicServiceList = cluster.GetPublicServiceList()
 	req.NodeInfo.Retire = dc.bRetire
 	req.NodeInfo.Private = cluster.localNodeInfo.Private
+	req.NodeInfo.Labels = cluster.localNodeInfo.Labels
+	req.NodeInfo.ServiceVersion = cluster.localNodeInfo.ServiceVersion
+	req.NodeInfo.UnhealthyServiceList = cluster.GetUnhealthyServiceList()
+	req.NodeInfo.SingletonServiceList = cluster.localNodeInfo.SingletonServiceList
+	req.NodeInfo.ServiceLeader = cluster.GetServiceLeader()
 	log.Debug("regServiceDiscover,nodeId:%s", nodeId)
 	//向Master服务同步本Node服务信息
 	_, err := dc.AsyncCallNodeWithTimeout(3*time.Second, nodeId, RegServiceDiscover, &req, func(res *rpc.SubscribeDiscoverNotify, err error) {
@@ -532,6 +878,8 @@ func (dc *OriginDiscoveryClient) regServiceDiscover(nodeId string) {
 
 		dc.isRegisterOk = true
 		dc.RPC_SubServiceDiscover(res)
+		cluster.refreshNodeHeartbeat(nodeId, time.Now())
+		cluster.refreshLocalHeartbeat()
 	})
 
 	if err != nil {
@@ -548,7 +896,7 @@ func (dc *OriginDiscoveryClient) setNodeInfo(masterNodeId string, nodeInfo *rpc.
 	//筛选关注的服务
 	var discoverServiceSlice = make([]string, 0, 24)
 	for _, pubService := range nodeInfo.PublicServiceList {
-		if cluster.CanDiscoveryService(masterNodeId, pubService) == true {
+		if cluster.CanDiscoveryService(masterNodeId, pubService, nodeInfo.Labels) == true {
 			discoverServiceSlice = append(discoverServiceSlice, pubService)
 		}
 	}
@@ -565,6 +913,11 @@ func (dc *OriginDiscoveryClient) setNodeInfo(masterNodeId string, nodeInfo *rpc.
 	nInfo.MaxRpcParamLen = nodeInfo.MaxRpcParamLen
 	nInfo.Retire = nodeInfo.Retire
 	nInfo.Private = nodeInfo.Private
+	nInfo.Labels = nodeInfo.Labels
+	nInfo.ServiceVersion = nodeInfo.ServiceVersion
+	nInfo.UnhealthyServiceList = nodeInfo.UnhealthyServiceList
+	nInfo.SingletonServiceList = nodeInfo.SingletonServiceList
+	nInfo.ServiceLeader = nodeInfo.ServiceLeader
 
 	dc.funSetNode(&nInfo)
 
@@ -574,6 +927,25 @@ func (dc *OriginDiscoveryClient) setNodeInfo(masterNodeId string, nodeInfo *rpc.
 func (dc *OriginDiscoveryClient) OnNodeDisconnect(nodeId string) {
 	//将Discard结点清理
 	cluster.DiscardNode(nodeId)
+
+	if cluster.IsOriginMasterDiscoveryNode(nodeId) {
+		dc.removeMaster(nodeId)
+	}
+}
+
+// removeMaster Master断开时清理它发现的结点，只删除其他Master中也不存在的结点
+func (dc *OriginDiscoveryClient) removeMaster(masterNodeId string) {
+	mapNodeId, ok := dc.mapDiscovery[masterNodeId]
+	if ok == false {
+		return
+	}
+
+	delete(dc.mapDiscovery, masterNodeId)
+	for nodeId := range mapNodeId {
+		if dc.findNodeId(nodeId) == false {
+			dc.funDelNode(nodeId)
+		}
+	}
 }
 
 func (dc *OriginDiscoveryClient) InitDiscovery(localNodeId string, funDelNode FunDelNode, funSetNode FunSetNode) error {
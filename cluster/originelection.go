@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"sync/atomic"
+	"time"
+)
+
+const SingletonLeaseMethod = OriginDiscoveryMasterName + ".RPC_SingletonLease"
+
+const (
+	singletonIdle   int32 = iota //未开始竞选
+	singletonElect               //竞选中
+	singletonResign              //已退出竞选
+)
+
+type SingletonLeaseReq struct {
+	NodeId      string
+	ServiceName string
+	Resign      bool //退出竞选，释放租约
+}
+
+type SingletonLeaseRes struct {
+	Granted      bool
+	LeaderNodeId string //当前持有租约的结点
+}
+
+// singletonLease Master授予的单例服务租约
+type singletonLease struct {
+	nodeId     string
+	expireTime time.Time
+}
+
+func getSingletonLeaseTTL() time.Duration {
+	return time.Duration(cluster.GetOriginDiscovery().TTLSecond) * time.Second
+}
+
+// RPC_SingletonLease 授予单例服务的Leader租约，同一时间只授予一个结点，租约过期或持有者退出竞选后才授予其他结点
+func (ds *OriginDiscoveryMaster) RPC_SingletonLease(req *SingletonLeaseReq, res *SingletonLeaseRes) error {
+	now := time.Now()
+	lease, ok := ds.mapSingletonLease[req.ServiceName]
+	if req.Resign == true {
+		if ok == true && lease.nodeId == req.NodeId {
+			delete(ds.mapSingletonLease, req.ServiceName)
+		}
+		return nil
+	}
+
+	if ok == true && lease.nodeId != req.NodeId && now.Before(lease.expireTime) {
+		res.LeaderNodeId = lease.nodeId
+		return nil
+	}
+
+	//Master重启后不知道重启前授予的租约，等待一个租约周期后再授予
+	ttl := getSingletonLeaseTTL()
+	if ok == false && now.Sub(ds.startTime) < ttl {
+		return nil
+	}
+
+	if ok == false || lease.nodeId != req.NodeId {
+		log.Infof("grant singleton lease,serviceName:%s,nodeId:%s", req.ServiceName, req.NodeId)
+	}
+	ds.mapSingletonLease[req.ServiceName] = singletonLease{nodeId: req.NodeId, expireTime: now.Add(ttl)}
+	res.Granted = true
+	res.LeaderNodeId = req.NodeId
+	return nil
+}
+
+// ElectSingleton 向所有Master申请租约，获得半数以上Master授予的租约时成为Leader
+func (dc *OriginDiscoveryClient) ElectSingleton(serviceList []string) {
+	dc.singletonServiceList = serviceList
+	atomic.StoreInt32(&dc.singletonState, singletonElect)
+}
+
+// ResignSingleton 立即让出Leader，由续约的定时器通知Master释放租约
+func (dc *OriginDiscoveryClient) ResignSingleton() {
+	if atomic.SwapInt32(&dc.singletonState, singletonResign) != singletonElect {
+		return
+	}
+
+	for _, serviceName := range dc.singletonServiceList {
+		cluster.setServiceLeader(serviceName, false)
+	}
+}
+
+// keepSingletonLease 每ttl/3续约一次，本结点认为租约在发起申请后ttl/2内有效，
+// 定时器最迟在ttl/2+ttl/3时发现租约失效并让出Leader，早于Master上租约的过期时间，避免出现两个Leader
+func (dc *OriginDiscoveryClient) keepSingletonLease() {
+	if len(cluster.GetLocalNodeInfo().SingletonServiceList) == 0 {
+		return
+	}
+
+	ttl := getSingletonLeaseTTL()
+	interval := ttl / 3
+	dc.mapSingletonLease = map[string]map[string]time.Time{}
+	dc.NewTicker(interval, func(t *timer.Ticker) {
+		switch atomic.LoadInt32(&dc.singletonState) {
+		case singletonElect:
+			for _, serviceName := range dc.singletonServiceList {
+				dc.updateSingletonLeader(serviceName)
+				dc.renewSingletonLease(serviceName, interval, ttl/2)
+			}
+		case singletonResign:
+			dc.resignSingletonLease()
+			t.Cancel()
+		}
+	})
+}
+
+func (dc *OriginDiscoveryClient) renewSingletonLease(serviceName string, timeout time.Duration, validTime time.Duration) {
+	if s := service.GetService(serviceName); s == nil || s.IsRetire() {
+		return
+	}
+
+	if _, ok := dc.mapSingletonLease[serviceName]; ok == false {
+		dc.mapSingletonLease[serviceName] = map[string]time.Time{}
+	}
+
+	var req SingletonLeaseReq
+	req.NodeId = dc.localNodeId
+	req.ServiceName = serviceName
+	sendTime := time.Now()
+	masterNodeList := cluster.GetOriginDiscovery().MasterNodeList
+	for i := 0; i < len(masterNodeList); i++ {
+		masterNodeId := masterNodeList[i].NodeId
+		_, err := dc.AsyncCallNodeWithTimeout(timeout, masterNodeId, SingletonLeaseMethod, &req, func(res *SingletonLeaseRes, err error) {
+			if err != nil {
+				//租约可能仍然有效，到期后自然失效
+				log.Debugf("renew singleton lease fail,serviceName:%s,masterNodeId:%s,err:%s", serviceName, masterNodeId, err)
+			} else if res.Granted == true {
+				dc.mapSingletonLease[serviceName][masterNodeId] = sendTime.Add(validTime)
+			} else {
+				delete(dc.mapSingletonLease[serviceName], masterNodeId)
+			}
+
+			dc.updateSingletonLeader(serviceName)
+		})
+
+		if err != nil {
+			log.Debugf("renew singleton lease fail,serviceName:%s,masterNodeId:%s,err:%s", serviceName, masterNodeId, err)
+		}
+	}
+}
+
+// updateSingletonLeader 持有半数以上Master的有效租约时为Leader
+func (dc *OriginDiscoveryClient) updateSingletonLeader(serviceName string) {
+	var grantNum int
+	now := time.Now()
+	for _, expireTime := range dc.mapSingletonLease[serviceName] {
+		if now.Before(expireTime) {
+			grantNum++
+		}
+	}
+
+	isLeader := atomic.LoadInt32(&dc.singletonState) == singletonElect && grantNum > len(cluster.GetOriginDiscovery().MasterNodeList)/2
+	if s := service.GetService(serviceName); s == nil || s.IsRetire() {
+		isLeader = false
+	}
+
+	cluster.setServiceLeader(serviceName, isLeader)
+}
+
+// resignSingletonLease 通知所有Master释放本结点持有的租约，其他结点可以马上接管
+func (dc *OriginDiscoveryClient) resignSingletonLease() {
+	masterNodeList := cluster.GetOriginDiscovery().MasterNodeList
+	for _, serviceName := range dc.singletonServiceList {
+		var req SingletonLeaseReq
+		req.NodeId = dc.localNodeId
+		req.ServiceName = serviceName
+		req.Resign = true
+		for i := 0; i < len(masterNodeList); i++ {
+			if err := dc.GoNode(masterNodeList[i].NodeId, SingletonLeaseMethod, &req); err != nil {
+				log.Errorf("resign singleton lease fail,serviceName:%s,masterNodeId:%s,err:%s", serviceName, masterNodeList[i].NodeId, err)
+			}
+		}
+	}
+
+	clear(dc.mapSingletonLease)
+}
@@ -6,9 +6,11 @@ import (
 	"github.com/duanhf2012/origin/v2/config"
 	"github.com/duanhf2012/origin/v2/log"
 	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/sysmodule/redismodule"
 	"github.com/go-viper/mapstructure/v2"
 	jsoniter "github.com/json-iterator/go"
 	"gopkg.in/yaml.v3"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -34,6 +36,23 @@ type EtcdDiscovery struct {
 	EtcdList []EtcdList
 }
 
+type RedisDiscovery struct {
+	TTLSecond   int64
+	NetworkName []string
+
+	redismodule.ConfigRedis `mapstructure:",squash"`
+}
+
+type GossipDiscovery struct {
+	ListenAddr               string        //gossip监听的UDP地址，如"0.0.0.0:9001"
+	AdvertiseAddr            string        //其他结点访问本结点的gossip地址，不配置时使用ListenAddr
+	SeedList                 []string      //种子地址，配置任意已加入集群的结点即可
+	NetworkName              []string      //加入的网络，只会发现相同网络中的结点
+	ProbeIntervalMillisecond time.Duration //探测间隔，默认1000毫秒
+	SuspectTimeoutSecond     int64         //结点处于怀疑状态超过该时长后被认为失败，默认5秒
+	SyncIntervalSecond       int64         //与随机结点全量同步的间隔，默认30秒
+}
+
 type OriginDiscovery struct {
 	TTLSecond      int64
 	MasterNodeList []NodeInfo
@@ -45,6 +64,8 @@ const (
 	InvalidType = 0
 	OriginType  = 1
 	EtcdType    = 2
+	RedisType   = 3
+	GossipType  = 4
 )
 
 const MinTTL = 3
@@ -53,6 +74,8 @@ type DiscoveryInfo struct {
 	discoveryType DiscoveryType
 	Etcd          *EtcdDiscovery   //etcd
 	Origin        *OriginDiscovery //origin
+	Redis         *RedisDiscovery  //redis
+	Gossip        *GossipDiscovery //gossip
 }
 
 type NatsConfig struct {
@@ -66,9 +89,11 @@ type RpcMode struct {
 }
 
 type NodeInfoList struct {
-	RpcMode   RpcMode
-	Discovery DiscoveryInfo
-	NodeList  []NodeInfo
+	RpcMode      RpcMode
+	Discovery    DiscoveryInfo
+	ConfigCenter ConfigCenter
+	ClusterAdmin ClusterAdminCfg
+	NodeList     []NodeInfo
 }
 
 func validConfigFile(f os.DirEntry) bool {
@@ -123,6 +148,112 @@ func (d *DiscoveryInfo) setDiscovery(discoveryInfo *DiscoveryInfo) error {
 		return err
 	}
 
+	err = d.setRedis(discoveryInfo.Redis)
+	if err != nil {
+		return err
+	}
+
+	err = d.setGossip(discoveryInfo.Gossip)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *DiscoveryInfo) setRedis(redisDiscovery *RedisDiscovery) error {
+	if redisDiscovery == nil {
+		return nil
+	}
+
+	if d.discoveryType != InvalidType {
+		return fmt.Errorf("repeat configuration of Discovery")
+	}
+
+	if redisDiscovery.IP == "" || redisDiscovery.Port == 0 {
+		return fmt.Errorf("redis discovery config IP or Port is empty")
+	}
+
+	//networkName不允许重复
+	mapNetworkName := make(map[string]struct{})
+	for _, netName := range redisDiscovery.NetworkName {
+		if _, ok := mapNetworkName[netName]; ok == true {
+			return fmt.Errorf("redis discovery config Redis.NetworkName %+v is repeat", netName)
+		}
+
+		mapNetworkName[netName] = struct{}{}
+	}
+
+	if len(mapNetworkName) == 0 {
+		return fmt.Errorf("redis discovery config Redis.NetworkName is empty")
+	}
+
+	if redisDiscovery.TTLSecond < MinTTL {
+		redisDiscovery.TTLSecond = MinTTL
+	}
+
+	d.Redis = redisDiscovery
+	d.discoveryType = RedisType
+
+	return nil
+}
+
+func (d *DiscoveryInfo) setGossip(gossipDiscovery *GossipDiscovery) error {
+	if gossipDiscovery == nil {
+		return nil
+	}
+
+	if d.discoveryType != InvalidType {
+		return fmt.Errorf("repeat configuration of Discovery")
+	}
+
+	if gossipDiscovery.ListenAddr == "" {
+		return fmt.Errorf("gossip discovery config ListenAddr is empty")
+	}
+
+	if gossipDiscovery.AdvertiseAddr == "" {
+		gossipDiscovery.AdvertiseAddr = gossipDiscovery.ListenAddr
+	}
+
+	//其他结点需要通过AdvertiseAddr访问本结点
+	host, _, err := net.SplitHostPort(gossipDiscovery.AdvertiseAddr)
+	if err != nil {
+		return fmt.Errorf("gossip discovery config AdvertiseAddr %s is invalid:%s", gossipDiscovery.AdvertiseAddr, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		return fmt.Errorf("gossip discovery config AdvertiseAddr %s must be a reachable address", gossipDiscovery.AdvertiseAddr)
+	}
+
+	//networkName不允许重复
+	mapNetworkName := make(map[string]struct{})
+	for _, netName := range gossipDiscovery.NetworkName {
+		if _, ok := mapNetworkName[netName]; ok == true {
+			return fmt.Errorf("gossip discovery config Gossip.NetworkName %+v is repeat", netName)
+		}
+
+		mapNetworkName[netName] = struct{}{}
+	}
+
+	if len(mapNetworkName) == 0 {
+		return fmt.Errorf("gossip discovery config Gossip.NetworkName is empty")
+	}
+
+	if gossipDiscovery.ProbeIntervalMillisecond <= 0 {
+		gossipDiscovery.ProbeIntervalMillisecond = 1000
+	}
+	gossipDiscovery.ProbeIntervalMillisecond = gossipDiscovery.ProbeIntervalMillisecond * time.Millisecond
+
+	if gossipDiscovery.SuspectTimeoutSecond <= 0 {
+		gossipDiscovery.SuspectTimeoutSecond = 5
+	}
+
+	if gossipDiscovery.SyncIntervalSecond <= 0 {
+		gossipDiscovery.SyncIntervalSecond = 30
+	}
+
+	d.Gossip = gossipDiscovery
+	d.discoveryType = GossipType
+
 	return nil
 }
 
@@ -199,33 +330,54 @@ func (d *DiscoveryInfo) setOrigin(originDiscovery *OriginDiscovery) error {
 }
 
 func (cls *Cluster) ReadClusterConfig() (*NodeInfoList, error) {
+	return decodeClusterConfig(config.GetSystemConfig())
+}
+
+func decodeClusterConfig(ms map[string]interface{}) (*NodeInfoList, error) {
 	c := &NodeInfoList{}
-	ms := config.GetSystemConfig()
 	err := mapstructure.Decode(ms, c)
 	return c, err
 }
 
 func (cls *Cluster) readServiceConfig() (interface{}, map[string]interface{}, map[string]map[string]interface{}, error) {
-	c := config.GetSystemConfig()
+	globalCfg, serviceConfig, mapNodeService, err := parseServiceConfig(config.GetSystemConfig())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
-	GlobalCfg, ok := c["Global"]
+	return globalCfg, serviceConfig, mapNodeService, nil
+}
+
+// parseServiceConfig 从配置中解析Global、Service与NodeService
+func parseServiceConfig(c map[string]interface{}) (interface{}, map[string]interface{}, map[string]map[string]interface{}, error) {
+	GlobalCfg := c["Global"]
 	serviceConfig := map[string]interface{}{}
 	serviceCfg, ok := c["Service"]
-	if ok == true {
-		serviceConfig = serviceCfg.(map[string]interface{})
+	if ok == true && serviceCfg != nil {
+		serviceConfig, ok = serviceCfg.(map[string]interface{})
+		if ok == false {
+			return nil, nil, nil, errors.New("Service config is not an object")
+		}
 	}
 
 	mapNodeService := map[string]map[string]interface{}{}
 	nodeServiceCfg, ok := c["NodeService"]
-	if ok == true {
-		nodeServiceList := nodeServiceCfg.([]interface{})
+	if ok == true && nodeServiceCfg != nil {
+		nodeServiceList, ok := nodeServiceCfg.([]interface{})
+		if ok == false {
+			return nil, nil, nil, errors.New("NodeService config is not a list")
+		}
+
 		for _, v := range nodeServiceList {
-			serviceCfg := v.(map[string]interface{})
-			nodeId, ok := serviceCfg["NodeId"]
+			serviceCfg, ok := v.(map[string]interface{})
+			if ok == false {
+				return nil, nil, nil, errors.New("NodeService list item is not an object")
+			}
+			nodeId, ok := serviceCfg["NodeId"].(string)
 			if ok == false {
-				log.Fatal("NodeService list not find nodeId field")
+				return nil, nil, nil, errors.New("NodeService list not find nodeId field")
 			}
-			mapNodeService[nodeId.(string)] = serviceCfg
+			mapNodeService[nodeId] = serviceCfg
 		}
 	}
 	return GlobalCfg, serviceConfig, mapNodeService, nil
@@ -258,12 +410,15 @@ func (cls *Cluster) SetRpcMode(cfgRpcMode *RpcMode, rpcMode *RpcMode) error {
 }
 
 func (cls *Cluster) readLocalClusterConfig(nodeId string) (DiscoveryInfo, []NodeInfo, RpcMode, error) {
+	fileNodeInfoList, _ := cls.ReadClusterConfig()
+	return cls.parseClusterConfig(nodeId, fileNodeInfoList)
+}
+
+func (cls *Cluster) parseClusterConfig(nodeId string, fileNodeInfoList *NodeInfoList) (DiscoveryInfo, []NodeInfo, RpcMode, error) {
 	var nodeInfoList []NodeInfo
 	var discoveryInfo DiscoveryInfo
 	var rpcMode RpcMode
 
-	fileNodeInfoList, _ := cls.ReadClusterConfig()
-
 	err := cls.SetRpcMode(&fileNodeInfoList.RpcMode, &rpcMode)
 	if err != nil {
 		return discoveryInfo, nil, rpcMode, err
@@ -433,6 +588,12 @@ func (cls *Cluster) InitCfg(localNodeId string) error {
 		return err
 	}
 
+	//加载版本路由配置
+	err = cls.initVersionRoute()
+	if err != nil {
+		return err
+	}
+
 	//本地配置服务加到全局map信息中
 	return cls.parseLocalCfg()
 }
@@ -477,29 +638,66 @@ func (cls *Cluster) GetNodeIdByTemplateService(templateServiceName string, rpcCl
 }
 
 func (cls *Cluster) GetNodeIdByService(serviceName string, rpcClientList []*rpc.Client, filterRetire bool) (error, []*rpc.Client) {
+	return cls.GetNodeIdByServiceSelector(serviceName, nil, rpcClientList, filterRetire)
+}
+
+func (cls *Cluster) GetNodeIdByServiceSelector(serviceName string, selector rpc.LabelSelector, rpcClientList []*rpc.Client, filterRetire bool) (error, []*rpc.Client) {
 	cls.locker.RLock()
 	defer cls.locker.RUnlock()
 	mapNodeId, ok := cls.mapServiceNode[serviceName]
-	if ok == true {
-		for nodeId := range mapNodeId {
-			pClient, retire := GetCluster().getRpcClient(nodeId)
-			if pClient == nil || pClient.IsConnected() == false {
-				continue
-			}
+	if ok == false {
+		return nil, rpcClientList
+	}
 
-			//如果需要筛选掉退休的，对retire状态的结点略过
-			if filterRetire == true && retire == true {
-				continue
-			}
+	//不满足优先标签的结点，没有满足的结点时才选择
+	var fallbackList []*rpc.Client
+	hasPrefer := selector.HasPrefer()
+	preferIndex := len(rpcClientList)
+	for nodeId := range mapNodeId {
+		nodeRpc, ok := cls.mapRpc[nodeId]
+		if ok == false || nodeRpc.client == nil || nodeRpc.client.IsConnected() == false {
+			continue
+		}
 
-			rpcClientList = append(rpcClientList, pClient)
+		//如果需要筛选掉退休的，对retire状态的结点略过
+		if filterRetire == true && nodeRpc.nodeInfo.Retire == true {
+			continue
+		}
+
+		//筛选标签不匹配的结点
+		if selector.Match(nodeRpc.nodeInfo.Labels) == false {
+			continue
 		}
+
+		//筛选掉健康检查失败的服务
+		if nodeRpc.nodeInfo.isServiceHealthy(serviceName) == false {
+			continue
+		}
+
+		//单例服务只调用Leader，选举中没有Leader时调用失败
+		if nodeRpc.nodeInfo.isSingletonStandby(serviceName) == true {
+			continue
+		}
+
+		if hasPrefer == true && selector.MatchPrefer(nodeRpc.nodeInfo.Labels) == false {
+			fallbackList = append(fallbackList, nodeRpc.client)
+			continue
+		}
+
+		rpcClientList = append(rpcClientList, nodeRpc.client)
+	}
+
+	if len(rpcClientList) == preferIndex {
+		rpcClientList = append(rpcClientList, fallbackList...)
 	}
 
 	return nil, rpcClientList
 }
 
 func (cls *Cluster) GetServiceCfg(serviceName string) interface{} {
+	cls.cfgLocker.RLock()
+	defer cls.cfgLocker.RUnlock()
+
 	serviceCfg, ok := cls.localServiceCfg[serviceName]
 	if ok == false {
 		return nil
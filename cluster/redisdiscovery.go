@@ -0,0 +1,494 @@
+package cluster
+
+import (
+	"errors"
+	"github.com/duanhf2012/origin/v2/event"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/sysmodule/redismodule"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"github.com/gomodule/redigo/redis"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	rdPut            = 'P' //结点注册或更新,消息体为NodeInfo
+	rdDelete         = 'D' //结点注销,消息体为NodeId
+	rdScanCount      = 256
+	rdSubscribeRetry = time.Second * 3
+)
+
+// rdPTTLScript 批量获取结点key的剩余存活毫秒数
+var rdPTTLScript = redis.NewScript(-1, `
+local ret = {}
+for i, key in ipairs(KEYS) do
+	ret[i] = redis.call('PTTL', key)
+end
+return ret
+`)
+
+const (
+	reMessage        = 0
+	reSubscribeClose = 1
+)
+
+type RedisDiscoveryService struct {
+	service.Service
+	funDelNode  FunDelNode
+	funSetNode  FunSetNode
+	localNodeId string
+
+	redisModule          redismodule.RedisModule
+	mapByteLocalNodeInfo map[string]string //map[networkName]本结点信息，桥接的服务只发布到导出的网络
+	subscriber           io.Closer
+	isClose              int32
+	bRetire              bool
+	mapDiscoveryNodeId   map[string]map[string]string //map[networkName]map[nodeId]nodeInfo
+}
+
+type redisDiscoveryEvent struct {
+	typ     int
+	channel string
+	data    []byte
+}
+
+func (re *redisDiscoveryEvent) GetEventType() event.EventType {
+	return event.Sys_Event_RedisDiscovery
+}
+
+var redisDiscovery *RedisDiscoveryService
+
+func getRedisDiscovery() IServiceDiscovery {
+	if redisDiscovery == nil {
+		redisDiscovery = &RedisDiscoveryService{}
+	}
+
+	return redisDiscovery
+}
+
+func (rd *RedisDiscoveryService) InitDiscovery(localNodeId string, funDelNode FunDelNode, funSetNode FunSetNode) error {
+	rd.localNodeId = localNodeId
+
+	rd.funDelNode = funDelNode
+	rd.funSetNode = funSetNode
+
+	return nil
+}
+
+func (rd *RedisDiscoveryService) OnInit() error {
+	rd.mapDiscoveryNodeId = make(map[string]map[string]string)
+	rd.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_RedisDiscovery, rd.GetEventHandler(), rd.OnRedisDiscovery)
+	rd.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_LocalNodeInfo, rd.GetEventHandler(), rd.OnLocalNodeInfo)
+
+	redisDiscoveryCfg := cluster.GetRedisDiscovery()
+	if redisDiscoveryCfg == nil {
+		return errors.New("redis discovery config is nil")
+	}
+
+	err := rd.marshalNodeInfo()
+	if err != nil {
+		return err
+	}
+
+	rd.redisModule.Init(&redisDiscoveryCfg.ConfigRedis)
+	_, err = rd.AddModule(&rd.redisModule)
+	if err != nil {
+		return err
+	}
+
+	err = rd.redisModule.TestPingRedis()
+	if err != nil {
+		log.Errorf("redis discovery init fail,addr:%s:%d,err:%s", redisDiscoveryCfg.IP, redisDiscoveryCfg.Port, err)
+		return err
+	}
+
+	return nil
+}
+
+func (rd *RedisDiscoveryService) OnStart() {
+	rd.registerService(true)
+	rd.subscribe()
+
+	cfg := cluster.GetRedisDiscovery()
+	//每1/3 TTL续期一次
+	interval := time.Duration(cfg.TTLSecond) * time.Second / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	rd.NewTicker(interval, func(t *timer.Ticker) {
+		rd.registerService(false)
+	})
+
+	//全量同步用于发现过期的结点
+	rd.NewTicker(time.Duration(cfg.TTLSecond)*time.Second, func(t *timer.Ticker) {
+		rd.syncServices()
+	})
+}
+
+func (rd *RedisDiscoveryService) OnRetire() {
+	rd.bRetire = true
+	rd.marshalNodeInfo()
+	rd.registerService(true)
+}
+
+// OnLocalNodeInfo 本结点信息变化(如服务健康状态)，重新写入并广播
+func (rd *RedisDiscoveryService) OnLocalNodeInfo(ev event.IEvent) {
+	rd.marshalNodeInfo()
+	rd.registerService(true)
+}
+
+func (rd *RedisDiscoveryService) OnRelease() {
+	atomic.StoreInt32(&rd.isClose, 1)
+
+	for _, networkName := range cluster.GetRedisDiscovery().NetworkName {
+		err := rd.redisModule.DelString(rd.getRegisterKey(networkName))
+		if err != nil {
+			log.Errorf("redis discovery unregister fail,networkName:%s,err:%s", networkName, err)
+		}
+
+		rd.redisModule.Publish(rd.getChannel(networkName), string(rdDelete)+rd.localNodeId)
+	}
+
+	//退订并等待接收协程退出，之后不会再回调本服务
+	if rd.subscriber != nil {
+		rd.subscriber.Close()
+		rd.subscriber = nil
+	}
+}
+
+func (rd *RedisDiscoveryService) isStop() bool {
+	return atomic.LoadInt32(&rd.isClose) == 1
+}
+
+func (rd *RedisDiscoveryService) getChannel(networkName string) string {
+	return originDir + "/" + networkName
+}
+
+func (rd *RedisDiscoveryService) getRegisterKey(networkName string) string {
+	return rd.getChannel(networkName) + "/" + rd.localNodeId
+}
+
+func (rd *RedisDiscoveryService) getNetworkNameByChannel(channel string) string {
+	return channel[len(originDir)+1:]
+}
+
+func (rd *RedisDiscoveryService) getNodeId(fullKey string) string {
+	return fullKey[strings.LastIndex(fullKey, "/")+1:]
+}
+
+func (rd *RedisDiscoveryService) marshalNodeInfo() error {
+	nInfo := cluster.GetLocalNodeInfo()
+	var nodeInfo rpc.NodeInfo
+	nodeInfo.NodeId = nInfo.NodeId
+	nodeInfo.ListenAddr = nInfo.ListenAddr
+	nodeInfo.Retire = rd.bRetire
+	nodeInfo.MaxRpcParamLen = nInfo.MaxRpcParamLen
+	nodeInfo.Labels = nInfo.Labels
+	nodeInfo.ServiceVersion = nInfo.ServiceVersion
+	nodeInfo.UnhealthyServiceList = cluster.GetUnhealthyServiceList()
+	nodeInfo.SingletonServiceList = nInfo.SingletonServiceList
+	nodeInfo.ServiceLeader = cluster.GetServiceLeader()
+	nodeInfo.Private = nInfo.Private
+
+	mapByteLocalNodeInfo := make(map[string]string, len(rd.mapByteLocalNodeInfo))
+	for _, networkName := range cluster.GetRedisDiscovery().NetworkName {
+		nodeInfo.PublicServiceList = cluster.GetNetworkPublicServiceList(networkName)
+
+		//标签为map,使用确定的序列化顺序，保证结点信息无变化时序列化结果一致
+		byteLocalNodeInfo, err := proto.MarshalOptions{Deterministic: true}.Marshal(&nodeInfo)
+		if err != nil {
+			return err
+		}
+		mapByteLocalNodeInfo[networkName] = string(byteLocalNodeInfo)
+	}
+
+	rd.mapByteLocalNodeInfo = mapByteLocalNodeInfo
+	return nil
+}
+
+// registerService 写入带TTL的结点信息,定时调用以完成续期,结点信息有变化时才广播
+func (rd *RedisDiscoveryService) registerService(bPublish bool) {
+	if rd.isStop() {
+		return
+	}
+
+	ttl := strconv.FormatInt(cluster.GetRedisDiscovery().TTLSecond, 10)
+	for _, networkName := range cluster.GetRedisDiscovery().NetworkName {
+		err := rd.redisModule.SetStringExpire(rd.getRegisterKey(networkName), rd.mapByteLocalNodeInfo[networkName], ttl)
+		if err != nil {
+			log.Errorf("redis discovery register fail,networkName:%s,err:%s", networkName, err)
+			continue
+		}
+		cluster.refreshLocalHeartbeat()
+
+		if bPublish == false {
+			continue
+		}
+
+		err = rd.redisModule.Publish(rd.getChannel(networkName), string(rdPut)+rd.mapByteLocalNodeInfo[networkName])
+		if err != nil {
+			log.Errorf("redis discovery publish fail,networkName:%s,err:%s", networkName, err)
+		}
+	}
+}
+
+func (rd *RedisDiscoveryService) subscribe() {
+	if rd.isStop() {
+		return
+	}
+
+	var channels []string
+	for _, networkName := range cluster.GetRedisDiscovery().NetworkName {
+		channels = append(channels, rd.getChannel(networkName))
+	}
+
+	var err error
+	rd.subscriber, err = rd.redisModule.Subscribe(channels, rd.notifyMessage, rd.notifySubscribeClose)
+	if err != nil {
+		log.Errorf("redis discovery subscribe fail:%s", err)
+		rd.trySubscribe()
+		return
+	}
+
+	//重新订阅期间可能有丢失的消息，需要全量同步一次
+	rd.syncServices()
+}
+
+func (rd *RedisDiscoveryService) trySubscribe() {
+	if rd.isStop() {
+		return
+	}
+
+	rd.AfterFunc(rdSubscribeRetry, func(t *timer.Timer) {
+		rd.subscribe()
+	})
+}
+
+// notifyMessage 在订阅协程中回调，转到服务协程处理
+func (rd *RedisDiscoveryService) notifyMessage(channel string, data []byte) {
+	var ev redisDiscoveryEvent
+	ev.typ = reMessage
+	ev.channel = channel
+	ev.data = data
+	rd.NotifyEvent(&ev)
+}
+
+func (rd *RedisDiscoveryService) notifySubscribeClose(err error) {
+	if rd.isStop() {
+		return
+	}
+
+	log.Errorf("redis discovery subscribe is closed:%s", err)
+	var ev redisDiscoveryEvent
+	ev.typ = reSubscribeClose
+	rd.NotifyEvent(&ev)
+}
+
+func (rd *RedisDiscoveryService) OnRedisDiscovery(ev event.IEvent) {
+	disEvent := ev.(*redisDiscoveryEvent)
+	switch disEvent.typ {
+	case reMessage:
+		rd.OnMessage(rd.getNetworkNameByChannel(disEvent.channel), disEvent.data)
+	case reSubscribeClose:
+		rd.subscriber = nil
+		rd.trySubscribe()
+	}
+}
+
+func (rd *RedisDiscoveryService) OnMessage(networkName string, data []byte) {
+	if len(data) < 1 {
+		return
+	}
+
+	switch data[0] {
+	case rdPut:
+		rd.setNode(networkName, data[1:])
+	case rdDelete:
+		nodeId := string(data[1:])
+		if nodeId == rd.localNodeId {
+			return
+		}
+
+		rd.funDelNode(nodeId)
+		delete(rd.mapDiscoveryNodeId[networkName], nodeId)
+	default:
+		log.Errorf("redis discovery unknown message,networkName:%s", networkName)
+	}
+}
+
+// syncServices 全量拉取各网络中的结点，清理已经过期的结点
+func (rd *RedisDiscoveryService) syncServices() {
+	if rd.isStop() {
+		return
+	}
+
+	for _, networkName := range cluster.GetRedisDiscovery().NetworkName {
+		mapNodeInfo, err := rd.getServices(networkName)
+		if err != nil {
+			log.Errorf("redis discovery get services fail,networkName:%s,err:%s", networkName, err)
+			continue
+		}
+
+		rd.onGets(networkName, mapNodeInfo)
+		rd.refreshHeartbeat(mapNodeInfo)
+	}
+}
+
+// refreshHeartbeat 根据结点key剩余的TTL推算其最后一次续期的时间，作为结点的心跳时间
+func (rd *RedisDiscoveryService) refreshHeartbeat(mapNodeInfo map[string]string) {
+	keysAndArgs := make([]interface{}, 0, len(mapNodeInfo)+1)
+	keysAndArgs = append(keysAndArgs, 0) //key数量,填充完key后设置
+	var nodeIdList []string
+	for key := range mapNodeInfo {
+		nodeId := rd.getNodeId(key)
+		if nodeId == rd.localNodeId {
+			continue
+		}
+		keysAndArgs = append(keysAndArgs, key)
+		nodeIdList = append(nodeIdList, nodeId)
+	}
+
+	if len(nodeIdList) == 0 {
+		return
+	}
+	keysAndArgs[0] = len(nodeIdList)
+
+	pttlList, err := redis.Int64s(rd.redisModule.EvalScript(rdPTTLScript, keysAndArgs...))
+	if err != nil {
+		log.Errorf("redis discovery get ttl fail,err:%s", err)
+		return
+	}
+
+	now := time.Now()
+	ttl := time.Duration(cluster.GetRedisDiscovery().TTLSecond) * time.Second
+	for i, pttl := range pttlList {
+		if i >= len(nodeIdList) || pttl <= 0 {
+			continue
+		}
+
+		//剩余时间为TTL时刚完成续期
+		cluster.refreshNodeHeartbeat(nodeIdList[i], now.Add(time.Duration(pttl)*time.Millisecond-ttl))
+	}
+}
+
+func (rd *RedisDiscoveryService) getServices(networkName string) (map[string]string, error) {
+	var keys []string
+	cursor := 0
+	match := rd.getChannel(networkName) + "/*"
+	for {
+		nextCursor, scanKeys, err := rd.redisModule.ScanMatchKeys(cursor, match, rdScanCount)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, scanKeys...)
+		if nextCursor == 0 {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	if len(keys) == 0 {
+		return map[string]string{}, nil
+	}
+
+	return rd.redisModule.GetStringMap(keys)
+}
+
+func (rd *RedisDiscoveryService) onGets(networkName string, mapNodeInfo map[string]string) {
+	mapNode := make(map[string]struct{}, len(mapNodeInfo))
+	for key, value := range mapNodeInfo {
+		if value == "" {
+			continue
+		}
+
+		mapNode[rd.getNodeId(key)] = struct{}{}
+		rd.setNode(networkName, []byte(value))
+	}
+
+	for nodeId := range rd.mapDiscoveryNodeId[networkName] {
+		if _, ok := mapNode[nodeId]; ok == false && nodeId != rd.localNodeId {
+			log.Debugf("redis discovery node expired,networkName:%s,nodeId:%s", networkName, nodeId)
+			rd.funDelNode(nodeId)
+			delete(rd.mapDiscoveryNodeId[networkName], nodeId)
+		}
+	}
+}
+
+func (rd *RedisDiscoveryService) setNode(networkName string, byteNode []byte) {
+	var nodeInfo rpc.NodeInfo
+	err := proto.Unmarshal(byteNode, &nodeInfo)
+	if err != nil {
+		log.Errorf("Unmarshal fail,networkName:%s,err:%s", networkName, err)
+		return
+	}
+
+	//结点信息无变化时不重复通知
+	if lastNodeInfo, ok := rd.mapDiscoveryNodeId[networkName][nodeInfo.NodeId]; ok == true && lastNodeInfo == string(byteNode) {
+		return
+	}
+
+	rd.addNodeId(networkName, nodeInfo.NodeId, string(byteNode))
+	rd.setNodeInfo(networkName, &nodeInfo)
+}
+
+func (rd *RedisDiscoveryService) setNodeInfo(networkName string, nodeInfo *rpc.NodeInfo) bool {
+	if nodeInfo == nil || nodeInfo.Private == true || nodeInfo.NodeId == rd.localNodeId {
+		return false
+	}
+
+	//筛选关注的服务
+	var discoverServiceSlice = make([]string, 0, 24)
+	for _, pubService := range nodeInfo.PublicServiceList {
+		if cluster.CanDiscoveryService(networkName, pubService, nodeInfo.Labels) == true {
+			discoverServiceSlice = append(discoverServiceSlice, pubService)
+		}
+	}
+
+	if len(discoverServiceSlice) == 0 {
+		return false
+	}
+
+	var nInfo NodeInfo
+	nInfo.ServiceList = discoverServiceSlice
+	nInfo.PublicServiceList = discoverServiceSlice
+	nInfo.NodeId = nodeInfo.NodeId
+	nInfo.ListenAddr = nodeInfo.ListenAddr
+	nInfo.MaxRpcParamLen = nodeInfo.MaxRpcParamLen
+	nInfo.Retire = nodeInfo.Retire
+	nInfo.Private = nodeInfo.Private
+	nInfo.Labels = nodeInfo.Labels
+	nInfo.ServiceVersion = nodeInfo.ServiceVersion
+	nInfo.UnhealthyServiceList = nodeInfo.UnhealthyServiceList
+	nInfo.SingletonServiceList = nodeInfo.SingletonServiceList
+	nInfo.ServiceLeader = nodeInfo.ServiceLeader
+	nInfo.NetworkName = networkName
+
+	rd.funSetNode(&nInfo)
+
+	return true
+}
+
+func (rd *RedisDiscoveryService) addNodeId(networkName string, nodeId string, byteNodeInfo string) {
+	if nodeId == "" || nodeId == rd.localNodeId {
+		return
+	}
+
+	if _, ok := rd.mapDiscoveryNodeId[networkName]; ok == false {
+		rd.mapDiscoveryNodeId[networkName] = make(map[string]string)
+	}
+
+	rd.mapDiscoveryNodeId[networkName][nodeId] = byteNodeInfo
+}
+
+func (rd *RedisDiscoveryService) OnNodeDisconnect(nodeId string) {
+	//将Discard结点清理
+	cluster.DiscardNode(nodeId)
+}
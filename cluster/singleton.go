@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ISingletonElection 单例服务的选举由服务发现负责(如etcd租约、origin的Master授予租约)，未实现该接口的服务发现不允许配置单例服务
+type ISingletonElection interface {
+	ElectSingleton(serviceList []string) //开始竞选
+	ResignSingleton()                    //退出竞选并释放Leader
+}
+
+// IsSingletonService 服务在本结点是否以单例模式运行
+func (cls *Cluster) IsSingletonService(serviceName string) bool {
+	return slices.Contains(cls.localNodeInfo.SingletonServiceList, serviceName)
+}
+
+// IsServiceLeader 本结点的单例服务是否为Leader
+func (cls *Cluster) IsServiceLeader(serviceName string) bool {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	_, ok := cls.localNodeInfo.ServiceLeader[serviceName]
+	return ok
+}
+
+// GetServiceLeader 获取本结点为Leader的单例服务
+func (cls *Cluster) GetServiceLeader() map[string]int64 {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	return cls.localNodeInfo.ServiceLeader
+}
+
+// GetSingletonLeader 获取单例服务当前的Leader结点Id，没有Leader时返回空
+func GetSingletonLeader(serviceName string) string {
+	cluster.locker.RLock()
+	defer cluster.locker.RUnlock()
+
+	for nodeId := range cluster.mapServiceNode[serviceName] {
+		nodeRpc, ok := cluster.mapRpc[nodeId]
+		if ok == false {
+			continue
+		}
+
+		if _, ok = nodeRpc.nodeInfo.ServiceLeader[serviceName]; ok == true {
+			return nodeId
+		}
+	}
+
+	return ""
+}
+
+// setServiceLeader 本结点单例服务的Leader状态变化，通知服务并通过服务发现发布
+func (cls *Cluster) setServiceLeader(serviceName string, isLeader bool) {
+	cls.locker.Lock()
+	if _, ok := cls.localNodeInfo.ServiceLeader[serviceName]; ok == isLeader {
+		cls.locker.Unlock()
+		return
+	}
+
+	//重新分配，避免影响正在读取的调用方
+	serviceLeader := make(map[string]int64, len(cls.localNodeInfo.ServiceLeader)+1)
+	maps.Copy(serviceLeader, cls.localNodeInfo.ServiceLeader)
+	if isLeader {
+		serviceLeader[serviceName] = time.Now().UnixMilli()
+	} else {
+		delete(serviceLeader, serviceName)
+	}
+
+	cls.localNodeInfo.ServiceLeader = serviceLeader
+	cls.syncLocalNodeRpcInfo()
+	cls.locker.Unlock()
+
+	log.Infof("singleton service leader changed,serviceName:%s,isLeader:%t", serviceName, isLeader)
+	if s := service.GetService(serviceName); s != nil {
+		s.SetLeader(isLeader)
+	}
+	cls.notifyLocalNodeInfoChanged()
+}
+
+// checkSingletonElection 配置了单例服务时，服务发现必须支持选举，否则在启动时报错
+func (cls *Cluster) checkSingletonElection() error {
+	if len(cls.localNodeInfo.SingletonServiceList) == 0 {
+		return nil
+	}
+
+	if _, ok := cls.serviceDiscovery.(ISingletonElection); ok == false {
+		return fmt.Errorf("singleton service %v requires etcd or origin service discovery", cls.localNodeInfo.SingletonServiceList)
+	}
+
+	return nil
+}
+
+func (cls *Cluster) startSingletonElection() {
+	if len(cls.localNodeInfo.SingletonServiceList) == 0 {
+		return
+	}
+
+	cls.serviceDiscovery.(ISingletonElection).ElectSingleton(cls.localNodeInfo.SingletonServiceList)
+}
+
+// RetireSingleton 结点退休时退出单例服务的竞选，由其他结点接管
+func (cls *Cluster) RetireSingleton() {
+	if len(cls.localNodeInfo.SingletonServiceList) == 0 {
+		return
+	}
+
+	cls.serviceDiscovery.(ISingletonElection).ResignSingleton()
+}
+
+// isSingletonStandby 单例服务在该结点不是Leader
+func (nodeInfo *NodeInfo) isSingletonStandby(serviceName string) bool {
+	if slices.Contains(nodeInfo.SingletonServiceList, serviceName) == false {
+		return false
+	}
+
+	_, ok := nodeInfo.ServiceLeader[serviceName]
+	return ok == false
+}
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"github.com/duanhf2012/origin/v2/service"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// NodeSnapshot 本结点视角下某个结点的状态
+type NodeSnapshot struct {
+	NodeId               string
+	NetworkName          string
+	ListenAddr           string
+	Local                bool //是否为本结点
+	Connected            bool
+	Retire               bool
+	PublicServiceList    []string
+	UnhealthyServiceList []string
+	LastUpdateTime       time.Time //最后一次收到服务发现同步该结点信息的时间，只在结点信息变化时同步，不代表结点存活，本结点为零值
+	LastHeartbeatTime    time.Time //最后一次观察到该结点存活的时间，本结点为最后一次向服务发现续约成功的时间，见refreshNodeHeartbeat
+}
+
+// LocalServiceStatus 本结点服务的运行状态
+type LocalServiceStatus struct {
+	ServiceName     string
+	EventChannelNum int //等待处理的事件数
+	TimerChannelNum int //等待处理的定时器数
+	Retire          bool
+	Healthy         bool
+	Leader          bool //单例服务是否为Leader，非单例服务为false
+}
+
+// ClusterStatus 本结点视角下的集群快照
+type ClusterStatus struct {
+	NodeId        string
+	DiscoveryType string //服务发现方式：origin、etcd、redis、gossip或config
+	SnapshotTime  time.Time
+	NodeList      []NodeSnapshot
+	ServiceList   []LocalServiceStatus
+	DrainStatus   DrainStatus //本结点的退休排空进度
+}
+
+func (d DiscoveryType) String() string {
+	switch d {
+	case OriginType:
+		return "origin"
+	case EtcdType:
+		return "etcd"
+	case RedisType:
+		return "redis"
+	case GossipType:
+		return "gossip"
+	}
+
+	return "config"
+}
+
+// refreshNodeHeartbeat 服务发现观察到结点存活时调用，各服务发现方式的观察方法为：
+// etcd为结点租约最后一次续约的时间，redis为结点信息最后一次续期的时间，
+// origin中Master为收到结点Ping的时间，其他结点只能观察到Master回复Pong的时间，gossip为探测收到回复的时间
+func (cls *Cluster) refreshNodeHeartbeat(nodeId string, heartbeatTime time.Time) {
+	cls.locker.Lock()
+	defer cls.locker.Unlock()
+
+	nodeRpc, ok := cls.mapRpc[nodeId]
+	if ok == false || heartbeatTime.Before(nodeRpc.lastHeartbeatTime) {
+		return
+	}
+
+	nodeRpc.lastHeartbeatTime = heartbeatTime
+}
+
+// refreshLocalHeartbeat 本结点向服务发现续约成功时调用
+func (cls *Cluster) refreshLocalHeartbeat() {
+	atomic.StoreInt64(&cls.localHeartbeatTime, time.Now().UnixNano())
+}
+
+func (cls *Cluster) getLocalHeartbeatTime() time.Time {
+	heartbeatTime := atomic.LoadInt64(&cls.localHeartbeatTime)
+	if heartbeatTime == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, heartbeatTime)
+}
+
+// GetClusterStatus 获取本结点已知的所有结点及本结点服务的状态快照
+func (cls *Cluster) GetClusterStatus() ClusterStatus {
+	var status ClusterStatus
+	status.NodeId = cls.localNodeInfo.NodeId
+	status.DiscoveryType = cls.discoveryInfo.getDiscoveryType().String()
+	status.SnapshotTime = time.Now()
+
+	cls.locker.RLock()
+	status.NodeList = make([]NodeSnapshot, 0, len(cls.mapRpc))
+	for nodeId, nodeRpc := range cls.mapRpc {
+		nodeInfo := &nodeRpc.nodeInfo
+		if nodeId == cls.localNodeInfo.NodeId {
+			nodeInfo = &cls.localNodeInfo
+		}
+
+		status.NodeList = append(status.NodeList, NodeSnapshot{
+			NodeId:               nodeId,
+			NetworkName:          nodeInfo.NetworkName,
+			ListenAddr:           nodeInfo.ListenAddr,
+			Local:                nodeId == cls.localNodeInfo.NodeId,
+			Connected:            nodeRpc.client != nil && nodeRpc.client.IsConnected(),
+			Retire:               nodeInfo.Retire,
+			PublicServiceList:    slices.Clone(nodeInfo.PublicServiceList),
+			UnhealthyServiceList: slices.Clone(nodeInfo.UnhealthyServiceList),
+			LastUpdateTime:       nodeRpc.lastUpdateTime,
+			LastHeartbeatTime:    nodeRpc.lastHeartbeatTime,
+		})
+		if nodeId == cls.localNodeInfo.NodeId {
+			status.NodeList[len(status.NodeList)-1].LastHeartbeatTime = cls.getLocalHeartbeatTime()
+		}
+	}
+	unhealthyServiceList := cls.localNodeInfo.UnhealthyServiceList
+	cls.locker.RUnlock()
+
+	sort.Slice(status.NodeList, func(i, j int) bool {
+		return status.NodeList[i].NodeId < status.NodeList[j].NodeId
+	})
+
+	for _, s := range service.GetServiceList() {
+		status.ServiceList = append(status.ServiceList, LocalServiceStatus{
+			ServiceName:     s.GetName(),
+			EventChannelNum: s.GetServiceEventChannelNum(),
+			TimerChannelNum: s.GetServiceTimerChannelNum(),
+			Retire:          s.IsRetire(),
+			Healthy:         slices.Contains(unhealthyServiceList, s.GetName()) == false,
+			Leader:          s.IsLeader(),
+		})
+	}
+	status.DrainStatus = cls.GetDrainStatus()
+
+	return status
+}
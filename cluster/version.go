@@ -0,0 +1,259 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// VersionRoute 服务版本路由，用于蓝绿与灰度发布
+type VersionRoute struct {
+	Version       string //调用的版本范围，如">=1.2.0,<2.0.0"，不配置表示不限制版本
+	CanaryVersion string //灰度版本范围，不配置表示无灰度
+	CanaryPercent int    //发往灰度版本的流量百分比，0-100
+
+	version       versionRange
+	canaryVersion versionRange
+}
+
+type versionConstraint struct {
+	op      string
+	version string
+}
+
+// versionRange 以逗号分隔的多个版本约束，需同时满足
+type versionRange []versionConstraint
+
+var versionOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+func parseVersionRange(strRange string) (versionRange, error) {
+	var vRange versionRange
+	for _, strConstraint := range strings.Split(strRange, ",") {
+		strConstraint = strings.TrimSpace(strConstraint)
+		if strConstraint == "" {
+			continue
+		}
+
+		constraint := versionConstraint{op: "="}
+		for _, op := range versionOps {
+			if strings.HasPrefix(strConstraint, op) {
+				constraint.op = op
+				strConstraint = strings.TrimSpace(strConstraint[len(op):])
+				break
+			}
+		}
+
+		if strConstraint == "" {
+			return nil, fmt.Errorf("version range %s is error", strRange)
+		}
+
+		constraint.version = strConstraint
+		vRange = append(vRange, constraint)
+	}
+
+	return vRange, nil
+}
+
+func (vRange versionRange) match(version string) bool {
+	if len(vRange) > 0 && version == "" {
+		return false
+	}
+
+	for _, constraint := range vRange {
+		ret := compareVersion(version, constraint.version)
+		switch constraint.op {
+		case ">=":
+			if ret < 0 {
+				return false
+			}
+		case "<=":
+			if ret > 0 {
+				return false
+			}
+		case ">":
+			if ret <= 0 {
+				return false
+			}
+		case "<":
+			if ret >= 0 {
+				return false
+			}
+		case "!=":
+			if ret == 0 {
+				return false
+			}
+		default:
+			if ret != 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// compareVersion 按点分隔逐段比较版本号，如1.10.0>1.9.2，缺省的段视为0
+func compareVersion(a string, b string) int {
+	aPart := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bPart := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(aPart) || i < len(bPart); i++ {
+		aValue, bValue := "0", "0"
+		if i < len(aPart) {
+			aValue = aPart[i]
+		}
+		if i < len(bPart) {
+			bValue = bPart[i]
+		}
+
+		aNum, aErr := strconv.Atoi(aValue)
+		bNum, bErr := strconv.Atoi(bValue)
+		if aErr == nil && bErr == nil {
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+
+		if ret := strings.Compare(aValue, bValue); ret != 0 {
+			return ret
+		}
+	}
+
+	return 0
+}
+
+func (route *VersionRoute) init() error {
+	var err error
+	if route.CanaryPercent < 0 || route.CanaryPercent > 100 {
+		return fmt.Errorf("canary percent %d is error", route.CanaryPercent)
+	}
+
+	route.version, err = parseVersionRange(route.Version)
+	if err != nil {
+		return err
+	}
+
+	route.canaryVersion, err = parseVersionRange(route.CanaryVersion)
+	return err
+}
+
+// SetVersionRoute 设置本结点调用serviceName的版本路由，route为nil时清除路由
+func (cls *Cluster) SetVersionRoute(serviceName string, route *VersionRoute) error {
+	if route == nil {
+		cls.locker.Lock()
+		delete(cls.mapVersionRoute, serviceName)
+		cls.locker.Unlock()
+		return nil
+	}
+
+	newRoute := *route
+	if err := newRoute.init(); err != nil {
+		return err
+	}
+
+	cls.locker.Lock()
+	defer cls.locker.Unlock()
+	cls.mapVersionRoute[serviceName] = &newRoute
+	return nil
+}
+
+func (cls *Cluster) GetVersionRoute(serviceName string) (VersionRoute, bool) {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	route, ok := cls.mapVersionRoute[serviceName]
+	if ok == false {
+		return VersionRoute{}, false
+	}
+
+	return *route, true
+}
+
+// newVersionRouteMap 校验配置中的版本路由
+func newVersionRouteMap(mapRoute map[string]VersionRoute) (map[string]*VersionRoute, error) {
+	mapVersionRoute := make(map[string]*VersionRoute, len(mapRoute))
+	for serviceName, route := range mapRoute {
+		newRoute := route
+		if err := newRoute.init(); err != nil {
+			return nil, fmt.Errorf("service %s version route is error:%s", serviceName, err)
+		}
+		mapVersionRoute[serviceName] = &newRoute
+	}
+
+	return mapVersionRoute, nil
+}
+
+func (cls *Cluster) initVersionRoute() error {
+	var err error
+	cls.mapVersionRoute, err = newVersionRouteMap(cls.localNodeInfo.VersionRoute)
+	cls.cfgVersionRoute = cls.localNodeInfo.VersionRoute
+	return err
+}
+
+// updateVersionRoute 配置中心下发的版本路由，为nil时使用NodeList中本结点的配置。
+// 只在配置的路由变化时整体替换，不覆盖运行期间通过SetVersionRoute的修改
+func (cls *Cluster) updateVersionRoute(mapRoute map[string]VersionRoute, mapVersionRoute map[string]*VersionRoute) {
+	cls.locker.Lock()
+	defer cls.locker.Unlock()
+
+	if reflect.DeepEqual(cls.cfgVersionRoute, mapRoute) {
+		return
+	}
+
+	log.Infof("version route is changed by config center")
+	cls.cfgVersionRoute = mapRoute
+	cls.mapVersionRoute = mapVersionRoute
+}
+
+func (cls *Cluster) getServiceVersion(nodeId string, serviceName string) string {
+	nodeRpc, ok := cls.mapRpc[nodeId]
+	if ok == false {
+		return ""
+	}
+
+	return nodeRpc.nodeInfo.ServiceVersion[serviceName]
+}
+
+// RouteRpcClient 按版本路由从候选结点中选择，只用于调用单个结点，广播调用不经过版本路由
+func RouteRpcClient(serviceMethod string, clientList []*rpc.Client) []*rpc.Client {
+	serviceName, _, _ := strings.Cut(serviceMethod, ".")
+	return GetCluster().routeVersion(serviceName, clientList)
+}
+
+// routeVersion 按版本路由筛选结点，灰度与非灰度版本中一方无可用结点时使用另一方
+func (cls *Cluster) routeVersion(serviceName string, clientList []*rpc.Client) []*rpc.Client {
+	cls.locker.RLock()
+	defer cls.locker.RUnlock()
+
+	route, ok := cls.mapVersionRoute[serviceName]
+	if ok == false {
+		return clientList
+	}
+
+	var stableClient, canaryClient []*rpc.Client
+	for _, client := range clientList {
+		version := cls.getServiceVersion(client.GetTargetNodeId(), serviceName)
+		if len(route.canaryVersion) > 0 && route.canaryVersion.match(version) {
+			canaryClient = append(canaryClient, client)
+		} else if route.version.match(version) {
+			stableClient = append(stableClient, client)
+		}
+	}
+
+	if len(canaryClient) == 0 {
+		return stableClient
+	}
+
+	if len(stableClient) == 0 || rand.Intn(100) < route.CanaryPercent {
+		return canaryClient
+	}
+
+	return stableClient
+}
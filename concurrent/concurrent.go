@@ -1,11 +1,13 @@
 package concurrent
 
 import (
+	"context"
 	"errors"
 	"runtime"
 
 	"github.com/duanhf2012/origin/v2/log"
 	"sync/atomic"
+	"time"
 )
 
 const defaultMaxTaskChannelNum = 1000000
@@ -15,14 +17,16 @@ type IConcurrent interface {
 	OpenConcurrent(minGoroutineNum int32, maxGoroutineNum int32, maxTaskChannelNum int)
 	AsyncDoByQueue(queueId int64, fn func() bool, cb func(err error))
 	AsyncDo(f func() bool, cb func(err error))
+	GetStopContext() context.Context
 }
 
 type Concurrent struct {
 	dispatch
 
-	tasks     chan task
-	cbChannel chan func(error)
-	open      int32
+	tasks        chan task
+	cbChannel    chan func(error)
+	open         int32
+	closeTimeout time.Duration
 }
 
 /*
@@ -53,6 +57,12 @@ func (c *Concurrent) AsyncDo(f func() bool, cb func(err error)) {
 }
 
 func (c *Concurrent) AsyncDoByQueue(queueId int64, fn func() bool, cb func(err error)) {
+	c.AsyncDoByQueueTo(nil, queueId, fn, cb)
+}
+
+// AsyncDoByQueueTo 与AsyncDoByQueue相同，cb投递到cbChannel中，由读取cbChannel的协程调用DoCallback执行。
+// cbChannel为nil时投递到GetCallBackChannel，用于服务的并行工作协程将回调返回到发起的工作协程
+func (c *Concurrent) AsyncDoByQueueTo(cbChannel chan func(error), queueId int64, fn func() bool, cb func(err error)) {
 	if cap(c.tasks) == 0 {
 		panic("not open concurrent")
 	}
@@ -63,7 +73,7 @@ func (c *Concurrent) AsyncDoByQueue(queueId int64, fn func() bool, cb func(err e
 	}
 
 	if fn == nil {
-		c.pushAsyncDoCallbackEvent(cb)
+		c.pushAsyncDoCallbackEvent(cb, cbChannel)
 		return
 	}
 
@@ -72,18 +82,23 @@ func (c *Concurrent) AsyncDoByQueue(queueId int64, fn func() bool, cb func(err e
 	}
 
 	select {
-	case c.tasks <- task{queueId, fn, cb}:
+	case c.tasks <- task{queueId, fn, cb, cbChannel}:
 	default:
 		log.Error("tasks channel is full")
 		if cb != nil {
 			c.pushAsyncDoCallbackEvent(func(err error) {
 				cb(errors.New("tasks channel is full"))
-			})
+			}, cbChannel)
 		}
 		return
 	}
 }
 
+// SetCloseTimeout 设置Close等待正在执行任务的超时时间，为0表示一直等待
+func (c *Concurrent) SetCloseTimeout(timeout time.Duration) {
+	c.closeTimeout = timeout
+}
+
 func (c *Concurrent) Close() {
 	if cap(c.tasks) == 0 {
 		return
@@ -91,11 +106,23 @@ func (c *Concurrent) Close() {
 
 	log.Info("wait close concurrent")
 
-	c.dispatch.close()
+	if c.dispatch.close(c.closeTimeout) == false {
+		log.Errorf("concurrent close timeout,some tasks are still running,timeout:%s", c.closeTimeout)
+		return
+	}
 
 	log.Info("concurrent has successfully exited")
 }
 
+// GetStopContext 获取并发协程的停止context，服务停止关闭Concurrent时取消
+func (c *Concurrent) GetStopContext() context.Context {
+	if c.cancelContext == nil {
+		return context.Background()
+	}
+
+	return c.cancelContext
+}
+
 func (c *Concurrent) GetCallBackChannel() chan func(error) {
 	return c.cbChannel
 }
@@ -161,19 +161,30 @@ func (d *dispatch) pushQueueTaskFinishEvent(queueId int64) {
 	d.queueIdChannel <- queueId
 }
 
-func (d *dispatch) pushAsyncDoCallbackEvent(cb func(err error)) {
+func (d *dispatch) pushAsyncDoCallbackEvent(cb func(err error), cbChannel chan func(error)) {
 	if cb == nil {
 		//不需要回调的情况
 		return
 	}
 
-	d.cbChannel <- cb
+	if cbChannel == nil {
+		cbChannel = d.cbChannel
+	}
+	cbChannel <- cb
 }
 
-func (d *dispatch) close() {
+// close 等待所有任务执行完成，超过timeout返回false，timeout为0表示一直等待
+func (d *dispatch) close(timeout time.Duration) bool {
 	atomic.StoreInt32(&d.minConcurrentNum, -1)
 	d.cancel()
 
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		closeTimer := time.NewTimer(timeout)
+		defer closeTimer.Stop()
+		timeoutC = closeTimer.C
+	}
+
 breakFor:
 	for {
 		select {
@@ -182,10 +193,13 @@ breakFor:
 				break breakFor
 			}
 			cb(nil)
+		case <-timeoutC:
+			return false
 		}
 	}
 
 	d.waitDispatch.Wait()
+	return true
 }
 
 func (d *dispatch) DoCallback(cb func(err error)) {
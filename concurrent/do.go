@@ -0,0 +1,46 @@
+package concurrent
+
+import (
+	"context"
+)
+
+// Do 在并发协程中执行fn，返回值与错误在服务协程中回调cb，cb可以为nil。
+// fn收到的ctx在ctx被取消(如超时)或服务停止时取消，执行前ctx已经取消时不再执行fn，直接以ctx.Err()回调
+func Do[T any](c IConcurrent, ctx context.Context, fn func(ctx context.Context) (T, error), cb func(T, error)) {
+	DoByQueue(c, 0, ctx, fn, cb)
+}
+
+// DoByQueue 与Do相同，queueId相同的任务按提交顺序依次执行，与AsyncDoByQueue一致
+func DoByQueue[T any](c IConcurrent, queueId int64, ctx context.Context, fn func(ctx context.Context) (T, error), cb func(T, error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var result T
+	var err error
+	var doCb func(error)
+	if cb != nil {
+		doCb = func(cbErr error) {
+			//fn发生panic或者任务队列已满
+			if cbErr != nil {
+				err = cbErr
+			}
+
+			cb(result, err)
+		}
+	}
+
+	stopCtx := c.GetStopContext()
+	c.AsyncDoByQueue(queueId, func() bool {
+		taskCtx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(stopCtx, cancel)
+		defer stop()
+		defer cancel()
+
+		if err = taskCtx.Err(); err == nil {
+			result, err = fn(taskCtx)
+		}
+
+		return doCb != nil
+	}, doCb)
+}
@@ -0,0 +1,184 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type doResult struct {
+	value int
+	err   error
+}
+
+func newTestConcurrent() *Concurrent {
+	c := &Concurrent{}
+	c.OpenConcurrent(1, 4, 100)
+	return c
+}
+
+// waitCallback 模拟服务协程执行回调，返回cb收到的结果
+func waitCallback(t *testing.T, c *Concurrent, result chan doResult) doResult {
+	for {
+		select {
+		case r := <-result:
+			return r
+		case cb := <-c.GetCallBackChannel():
+			c.DoCallback(cb)
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait callback timeout")
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name      string
+		ctx       func() (context.Context, context.CancelFunc)
+		fn        func(ctx context.Context) (int, error)
+		wantValue int
+		wantErr   error //为nil时只检查是否有错误
+		hasErr    bool
+	}{
+		{
+			name: "Success",
+			ctx:  func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+			fn: func(ctx context.Context) (int, error) {
+				return 1, nil
+			},
+			wantValue: 1,
+		},
+		{
+			name: "Deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			fn: func(ctx context.Context) (int, error) {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			},
+			wantErr: context.DeadlineExceeded,
+			hasErr:  true,
+		},
+		{
+			name: "CanceledBeforeRun",
+			ctx:  func() (context.Context, context.CancelFunc) { return canceledCtx, func() {} },
+			fn: func(ctx context.Context) (int, error) {
+				panic("fn should not be executed")
+			},
+			wantErr: context.Canceled,
+			hasErr:  true,
+		},
+		{
+			name: "Panic",
+			ctx:  func() (context.Context, context.CancelFunc) { return nil, func() {} },
+			fn: func(ctx context.Context) (int, error) {
+				panic("do panic")
+			},
+			hasErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestConcurrent()
+			defer c.Close()
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+
+			result := make(chan doResult, 1)
+			Do(c, ctx, tc.fn, func(value int, err error) {
+				result <- doResult{value: value, err: err}
+			})
+
+			r := waitCallback(t, c, result)
+			if r.value != tc.wantValue || (r.err != nil) != tc.hasErr {
+				t.Fatalf("result is %+v,expect value %d,has error %t", r, tc.wantValue, tc.hasErr)
+			}
+			if tc.wantErr != nil && errors.Is(r.err, tc.wantErr) == false {
+				t.Fatalf("error is %v,expect %v", r.err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoCancelOnClose(t *testing.T) {
+	c := newTestConcurrent()
+
+	started := make(chan struct{})
+	result := make(chan doResult, 1)
+	Do(c, context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, func(value int, err error) {
+		result <- doResult{value: value, err: err}
+	})
+
+	<-started
+	//Close取消正在执行的fn，并执行剩余的回调
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close timeout")
+	}
+
+	select {
+	case r := <-result:
+		if errors.Is(r.err, context.Canceled) == false {
+			t.Fatalf("error is %v,expect %v", r.err, context.Canceled)
+		}
+	default:
+		t.Fatal("callback is not executed on close")
+	}
+}
+
+func TestDoByQueueOrder(t *testing.T) {
+	c := newTestConcurrent()
+	defer c.Close()
+
+	const taskNum = 20
+	var locker sync.Mutex
+	var orderList []int
+	result := make(chan doResult, taskNum)
+	for i := 0; i < taskNum; i++ {
+		index := i
+		DoByQueue(c, 1, context.Background(), func(ctx context.Context) (int, error) {
+			//先提交的任务执行得更慢，不按顺序执行时会被后提交的任务超过
+			time.Sleep(time.Duration(taskNum-index) * time.Millisecond)
+			locker.Lock()
+			orderList = append(orderList, index)
+			locker.Unlock()
+			return index, nil
+		}, func(value int, err error) {
+			result <- doResult{value: value, err: err}
+		})
+	}
+
+	for i := 0; i < taskNum; i++ {
+		r := waitCallback(t, c, result)
+		if r.err != nil || r.value != i {
+			t.Fatalf("callback %d is %+v", i, r)
+		}
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+	for i, index := range orderList {
+		if index != i {
+			t.Fatalf("tasks in the same queue run out of order:%v", orderList)
+		}
+	}
+}
@@ -10,9 +10,10 @@ import (
 )
 
 type task struct {
-	queueId int64
-	fn      func() bool
-	cb      func(err error)
+	queueId   int64
+	fn        func() bool
+	cb        func(err error)
+	cbChannel chan func(error) //回调投递的管道，为nil时投递到Concurrent的回调管道
 }
 
 type worker struct {
@@ -66,7 +67,7 @@ func (w *worker) exec(t *task) {
 
 func (w *worker) endCallFun(isDoCallBack bool, t *task) {
 	if isDoCallBack {
-		w.pushAsyncDoCallbackEvent(t.cb)
+		w.pushAsyncDoCallbackEvent(t.cb, t.cbChannel)
 	}
 
 	if t.queueId != 0 {
@@ -7,6 +7,8 @@ import "github.com/go-viper/mapstructure/v2"
 
 func SetClusterPath(path string) { GetConfig(Cluster).path = path }
 
+func GetClusterPath() string { return GetConfig(Cluster).path }
+
 func deepMerge(dest, src map[string]interface{}) {
 	for key, srcVal := range src {
 
@@ -35,12 +37,29 @@ func deepMerge(dest, src map[string]interface{}) {
 }
 
 func ClusterLoad() error {
+	if GetConfig(Cluster).value == nil {
+		GetConfig(Cluster).value = map[string]interface{}{}
+	}
+
 	a := GetConfig(Cluster).Load(func(src map[string]interface{}) {
 		deepMerge(GetConfig(Cluster).value, src)
 	})
 	return a
 }
 
+// ClusterReload 重新读取集群配置，返回新的配置数据，不会替换当前已加载的配置
+func ClusterReload() (map[string]interface{}, error) {
+	value := map[string]interface{}{}
+	err := GetConfig(Cluster).Load(func(src map[string]interface{}) {
+		deepMerge(value, src)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
 func GetSystemConfigParse(key string, res interface{}) error {
 	if m, ok := GetConfig(Cluster).value[key]; ok {
 		err := mapstructure.Decode(m, res)
@@ -18,6 +18,12 @@ const (
 	Sys_Event_EtcdDiscovery   EventType = -11
 	Sys_Event_Gin_Event       EventType = -12
 	Sys_Event_FrameTick       EventType = -13
+	Sys_Event_RedisDiscovery  EventType = -14
+	Sys_Event_LocalNodeInfo   EventType = -15
+	Sys_Event_Leader          EventType = -16
+	Sys_Event_Lock            EventType = -17
+	Sys_Event_GossipDiscovery EventType = -18
+	Sys_Event_ConfigChanged   EventType = -19
 
 	Sys_Event_User_Define EventType = 1
 )
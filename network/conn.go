@@ -21,6 +21,13 @@ type Conn interface {
 
 type ConnSet map[net.Conn]struct{}
 
+var clientConnNum int64 //网络模块上的客户端连接数，不含结点间Rpc连接
+
+// GetClientConnNum 获取当前客户端连接数
+func GetClientConnNum() int64 {
+	return atomic.LoadInt64(&clientConnNum)
+}
+
 type NetConn struct {
 	sync.Mutex
 	conn      net.Conn
@@ -5,6 +5,7 @@ import (
 	"github.com/duanhf2012/origin/v2/network/processor"
 	"github.com/xtaci/kcp-go/v5"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -228,6 +229,7 @@ func (kp *KCPServer) run(listener *kcp.Listener) bool {
 	netConn := newNetConn(conn, kp.kcpCfg.PendingWriteNum, &kp.msgParser, *kp.kcpCfg.WriteDeadlineMill)
 	agent := kp.NewAgent(netConn)
 	kp.wgConns.Add(1)
+	atomic.AddInt64(&clientConnNum, 1)
 	go func() {
 		agent.Run()
 		// cleanup
@@ -236,6 +238,7 @@ func (kp *KCPServer) run(listener *kcp.Listener) bool {
 		delete(kp.conns, conn)
 		kp.mutexConns.Unlock()
 		agent.OnClose()
+		atomic.AddInt64(&clientConnNum, -1)
 
 		kp.wgConns.Done()
 	}()
@@ -7,6 +7,7 @@ import (
 	"github.com/duanhf2012/origin/v2/util/bytespool"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,6 +27,7 @@ type TCPServer struct {
 	PendingWriteNum int
 	ReadDeadline    time.Duration
 	WriteDeadline   time.Duration
+	IsRpcServer     bool //结点间Rpc连接不计入客户端连接数
 
 	NewAgent   func(conn Conn) Agent
 	ln         net.Listener
@@ -153,6 +155,9 @@ func (server *TCPServer) run() {
 		server.conns[conn] = struct{}{}
 		server.mutexConns.Unlock()
 		server.wgConns.Add(1)
+		if server.IsRpcServer == false {
+			atomic.AddInt64(&clientConnNum, 1)
+		}
 
 		tcpConn := newNetConn(conn, server.PendingWriteNum, &server.MsgParser, server.WriteDeadline)
 		agent := server.NewAgent(tcpConn)
@@ -165,6 +170,9 @@ func (server *TCPServer) run() {
 			delete(server.conns, conn)
 			server.mutexConns.Unlock()
 			agent.OnClose()
+			if server.IsRpcServer == false {
+				atomic.AddInt64(&clientConnNum, -1)
+			}
 
 			server.wgConns.Done()
 		}()
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -73,6 +74,7 @@ func (handler *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	handler.conns[conn] = struct{}{}
 	handler.mutexConns.Unlock()
+	atomic.AddInt64(&clientConnNum, 1)
 
 	conn.UnderlyingConn().(*net.TCPConn).SetLinger(0)
 	conn.UnderlyingConn().(*net.TCPConn).SetNoDelay(true)
@@ -86,6 +88,7 @@ func (handler *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	delete(handler.conns, conn)
 	handler.mutexConns.Unlock()
 	agent.OnClose()
+	atomic.AddInt64(&clientConnNum, -1)
 }
 
 func (server *WSServer) SetMessageType(messageType int) {
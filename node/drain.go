@@ -0,0 +1,105 @@
+package node
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/cluster"
+	"github.com/duanhf2012/origin/v2/log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	drainCheckInterval = time.Second //排空检查间隔
+	drainIdleTimes     = 3           //连续空闲的检查次数，避免服务发现等周期性调用造成误判
+)
+
+var drainTimeout time.Duration
+var retireDrainSecond int //-drain命令指定的排空时长(秒)
+var drainIdleCount int
+var lastDrainStatus cluster.DrainStatus
+
+// OpenRetireDrain 开启退休排空，收到退休信号后持续检查结点负载，空闲或超过timeout后自动停止结点。
+// 结点配置了RetireDrainSecond时以配置为准
+func OpenRetireDrain(timeout time.Duration) {
+	drainTimeout = timeout
+}
+
+func getDrainFilePath(nodeId string) string {
+	return fmt.Sprintf("%s_%s.drain", os.Args[0], nodeId)
+}
+
+func setRetireDrain(args interface{}) error {
+	retireDrainSecond = args.(int)
+	if retireDrainSecond < 0 {
+		return fmt.Errorf("invalid option -drain %d", retireDrainSecond)
+	}
+
+	return nil
+}
+
+// writeRetireDrain -retire与-drain一起使用时，将排空时长写入文件，由退休的进程在收到退休信号时读取
+func writeRetireDrain(nodeId string) error {
+	if retireDrainSecond <= 0 {
+		return nil
+	}
+
+	return os.WriteFile(getDrainFilePath(nodeId), []byte(strconv.Itoa(retireDrainSecond)), 0600)
+}
+
+// readRetireDrain 读取-drain命令指定的排空时长，没有指定时使用配置或者OpenRetireDrain设置的时长
+func readRetireDrain(nodeId string) time.Duration {
+	filePath := getDrainFilePath(nodeId)
+	byteDrain, err := os.ReadFile(filePath)
+	if err != nil {
+		return drainTimeout
+	}
+	os.Remove(filePath)
+
+	second, err := strconv.Atoi(strings.TrimSpace(string(byteDrain)))
+	if err != nil || second <= 0 {
+		log.Errorf("invalid drain file %s,content:%s", filePath, byteDrain)
+		return drainTimeout
+	}
+
+	return time.Duration(second) * time.Second
+}
+
+func startDrain(timeout time.Duration) *time.Ticker {
+	cluster.GetCluster().StartDrain(timeout)
+	log.Infof("start drain,timeout:%s", timeout)
+
+	return time.NewTicker(drainCheckInterval)
+}
+
+// checkDrain 检查排空进度，返回true表示结点可以停止
+func checkDrain() bool {
+	status := cluster.GetCluster().GetDrainStatus()
+	if status.IsIdle() {
+		drainIdleCount++
+	} else {
+		drainIdleCount = 0
+	}
+
+	if drainIdleCount >= drainIdleTimes {
+		log.Infof("drain is completed,cost:%s", time.Since(status.StartTime))
+		return true
+	}
+
+	if time.Now().After(status.Deadline) {
+		log.Warnf("drain deadline is reached,inbound rpc:%d,pending call:%d,client conn:%d,pending event:%d",
+			status.InboundRpcNum, status.PendingCallNum, status.ClientConnNum, status.PendingEventNum)
+		return true
+	}
+
+	//进度有变化时才输出
+	if status.InboundRpcNum != lastDrainStatus.InboundRpcNum || status.PendingCallNum != lastDrainStatus.PendingCallNum ||
+		status.ClientConnNum != lastDrainStatus.ClientConnNum || status.PendingEventNum != lastDrainStatus.PendingEventNum {
+		log.Infof("draining,inbound rpc:%d,pending call:%d,client conn:%d,pending event:%d,remaining time:%s",
+			status.InboundRpcNum, status.PendingCallNum, status.ClientConnNum, status.PendingEventNum, time.Until(status.Deadline).Round(time.Second))
+	}
+	lastDrainStatus = status
+
+	return false
+}
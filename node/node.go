@@ -1,6 +1,7 @@
 package node
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/duanhf2012/origin/v2/cluster"
 	"github.com/duanhf2012/origin/v2/config"
@@ -27,14 +28,24 @@ var nodeId string
 var preSetupService []service.IService //预安装
 var preSetupTemplateService []func() service.IService
 var profilerInterval time.Duration
+var watchdogThreshold time.Duration
+var watchdogExitTimeout time.Duration
+var nodeStopTimeout = service.DefaultStopAllTimeout //小于0表示一直等待
+var timingWheelOpen bool
 var configDir = "./config/"
 var NodeIsRun = false
 
 const (
 	SingleStop   syscall.Signal = 10
 	SignalRetire syscall.Signal = 12
+	SignalStatus syscall.Signal = 30
 )
 
+const statusWaitTime = 5 * time.Second
+
+// forceExitDelay 所有服务停止超时后，留给集群停止与日志输出的时间
+const forceExitDelay = 5 * time.Second
+
 type BuildOSType = int8
 
 const (
@@ -45,13 +56,15 @@ const (
 
 func init() {
 	sig = make(chan os.Signal, 4)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, SingleStop, SignalRetire)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, SingleStop, SignalRetire, SignalStatus)
 
 	console.RegisterCommandBool("help", false, "<-help> This help.", usage)
 	console.RegisterCommandString("name", "", "<-name nodeName> Node's name.", setName)
 	console.RegisterCommandString("start", "", "<-start nodeid=nodeid> Run originserver.", startNode)
 	console.RegisterCommandString("stop", "", "<-stop nodeid=nodeid> Stop originserver process.", stopNode)
+	console.RegisterCommandInt("drain", 0, "<-drain seconds> Used with -retire,stop the retired process after draining for at most seconds.", setRetireDrain)
 	console.RegisterCommandString("retire", "", "<-retire nodeid=nodeid> retire originserver process.", retireNode)
+	console.RegisterCommandString("status", "", "<-status nodeid=nodeid> Print the cluster status of originserver process.", statusNode)
 	console.RegisterCommandString("config", "", "<-config path> Configuration file path.", setConfigPath)
 	//console.RegisterCommandString("console", "", "<-console true|false> Turn on or off screen log output.", openConsole)
 	//console.RegisterCommandString("loglevel", "debug", "<-loglevel debug|info|warn|error|stackerror|fatal> Set loglevel.", setLevel)
@@ -70,6 +83,7 @@ func Start() {
 
 func notifyAllServiceRetire() {
 	service.NotifyAllServiceRetire()
+	cluster.GetCluster().RetireSingleton()
 }
 
 func usage(val interface{}) error {
@@ -168,49 +182,21 @@ func initNode(id string) {
 		os.Exit(1)
 	}
 
+	if drainSecond := cluster.GetCluster().GetLocalNodeInfo().RetireDrainSecond; drainSecond > 0 {
+		drainTimeout = time.Duration(drainSecond) * time.Second
+	}
+
 	//2.顺序安装服务
+	service.SetLocalNodeId(nodeId)
 	serviceOrder := cluster.GetCluster().GetLocalNodeInfo().ServiceList
 	for _, serviceName := range serviceOrder {
-		bSetup := false
-
-		//判断是否有配置模板服务
-		splitServiceName := strings.Split(serviceName, ":")
-		if len(splitServiceName) == 2 {
-			serviceName = splitServiceName[0]
-			templateServiceName := splitServiceName[1]
-			for _, newSer := range preSetupTemplateService {
-				ser := newSer()
-				ser.OnSetup(ser)
-				if ser.GetName() == templateServiceName {
-					ser.SetName(serviceName)
-					ser.Init(ser, cluster.GetRpcClient, cluster.GetRpcServer, cluster.GetCluster().GetServiceCfg(ser.GetName()))
-					service.Setup(ser)
-
-					bSetup = true
-					break
-				}
-			}
-
-			if bSetup == false {
-				fmt.Printf("Template service not found,service name:%s,template service name:%s", templateServiceName)
-				os.Exit(1)
-			}
-		}
-
-		for _, s := range preSetupService {
-			if s.GetName() != serviceName {
-				continue
-			}
-			bSetup = true
-			pServiceCfg := cluster.GetCluster().GetServiceCfg(s.GetName())
-			s.Init(s, cluster.GetRpcClient, cluster.GetRpcServer, pServiceCfg)
-
-			service.Setup(s)
-		}
-
-		if bSetup == false {
+		s := newSetupService(serviceName)
+		if s == nil {
 			log.Fatal("Service name " + serviceName + " configuration error")
 		}
+
+		s.Init(s, cluster.GetRpcClient, cluster.GetRpcServer, cluster.GetCluster().GetServiceCfg(s.GetName()))
+		service.Setup(s)
 	}
 
 	//3.service初始化
@@ -242,10 +228,86 @@ func retireNode(args interface{}) error {
 		return err
 	}
 
+	if err = writeRetireDrain(nId); err != nil {
+		return err
+	}
+
 	RetireProcess(processId)
+	if retireDrainSecond > 0 {
+		fmt.Printf("node %s starts draining,use -status nodeid=%s to show the progress\n", nId, nId)
+	}
 	return nil
 }
 
+func getStatusFilePath(nodeId string) string {
+	return fmt.Sprintf("%s_%s.status", os.Args[0], nodeId)
+}
+
+// writeClusterStatus 将集群快照写入状态文件，由-status命令读取
+func writeClusterStatus(nodeId string) {
+	byteStatus, err := json.MarshalIndent(cluster.GetCluster().GetClusterStatus(), "", "  ")
+	if err != nil {
+		log.Errorf("marshal cluster status fail:%s", err)
+		return
+	}
+
+	//先写临时文件再改名，避免读取到不完整的内容
+	filePath := getStatusFilePath(nodeId)
+	err = os.WriteFile(filePath+".tmp", byteStatus, 0600)
+	if err == nil {
+		err = os.Rename(filePath+".tmp", filePath)
+	}
+
+	if err != nil {
+		log.Errorf("write cluster status fail:%s", err)
+	}
+}
+
+func statusNode(args interface{}) error {
+	//1.解析参数
+	param := args.(string)
+	if param == "" {
+		return nil
+	}
+
+	sParam := strings.Split(param, "=")
+	if len(sParam) != 2 {
+		return fmt.Errorf("invalid option %s", param)
+	}
+	if sParam[0] != "nodeid" {
+		return fmt.Errorf("invalid option %s", param)
+	}
+	nId := strings.TrimSpace(sParam[1])
+	if nId == "" {
+		return fmt.Errorf("invalid option %s", param)
+	}
+
+	processId, err := getRunProcessPid(nId)
+	if err != nil {
+		return err
+	}
+
+	//2.通知进程写入状态文件并等待
+	filePath := getStatusFilePath(nId)
+	os.Remove(filePath)
+	if err = StatusProcess(processId); err != nil {
+		return err
+	}
+
+	for deadline := time.Now().Add(statusWaitTime); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		byteStatus, rErr := os.ReadFile(filePath)
+		if rErr != nil {
+			continue
+		}
+
+		os.Remove(filePath)
+		fmt.Println(string(byteStatus))
+		return nil
+	}
+
+	return fmt.Errorf("wait for status of processid %d timeout", processId)
+}
+
 func stopNode(args interface{}) error {
 	//1.解析参数
 	param := args.(string)
@@ -327,11 +389,14 @@ func startNode(args interface{}) error {
 
 	//2.记录进程id号
 	writeProcessPid(strNodeId)
-	timer.StartTimer(10*time.Millisecond, 1000000)
+	//清理上次运行遗留的排空时长
+	os.Remove(getDrainFilePath(strNodeId))
+	startTimer()
 
 	initNode(strNodeId)
 
 	//4.运行service
+	startWatchdog()
 	service.Start()
 
 	//5.运行集群
@@ -347,27 +412,47 @@ func startNode(args interface{}) error {
 		pProfilerTicker = time.NewTicker(profilerInterval)
 	}
 
+	var drainC <-chan time.Time
 	NodeIsRun = true
 	for NodeIsRun {
 		select {
 		case s := <-sig:
 			signal := s.(syscall.Signal)
-			if signal == SignalRetire {
+			if signal == SignalStatus {
+				writeClusterStatus(strNodeId)
+			} else if signal == SignalRetire {
 				log.Info("receipt retire signal.")
 				notifyAllServiceRetire()
+				if timeout := readRetireDrain(strNodeId); timeout > 0 && drainC == nil {
+					drainTicker := startDrain(timeout)
+					defer drainTicker.Stop()
+					drainC = drainTicker.C
+				}
 			} else {
 				NodeIsRun = false
 				log.Info("receipt stop signal.")
 			}
 		case <-pProfilerTicker.C:
 			profiler.Report()
+		case <-drainC:
+			if checkDrain() {
+				NodeIsRun = false
+			}
 		}
 	}
 
-	//7.退出
-	service.StopAllService()
+	//7.退出，超时未能退出时强制结束进程
+	if nodeStopTimeout > 0 {
+		time.AfterFunc(nodeStopTimeout+forceExitDelay, forceExit)
+	}
+	allStopped := service.StopAllService()
 	cluster.GetCluster().Stop()
 
+	if allStopped == false {
+		log.Error("Server is stop,but some services are still running.")
+		log.Close()
+		os.Exit(-1)
+	}
 	log.Info("Server is stop.")
 
 	return nil
@@ -415,6 +500,62 @@ func OpenProfilerReport(interval time.Duration) {
 	profilerInterval = interval
 }
 
+// SetStopTimeout 设置每个服务默认的停止超时时间与结点停止的总超时时间。serviceTimeout默认为0，只受总超时时间限制，
+// nodeTimeout为0时使用service.DefaultStopAllTimeout，为service.StopWaitForever时一直等待。
+// 超时时打印仍在运行的服务协程并强制退出进程，服务可以通过Service.SetStopTimeout单独设置
+func SetStopTimeout(serviceTimeout time.Duration, nodeTimeout time.Duration) {
+	if nodeTimeout == 0 {
+		nodeTimeout = service.DefaultStopAllTimeout
+	}
+
+	service.SetStopTimeout(serviceTimeout, nodeTimeout)
+	nodeStopTimeout = nodeTimeout
+}
+
+func forceExit() {
+	log.Error("node stop timeout,force exit.")
+	log.Close()
+	os.Exit(-1)
+}
+
+// OpenWatchdog 打开服务看门狗，服务处理一项超过threshold时打印该协程的调用栈，
+// exitTimeout大于0时，处理超过exitTimeout则退出进程，由外部守护进程重新拉起
+func OpenWatchdog(threshold time.Duration, exitTimeout time.Duration) {
+	watchdogThreshold = threshold
+	watchdogExitTimeout = exitTimeout
+}
+
+func startWatchdog() {
+	if watchdogThreshold <= 0 {
+		return
+	}
+
+	if watchdogExitTimeout > 0 {
+		service.SetWatchdogExit(watchdogExitTimeout, func(info *service.StuckInfo) {
+			log.Errorf("service is stuck too long and the node exits,serviceName:%s,tag:%s,elapsed:%s\n%s", info.ServiceName, info.Tag, info.Elapsed, info.Stack)
+			log.Close()
+			os.Exit(-1)
+		})
+	}
+
+	service.StartWatchdog(watchdogThreshold)
+}
+
+// OpenTimingWheel 使用分层时间轮代替最小堆管理定时器，同时存在大量定时器(如百万级)时添加与触发的开销更低，
+// 定时器精度为10ms。需要在node.Start之前调用
+func OpenTimingWheel() {
+	timingWheelOpen = true
+}
+
+func startTimer() {
+	if timingWheelOpen == true {
+		timer.StartTimingWheel(10 * time.Millisecond)
+		return
+	}
+
+	timer.StartTimer(10*time.Millisecond, 1000000)
+}
+
 //func openConsole(args interface{}) error {
 //	if args == "" {
 //		return nil
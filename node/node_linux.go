@@ -28,3 +28,12 @@ func RetireProcess(processId int){
 		fmt.Printf("retire processid %d is successful.\n",processId)
 	}
 }
+
+func StatusProcess(processId int) error{
+	err := syscall.Kill(processId,SignalStatus)
+	if err != nil {
+		return fmt.Errorf("notify processid %d is fail:%+v",processId,err)
+	}
+
+	return nil
+}
@@ -27,3 +27,7 @@ func GetBuildOSType() BuildOSType{
 func RetireProcess(processId int){
 	fmt.Printf("This command does not support Windows")
 }
+
+func StatusProcess(processId int) error{
+	return fmt.Errorf("this command does not support Windows")
+}
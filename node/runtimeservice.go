@@ -0,0 +1,122 @@
+package node
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/cluster"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var runtimeServiceLocker sync.Mutex
+
+// mapDiscardService 已卸载或安装失败的预安装服务实例，Init后的实例不能再次安装
+var mapDiscardService = map[service.IService]struct{}{}
+
+func init() {
+	cluster.InstallServiceFun = InstallService
+	cluster.UninstallServiceFun = UninstallService
+}
+
+// newSetupService 查找预安装的服务，serviceName为"服务名:模板服务名"时创建新的模板服务实例
+func newSetupService(serviceName string) service.IService {
+	if name, templateServiceName, ok := strings.Cut(serviceName, ":"); ok == true {
+		for _, newSer := range preSetupTemplateService {
+			ser := newSer()
+			ser.OnSetup(ser)
+			if ser.GetName() == templateServiceName {
+				ser.SetName(name)
+				return ser
+			}
+		}
+
+		return nil
+	}
+
+	//桥接服务与被导出的服务同名，优先使用
+	if s := cluster.GetCluster().GetBridgeService(serviceName); s != nil {
+		return s
+	}
+
+	for _, s := range preSetupService {
+		if s.GetName() == serviceName {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// InstallService 运行期间安装并启动服务，公开的服务会通过服务发现发布
+// serviceName格式与NodeList中ServiceList一致，如"BattleService2:BattleService"，以_开头表示不公开
+func InstallService(serviceName string) error {
+	runtimeServiceLocker.Lock()
+	defer runtimeServiceLocker.Unlock()
+
+	s := newSetupService(strings.TrimLeft(serviceName, "_"))
+	if s == nil {
+		return fmt.Errorf("service %s is not setup", serviceName)
+	}
+
+	if _, ok := mapDiscardService[s]; ok == true {
+		return fmt.Errorf("service %s has been uninstalled and cannot be reinstalled,setup it by node.SetupTemplate to install a new instance", serviceName)
+	}
+
+	if service.GetService(s.GetName()) != nil {
+		return fmt.Errorf("service %s is already installed", s.GetName())
+	}
+
+	s.Init(s, cluster.GetRpcClient, cluster.GetRpcServer, cluster.GetCluster().ReadServiceCfg(s.GetName()))
+	if service.Setup(s) == false {
+		return fmt.Errorf("service %s is already installed", s.GetName())
+	}
+
+	if err := s.OnInit(); err != nil {
+		service.Remove(s.GetName())
+		discardService(s)
+		return fmt.Errorf("failed to initialize %s service,error:%s", s.GetName(), err)
+	}
+
+	s.Start()
+	if err := cluster.GetCluster().AddLocalService(serviceName); err != nil {
+		service.Remove(s.GetName())
+		s.Stop()
+		discardService(s)
+		return err
+	}
+
+	log.Infof("install service %s", serviceName)
+	return nil
+}
+
+// UninstallService 运行期间停止并卸载服务，释放其Module与定时器，不能在被卸载服务的协程中调用
+func UninstallService(serviceName string) error {
+	runtimeServiceLocker.Lock()
+	defer runtimeServiceLocker.Unlock()
+
+	s := service.GetService(serviceName)
+	if s == nil {
+		return fmt.Errorf("service %s is not found", serviceName)
+	}
+
+	//先从服务发现中移除，不再接收新的调用
+	if err := cluster.GetCluster().RemoveLocalService(serviceName); err != nil {
+		return err
+	}
+
+	service.Remove(serviceName)
+	s.Stop()
+	discardService(s)
+
+	log.Infof("uninstall service %s", serviceName)
+	return nil
+}
+
+// discardService 预安装服务只有一个实例，停止后丢弃，模板服务每次安装都会创建新的实例
+func discardService(s service.IService) {
+	if slices.Contains(preSetupService, s) {
+		mapDiscardService[s] = struct{}{}
+	}
+}
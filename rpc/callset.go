@@ -142,3 +142,11 @@ func (cs *CallSet) cleanPending() {
 func (cs *CallSet) generateSeq() uint64 {
 	return atomic.AddUint64(&cs.startSeq, 1)
 }
+
+// GetPendingNum 获取等待返回的调用数
+func (cs *CallSet) GetPendingNum() int {
+	cs.pendingLock.RLock()
+	defer cs.pendingLock.RUnlock()
+
+	return len(cs.pending)
+}
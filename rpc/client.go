@@ -248,7 +248,7 @@ func (client *Client) asyncCall(nodeId string, w IWriter, timeout time.Duration,
 	call := MakeCall()
 	call.Reply = replyParam
 	call.callback = &callback
-	call.rpcHandler = rpcHandler
+	call.setCaller(rpcHandler)
 	call.ServiceMethod = serviceMethod
 	call.Seq = seq
 	call.TimeOut = timeout
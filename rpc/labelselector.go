@@ -0,0 +1,70 @@
+package rpc
+
+import "strings"
+
+// LabelSelector 结点标签选择器，结点标签中必须包含所有的key且值相等才匹配
+// 值配置为"*"时，只要求结点存在该标签。key以PreferLabelPrefix开头时为优先匹配的标签，见Prefer
+type LabelSelector map[string]string
+
+const AnyLabelValue = "*"
+const PreferLabelPrefix = "prefer:"
+
+// Prefer 添加优先匹配的标签，满足选择器的结点中有标签匹配的结点时只选择这些结点，没有时退回到所有满足选择器的结点
+func (selector LabelSelector) Prefer(key string, value string) LabelSelector {
+	if selector == nil {
+		selector = LabelSelector{}
+	}
+
+	selector[PreferLabelPrefix+key] = value
+	return selector
+}
+
+// Match 判断结点标签是否满足选择器，空的选择器匹配所有结点，优先匹配的标签不影响结果
+func (selector LabelSelector) Match(labels map[string]string) bool {
+	for key, value := range selector {
+		if strings.HasPrefix(key, PreferLabelPrefix) {
+			continue
+		}
+
+		if matchLabel(labels, key, value) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasPrefer 是否包含优先匹配的标签
+func (selector LabelSelector) HasPrefer() bool {
+	for key := range selector {
+		if strings.HasPrefix(key, PreferLabelPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchPrefer 判断结点标签是否满足所有优先匹配的标签
+func (selector LabelSelector) MatchPrefer(labels map[string]string) bool {
+	for key, value := range selector {
+		if strings.HasPrefix(key, PreferLabelPrefix) == false {
+			continue
+		}
+
+		if matchLabel(labels, strings.TrimPrefix(key, PreferLabelPrefix), value) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchLabel(labels map[string]string, key string, value string) bool {
+	labelValue, ok := labels[key]
+	if ok == false {
+		return false
+	}
+
+	return value == AnyLabelValue || labelValue == value
+}
@@ -117,6 +117,7 @@ func (server *BaseServer) selfNodeRpcHandlerGo(timeout time.Duration, processor
 		}
 	}
 
+	req.markInbound()
 	err := rpcHandler.PushRpcRequest(req)
 	if err != nil {
 		log.Error(err.Error())
@@ -153,7 +154,7 @@ func (server *BaseServer) selfNodeRpcHandlerAsyncGo(timeout time.Duration, clien
 		callSeq = client.generateSeq()
 		pCall := MakeCall()
 		pCall.Seq = callSeq
-		pCall.rpcHandler = callerRpcHandler
+		pCall.setCaller(callerRpcHandler)
 		pCall.callback = &callback
 		pCall.Reply = reply
 		pCall.ServiceMethod = serviceMethod
@@ -182,6 +183,7 @@ func (server *BaseServer) selfNodeRpcHandlerAsyncGo(timeout time.Duration, clien
 		}
 	}
 
+	req.markInbound()
 	err = rpcHandler.PushRpcRequest(req)
 	if err != nil {
 		ReleaseRpcRequest(req)
@@ -276,6 +278,7 @@ func (server *BaseServer) processRpcRequest(data []byte, connTag string, wrRespo
 		return nil
 	}
 
+	req.markInbound()
 	err = rpcHandler.PushRpcRequest(req)
 	if err != nil {
 		rpcError := RpcError(err.Error())
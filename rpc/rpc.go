@@ -3,6 +3,7 @@ package rpc
 import (
 	"github.com/duanhf2012/origin/v2/util/sync"
 	"reflect"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +17,7 @@ type RpcRequest struct {
 	requestHandle RequestHandler
 	callback *reflect.Value
 	rpcProcessor IRpcProcessor
+	inbound bool //是否计入在途的入站请求
 }
 
 type RpcResponse struct {
@@ -28,6 +30,8 @@ func (r *Responder) IsInvalid() bool {
 	return reflect.ValueOf(*r).Pointer() == reflect.ValueOf(reqHandlerNull).Pointer()
 }
 
+var inboundRequestNum int64 //已投递给服务但还未处理完成的Rpc请求数
+
 var rpcRequestPool = sync.NewPoolEx(make(chan sync.IPoolData,10240),func()sync.IPoolData{
 	return &RpcRequest{}
 })
@@ -57,6 +61,9 @@ type RpcHandleFinder interface {
 
 type RequestHandler func(Returns interface{},Err RpcError)
 
+// RawData 未解析的调用参数，交由IRpcForwarder转发
+type RawData []byte
+
 type Call struct {
 	ref           bool
 	Seq           uint64
@@ -69,6 +76,12 @@ type Call struct {
 	callback      *reflect.Value
 	rpcHandler    IRpcHandler
 	TimeOut       time.Duration
+	workerId      int //发起调用的工作协程，回调时返回到该工作协程
+}
+
+// IRpcWorker 发起调用的IRpcHandler实现该接口时，异步调用的回调返回到该工作协程
+type IRpcWorker interface {
+	GetWorkerId() int
 }
 
 type RpcCancel struct {
@@ -80,6 +93,24 @@ func (rc *RpcCancel) CancelRpc(){
 	rc.Cli.RemovePending(rc.CallSeq)
 }
 
+// GetInParam 获取已解析的请求参数
+func (slf *RpcRequest) GetInParam() interface{} {
+	return slf.inParam
+}
+
+// GetWorkerId 获取发起调用的工作协程
+func (call *Call) GetWorkerId() int {
+	return call.workerId
+}
+
+// setCaller 记录发起调用的服务与工作协程
+func (call *Call) setCaller(rpcHandler IRpcHandler) {
+	call.rpcHandler = rpcHandler
+	if rpcWorker, ok := rpcHandler.(IRpcWorker); ok {
+		call.workerId = rpcWorker.GetWorkerId()
+	}
+}
+
 func (slf *RpcRequest) Clear() *RpcRequest{
 	slf.RpcRequestData = nil
 	slf.localReply = nil
@@ -87,6 +118,7 @@ func (slf *RpcRequest) Clear() *RpcRequest{
 	slf.requestHandle = nil
 	slf.callback = nil
 	slf.rpcProcessor = nil
+	slf.inbound = false
 	return slf
 }
 
@@ -134,6 +166,7 @@ func (call *Call) Clear() *Call{
 	call.callback = nil
 	call.rpcHandler = nil
 	call.TimeOut = 0
+	call.workerId = 0
 
 	return call
 }
@@ -167,10 +200,36 @@ func MakeRpcRequest(rpcProcessor IRpcProcessor,seq uint64,rpcMethodId uint32,ser
 }
 
 func ReleaseRpcRequest(rpcRequest *RpcRequest){
+	if rpcRequest.inbound == true {
+		rpcRequest.inbound = false
+		atomic.AddInt64(&inboundRequestNum,-1)
+	}
+
 	rpcRequest.rpcProcessor.ReleaseRpcRequest(rpcRequest.RpcRequestData)
 	rpcRequestPool.Put(rpcRequest)
 }
 
+// DropRpcRequest 丢弃未处理的请求，需要回复时以rpcErr回复请求者，回复后释放请求
+func DropRpcRequest(rpcRequest *RpcRequest, rpcErr RpcError) {
+	if rpcRequest.requestHandle != nil {
+		rpcRequest.requestHandle(nil, rpcErr)
+		return
+	}
+
+	ReleaseRpcRequest(rpcRequest)
+}
+
+// markInbound 请求投递给服务前标记，直到请求释放时才计为完成
+func (slf *RpcRequest) markInbound() {
+	slf.inbound = true
+	atomic.AddInt64(&inboundRequestNum,1)
+}
+
+// GetInboundRequestNum 获取在途的入站Rpc请求数，包含等待异步回复的请求
+func GetInboundRequestNum() int64 {
+	return atomic.LoadInt64(&inboundRequestNum)
+}
+
 func MakeCall() *Call {
 	return rpcCallPool.Get().(*Call)
 }
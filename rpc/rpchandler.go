@@ -6,7 +6,7 @@ import (
 	"github.com/duanhf2012/origin/v2/event"
 	"github.com/duanhf2012/origin/v2/log"
 	"reflect"
-
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -17,6 +17,14 @@ const maxClusterNode int = 32
 
 type FuncRpcClient func(nodeId string, serviceMethod string, filterRetire bool, client []*Client) (error, []*Client)
 type FuncRpcServer func() IServer
+type FuncSelectRpcClient func(serviceMethod string, selector LabelSelector, filterRetire bool, client []*Client) (error, []*Client)
+type FuncRouteRpcClient func(serviceMethod string, client []*Client) []*Client
+
+// SelectRpcClientFun 按标签选择器查找结点，由cluster注册
+var SelectRpcClientFun FuncSelectRpcClient
+
+// RouteRpcClientFun 按服务名调用单个结点时从候选结点中路由，如版本路由，广播调用不经过路由，由cluster注册
+var RouteRpcClientFun FuncRouteRpcClient
 
 const NodeIdNull = ""
 
@@ -85,6 +93,11 @@ type IDiscoveryServiceListener interface {
 	OnUnDiscoveryService(nodeId string, serviceName []string)
 }
 
+// IServiceHealthListener 通过RegDiscoverListener注册的监听者实现该接口时，将收到服务健康状态变化
+type IServiceHealthListener interface {
+	OnServiceHealthChanged(nodeId string, serviceName []string, healthy bool)
+}
+
 type CancelRpc func()
 
 func emptyCancelRpc() {}
@@ -108,14 +121,27 @@ type IRpcHandler interface {
 	AsyncCallWithTimeout(timeout time.Duration, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error)
 	AsyncCallNodeWithTimeout(timeout time.Duration, nodeId string, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error)
 
+	CallWithSelector(selector LabelSelector, serviceMethod string, args interface{}, reply interface{}) error
+	CallWithSelectorTimeout(timeout time.Duration, selector LabelSelector, serviceMethod string, args interface{}, reply interface{}) error
+	AsyncCallWithSelector(selector LabelSelector, serviceMethod string, args interface{}, callback interface{}) error
+	AsyncCallWithSelectorTimeout(timeout time.Duration, selector LabelSelector, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error)
+
 	Go(serviceMethod string, args interface{}) error
 	GoNode(nodeId string, serviceMethod string, args interface{}) error
 	RawGoNode(rpcProcessorType RpcProcessorType, nodeId string, rpcMethodId uint32, serviceName string, rawArgs []byte) error
 	CastGo(serviceMethod string, args interface{}) error
+	GoWithSelector(selector LabelSelector, serviceMethod string, args interface{}) error
+	CastGoWithSelector(selector LabelSelector, serviceMethod string, args interface{}) error
 	UnmarshalInParam(rpcProcessor IRpcProcessor, serviceMethod string, rawRpcMethodId uint32, inParam []byte) (interface{}, error)
 	GetRpcServer() FuncRpcServer
 }
 
+// IRpcForwarder RpcHandler实现该接口时，未注册的方法不解析参数，以原始数据交由ForwardRpc转发
+// responder为nil时表示调用方不需要返回，传入responder的返回值需要能被processor序列化
+type IRpcForwarder interface {
+	ForwardRpc(processor IRpcProcessor, serviceMethod string, rawArgs RawData, responder RequestHandler)
+}
+
 func reqHandlerNull(Returns interface{}, Err RpcError) {
 }
 
@@ -272,6 +298,11 @@ func (handler *RpcHandler) HandlerRpcRequest(request *RpcRequest) {
 
 	//普通的rpc请求
 	v, ok := handler.mapFunctions[request.RpcRequestData.GetServiceMethod()]
+	if forwarder, isForwarder := handler.rpcHandler.(IRpcForwarder); ok == false && isForwarder == true {
+		handler.forwardRpcRequest(forwarder, request)
+		return
+	}
+
 	if ok == false {
 		err := "RpcHandler " + handler.rpcHandler.GetName() + " cannot find " + request.RpcRequestData.GetServiceMethod()
 		log.Errorf("HandlerRpcRequest cannot find serviceMethod,RpcHandlerName:[%s],serviceMethod:[%s]", handler.rpcHandler.GetName(), request.RpcRequestData.GetServiceMethod())
@@ -324,6 +355,53 @@ func (handler *RpcHandler) HandlerRpcRequest(request *RpcRequest) {
 	}
 }
 
+func (handler *RpcHandler) forwardRpcRequest(forwarder IRpcForwarder, request *RpcRequest) {
+	//本结点的调用参数未序列化
+	rawArgs, ok := request.inParam.(RawData)
+	if ok == false {
+		byteArgs, err := request.rpcProcessor.Marshal(request.inParam)
+		if err != nil {
+			if request.requestHandle != nil {
+				request.requestHandle(nil, ConvertError(err))
+			}
+			return
+		}
+		rawArgs = byteArgs
+	}
+
+	var responder RequestHandler
+	if request.requestHandle != nil {
+		requestHandle := request.requestHandle
+		processor := request.rpcProcessor
+		localReply := request.localReply
+		responder = func(Returns interface{}, Err RpcError) {
+			//本结点的调用需要返回调用方的返回值类型
+			if localReply != nil && len(Err) == 0 {
+				if Returns != nil && Returns != localReply {
+					if err := convertReply(processor, Returns, localReply); err != nil {
+						Err = ConvertError(err)
+					}
+				}
+				Returns = localReply
+			}
+
+			requestHandle(Returns, Err)
+		}
+	}
+
+	forwarder.ForwardRpc(request.rpcProcessor, request.RpcRequestData.GetServiceMethod(), rawArgs, responder)
+}
+
+// convertReply 转发返回的对象与调用方的返回值类型不同时，经序列化转换
+func convertReply(processor IRpcProcessor, returns interface{}, reply interface{}) error {
+	byteReturns, err := processor.Marshal(returns)
+	if err != nil {
+		return err
+	}
+
+	return processor.Unmarshal(byteReturns, reply)
+}
+
 func (handler *RpcHandler) CallMethod(client *Client, ServiceMethod string, param interface{}, callBack reflect.Value, reply interface{}) error {
 	var err error
 	v, ok := handler.mapFunctions[ServiceMethod]
@@ -435,9 +513,37 @@ func (handler *RpcHandler) CallMethod(client *Client, ServiceMethod string, para
 	return err
 }
 
-func (handler *RpcHandler) goRpc(processor IRpcProcessor, bCast bool, nodeId string, serviceMethod string, args interface{}) error {
+// getRpcClient 指定nodeId时直接查找结点，否则按服务名查找，并用标签选择器筛选结点
+func (handler *RpcHandler) getRpcClient(nodeId string, selector LabelSelector, serviceMethod string, clientList []*Client) (error, []*Client) {
+	if nodeId != NodeIdNull || len(selector) == 0 {
+		return handler.funcRpcClient(nodeId, serviceMethod, false, clientList)
+	}
+
+	if SelectRpcClientFun == nil {
+		return errors.New("label selector is not supported"), nil
+	}
+
+	return SelectRpcClientFun(serviceMethod, selector, false, clientList)
+}
+
+// getSingleRpcClient 查找单个调用的目标结点，未指定结点时经过RouteRpcClientFun路由
+func (handler *RpcHandler) getSingleRpcClient(nodeId string, selector LabelSelector, serviceMethod string, clientList []*Client) (error, []*Client) {
+	err, clientList := handler.getRpcClient(nodeId, selector, serviceMethod, clientList)
+	if err != nil || nodeId != NodeIdNull || RouteRpcClientFun == nil || len(clientList) == 0 {
+		return err, clientList
+	}
+
+	return nil, RouteRpcClientFun(serviceMethod, clientList)
+}
+
+func (handler *RpcHandler) goRpc(processor IRpcProcessor, bCast bool, nodeId string, selector LabelSelector, serviceMethod string, args interface{}) error {
+	var err error
 	pClientList := make([]*Client, 0, maxClusterNode)
-	err, pClientList := handler.funcRpcClient(nodeId, serviceMethod, false, pClientList)
+	if bCast == true {
+		err, pClientList = handler.getRpcClient(nodeId, selector, serviceMethod, pClientList)
+	} else {
+		err, pClientList = handler.getSingleRpcClient(nodeId, selector, serviceMethod, pClientList)
+	}
 	if len(pClientList) == 0 {
 		if err != nil {
 			log.Errorf("call serviceMethod is failed,serviceMethod:[%s],error:%s", serviceMethod, err)
@@ -465,9 +571,9 @@ func (handler *RpcHandler) goRpc(processor IRpcProcessor, bCast bool, nodeId str
 	return err
 }
 
-func (handler *RpcHandler) callRpc(timeout time.Duration, nodeId string, serviceMethod string, args interface{}, reply interface{}) error {
+func (handler *RpcHandler) callRpc(timeout time.Duration, nodeId string, selector LabelSelector, serviceMethod string, args interface{}, reply interface{}) error {
 	pClientList := make([]*Client, 0, maxClusterNode)
-	err, pClientList := handler.funcRpcClient(nodeId, serviceMethod, false, pClientList)
+	err, pClientList := handler.getSingleRpcClient(nodeId, selector, serviceMethod, pClientList)
 	if err != nil {
 		log.Errorf("Call serviceMethod is failed,error:%s", err)
 		return err
@@ -489,7 +595,7 @@ func (handler *RpcHandler) callRpc(timeout time.Duration, nodeId string, service
 	return err
 }
 
-func (handler *RpcHandler) asyncCallRpc(timeout time.Duration, nodeId string, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error) {
+func (handler *RpcHandler) asyncCallRpc(timeout time.Duration, nodeId string, selector LabelSelector, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error) {
 	fVal := reflect.ValueOf(callback)
 	if fVal.Kind() != reflect.Func {
 		err := errors.New("call " + serviceMethod + " input callback param is error!")
@@ -511,7 +617,7 @@ func (handler *RpcHandler) asyncCallRpc(timeout time.Duration, nodeId string, se
 
 	reply := reflect.New(fVal.Type().In(0).Elem()).Interface()
 	pClientList := make([]*Client, 0, 1)
-	err, pClientList := handler.funcRpcClient(nodeId, serviceMethod, false, pClientList[:])
+	err, pClientList := handler.getSingleRpcClient(nodeId, selector, serviceMethod, pClientList[:])
 	if len(pClientList) == 0 || err != nil {
 		if err == nil {
 			if nodeId != NodeIdNull {
@@ -541,50 +647,75 @@ func (handler *RpcHandler) GetName() string {
 }
 
 func (handler *RpcHandler) CallWithTimeout(timeout time.Duration, serviceMethod string, args interface{}, reply interface{}) error {
-	return handler.callRpc(timeout, NodeIdNull, serviceMethod, args, reply)
+	return handler.callRpc(timeout, NodeIdNull, nil, serviceMethod, args, reply)
 }
 
 func (handler *RpcHandler) CallNodeWithTimeout(timeout time.Duration, nodeId string, serviceMethod string, args interface{}, reply interface{}) error {
-	return handler.callRpc(timeout, nodeId, serviceMethod, args, reply)
+	return handler.callRpc(timeout, nodeId, nil, serviceMethod, args, reply)
 }
 
 func (handler *RpcHandler) AsyncCallWithTimeout(timeout time.Duration, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error) {
-	return handler.asyncCallRpc(timeout, NodeIdNull, serviceMethod, args, callback)
+	return handler.asyncCallRpc(timeout, NodeIdNull, nil, serviceMethod, args, callback)
 }
 
 func (handler *RpcHandler) AsyncCallNodeWithTimeout(timeout time.Duration, nodeId string, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error) {
-	return handler.asyncCallRpc(timeout, nodeId, serviceMethod, args, callback)
+	return handler.asyncCallRpc(timeout, nodeId, nil, serviceMethod, args, callback)
 }
 
 func (handler *RpcHandler) AsyncCall(serviceMethod string, args interface{}, callback interface{}) error {
-	_, err := handler.asyncCallRpc(DefaultRpcTimeout, NodeIdNull, serviceMethod, args, callback)
+	_, err := handler.asyncCallRpc(DefaultRpcTimeout, NodeIdNull, nil, serviceMethod, args, callback)
 	return err
 }
 
 func (handler *RpcHandler) Call(serviceMethod string, args interface{}, reply interface{}) error {
-	return handler.callRpc(DefaultRpcTimeout, NodeIdNull, serviceMethod, args, reply)
+	return handler.callRpc(DefaultRpcTimeout, NodeIdNull, nil, serviceMethod, args, reply)
 }
 
 func (handler *RpcHandler) Go(serviceMethod string, args interface{}) error {
-	return handler.goRpc(nil, false, NodeIdNull, serviceMethod, args)
+	return handler.goRpc(nil, false, NodeIdNull, nil, serviceMethod, args)
 }
 
 func (handler *RpcHandler) AsyncCallNode(nodeId string, serviceMethod string, args interface{}, callback interface{}) error {
-	_, err := handler.asyncCallRpc(DefaultRpcTimeout, nodeId, serviceMethod, args, callback)
+	_, err := handler.asyncCallRpc(DefaultRpcTimeout, nodeId, nil, serviceMethod, args, callback)
 
 	return err
 }
 
 func (handler *RpcHandler) CallNode(nodeId string, serviceMethod string, args interface{}, reply interface{}) error {
-	return handler.callRpc(DefaultRpcTimeout, nodeId, serviceMethod, args, reply)
+	return handler.callRpc(DefaultRpcTimeout, nodeId, nil, serviceMethod, args, reply)
 }
 
 func (handler *RpcHandler) GoNode(nodeId string, serviceMethod string, args interface{}) error {
-	return handler.goRpc(nil, false, nodeId, serviceMethod, args)
+	return handler.goRpc(nil, false, nodeId, nil, serviceMethod, args)
 }
 
 func (handler *RpcHandler) CastGo(serviceMethod string, args interface{}) error {
-	return handler.goRpc(nil, true, NodeIdNull, serviceMethod, args)
+	return handler.goRpc(nil, true, NodeIdNull, nil, serviceMethod, args)
+}
+
+func (handler *RpcHandler) CallWithSelector(selector LabelSelector, serviceMethod string, args interface{}, reply interface{}) error {
+	return handler.callRpc(DefaultRpcTimeout, NodeIdNull, selector, serviceMethod, args, reply)
+}
+
+func (handler *RpcHandler) CallWithSelectorTimeout(timeout time.Duration, selector LabelSelector, serviceMethod string, args interface{}, reply interface{}) error {
+	return handler.callRpc(timeout, NodeIdNull, selector, serviceMethod, args, reply)
+}
+
+func (handler *RpcHandler) AsyncCallWithSelector(selector LabelSelector, serviceMethod string, args interface{}, callback interface{}) error {
+	_, err := handler.asyncCallRpc(DefaultRpcTimeout, NodeIdNull, selector, serviceMethod, args, callback)
+	return err
+}
+
+func (handler *RpcHandler) AsyncCallWithSelectorTimeout(timeout time.Duration, selector LabelSelector, serviceMethod string, args interface{}, callback interface{}) (CancelRpc, error) {
+	return handler.asyncCallRpc(timeout, NodeIdNull, selector, serviceMethod, args, callback)
+}
+
+func (handler *RpcHandler) GoWithSelector(selector LabelSelector, serviceMethod string, args interface{}) error {
+	return handler.goRpc(nil, false, NodeIdNull, selector, serviceMethod, args)
+}
+
+func (handler *RpcHandler) CastGoWithSelector(selector LabelSelector, serviceMethod string, args interface{}) error {
+	return handler.goRpc(nil, true, NodeIdNull, selector, serviceMethod, args)
 }
 
 func (handler *RpcHandler) RawGoNode(rpcProcessorType RpcProcessorType, nodeId string, rpcMethodId uint32, serviceName string, rawArgs []byte) error {
@@ -627,6 +758,10 @@ func (handler *RpcHandler) UnmarshalInParam(rpcProcessor IRpcProcessor, serviceM
 	}
 
 	v, ok := handler.mapFunctions[serviceMethod]
+	if _, isForwarder := handler.rpcHandler.(IRpcForwarder); ok == false && isForwarder == true {
+		return RawData(slices.Clone(inParam)), nil
+	}
+
 	if ok == false {
 		return nil, errors.New("RpcHandler " + handler.rpcHandler.GetName() + " cannot find " + serviceMethod)
 	}
@@ -640,3 +775,8 @@ func (handler *RpcHandler) UnmarshalInParam(rpcProcessor IRpcProcessor, serviceM
 func (handler *RpcHandler) GetRpcServer() FuncRpcServer {
 	return handler.funcRpcServer
 }
+
+// GetRpcClientFun 获取查找rpc客户端的函数
+func (handler *RpcHandler) GetRpcClientFun() FuncRpcClient {
+	return handler.funcRpcClient
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"container/heap"
+	"github.com/duanhf2012/origin/v2/util/timer"
 	"time"
 )
 
@@ -62,7 +63,7 @@ func (h *CallTimerHeap) Cancel(seq uint64) bool {
 func (h *CallTimerHeap) AddTimer(seqId uint64,d time.Duration){
 	heap.Push(h, CallTimer{
 		SeqId:    seqId,
-		FireTime: time.Now().Add(d).UnixNano(),
+		FireTime: timer.Now().Add(d).UnixNano(),
 	})
 }
 
@@ -72,7 +73,7 @@ func (h *CallTimerHeap) PopTimeout() uint64 {
 	}
 
 	nextFireTime := h.callTimer[0].FireTime
-	if nextFireTime > time.Now().UnixNano() {
+	if nextFireTime > timer.Now().UnixNano() {
 		return 0
 	}
 
@@ -79,7 +79,7 @@ func (server *Server) Init(listenAddr string, maxRpcParamLen uint32, compressByt
 	server.listenAddr = listenAddr
 	server.maxRpcParamLen = maxRpcParamLen
 
-	server.rpcServer = &network.TCPServer{}
+	server.rpcServer = &network.TCPServer{IsRpcServer: true}
 }
 
 func (server *Server) Start() error {
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"time"
+)
+
+// DurableTimerData 持久化定时器的数据
+type DurableTimerData struct {
+	Id       string //服务内唯一
+	FireTime int64  //触发时间(UnixNano)
+	FunName  string //通过RegDurableTimerFun注册的回调名
+	Payload  []byte //回调参数
+	CronExpr string //不为空时为cron定时器，每次触发后按表达式计算下次触发时间
+}
+
+// IDurableTimerStore 持久化定时器的存储，在服务协程中同步调用。
+// owner由结点id与服务名组成，见getDurableTimerOwner，多个结点运行同名服务时各自只加载与触发自己的定时器
+type IDurableTimerStore interface {
+	Load(owner string) ([]*DurableTimerData, error)
+	Save(owner string, data *DurableTimerData) error //不存在时插入，存在时覆盖
+	Remove(owner string, id string) error
+}
+
+// DurableTimerFun 持久化定时器回调，data.FireTime为计划的触发时间，结点停机期间到期的定时器在启动后补触发，此时早于当前时间
+type DurableTimerFun func(data *DurableTimerData)
+
+type durableTimer struct {
+	data    *DurableTimerData
+	timerId uint64
+}
+
+// durableTimerMgr 服务的持久化定时器，只在服务协程中访问
+type durableTimerMgr struct {
+	store    IDurableTimerStore
+	mapFun   map[string]DurableTimerFun
+	mapTimer map[string]*durableTimer
+}
+
+// SetDurableTimerStore 设置持久化定时器的存储，需要在OnInit中调用，服务启动时加载并恢复定时器
+func (s *Service) SetDurableTimerStore(store IDurableTimerStore) {
+	s.getDurableTimerMgr().store = store
+}
+
+func (m *Module) getDurableTimerMgr() *durableTimerMgr {
+	ancestor := m.getAncestorModule()
+	if ancestor.durableTimer == nil {
+		ancestor.durableTimer = &durableTimerMgr{mapFun: map[string]DurableTimerFun{}, mapTimer: map[string]*durableTimer{}}
+	}
+
+	return ancestor.durableTimer
+}
+
+// RegDurableTimerFun 注册持久化定时器回调，需要在OnInit中注册，服务启动恢复定时器时按名称找到回调
+func (m *Module) RegDurableTimerFun(funName string, fun DurableTimerFun) {
+	m.getDurableTimerMgr().mapFun[funName] = fun
+}
+
+// DurableAfterFunc 创建持久化定时器，d时间后回调funName，id已存在时覆盖。
+// 写入存储成功后才会生效，结点重启后自动恢复，回调完成后从存储中删除
+func (m *Module) DurableAfterFunc(id string, d time.Duration, funName string, payload []byte) error {
+	return m.setupDurableTimer(&DurableTimerData{Id: id, FireTime: timer.Now().Add(d).UnixNano(), FunName: funName, Payload: payload})
+}
+
+// DurableCronFunc 创建持久化cron定时器，id已存在时覆盖。停机期间错过的多次触发在启动后只补触发一次
+func (m *Module) DurableCronFunc(id string, cronExpr string, funName string, payload []byte) error {
+	expr, err := timer.NewCronExpr(cronExpr)
+	if err != nil {
+		return err
+	}
+
+	nextTime := expr.Next(timer.Now())
+	if nextTime.IsZero() {
+		return fmt.Errorf("cron expr %s has no next time", cronExpr)
+	}
+
+	return m.setupDurableTimer(&DurableTimerData{Id: id, FireTime: nextTime.UnixNano(), FunName: funName, Payload: payload, CronExpr: cronExpr})
+}
+
+// CancelDurableTimer 取消持久化定时器并从存储中删除
+func (m *Module) CancelDurableTimer(id string) error {
+	mgr := m.getDurableTimerMgr()
+	if mgr.store == nil {
+		return errors.New("durable timer store is not set")
+	}
+
+	dt, ok := mgr.mapTimer[id]
+	if ok == false {
+		return fmt.Errorf("cannot find durable timer %s", id)
+	}
+
+	if err := mgr.store.Remove(getDurableTimerOwner(m.GetService().GetName()), id); err != nil {
+		return err
+	}
+
+	delete(mgr.mapTimer, id)
+	m.getAncestorModule().CancelTimerId(&dt.timerId)
+	return nil
+}
+
+// GetDurableTimer 获取持久化定时器，不存在时返回nil
+func (m *Module) GetDurableTimer(id string) *DurableTimerData {
+	if dt, ok := m.getDurableTimerMgr().mapTimer[id]; ok {
+		return dt.data
+	}
+
+	return nil
+}
+
+// getDurableTimerOwner 定时器在存储中按结点id与服务名区分，结点id未设置时只使用服务名
+func getDurableTimerOwner(serviceName string) string {
+	if localNodeId == "" {
+		return serviceName
+	}
+
+	return localNodeId + "_" + serviceName
+}
+
+func (m *Module) getAncestorModule() *Module {
+	return m.ancestor.getBaseModule().(*Module)
+}
+
+func (m *Module) setupDurableTimer(data *DurableTimerData) error {
+	mgr := m.getDurableTimerMgr()
+	if mgr.store == nil {
+		return errors.New("durable timer store is not set")
+	}
+
+	if data.Id == "" {
+		return errors.New("durable timer id is empty")
+	}
+
+	if _, ok := mgr.mapFun[data.FunName]; ok == false {
+		return fmt.Errorf("durable timer fun %s is not registered", data.FunName)
+	}
+
+	if err := mgr.store.Save(getDurableTimerOwner(m.GetService().GetName()), data); err != nil {
+		return err
+	}
+
+	if dt, ok := mgr.mapTimer[data.Id]; ok {
+		m.getAncestorModule().CancelTimerId(&dt.timerId)
+	}
+	m.getAncestorModule().scheduleDurableTimer(data)
+	return nil
+}
+
+// scheduleDurableTimer 按计划的触发时间安装定时器，已过期的定时器按计划的触发时间先后立即触发
+func (m *Module) scheduleDurableTimer(data *DurableTimerData) {
+	dt := &durableTimer{data: data}
+	m.getDurableTimerMgr().mapTimer[data.Id] = dt
+	m.SafeAfterFunc(&dt.timerId, time.Unix(0, data.FireTime).Sub(timer.Now()), nil, func(uint64, interface{}) {
+		m.onDurableTimer(dt)
+	})
+}
+
+func (m *Module) onDurableTimer(dt *durableTimer) {
+	mgr := m.getDurableTimerMgr()
+	if mgr.mapTimer[dt.data.Id] != dt {
+		return
+	}
+	delete(mgr.mapTimer, dt.data.Id)
+
+	data := dt.data
+	serviceName := m.GetService().GetName()
+	fun := mgr.mapFun[data.FunName]
+	if fun == nil {
+		log.Errorf("durable timer fun %s is not registered,serviceName:%s,id:%s", data.FunName, serviceName, data.Id)
+		return
+	}
+
+	safeDurableTimerFun(fun, data)
+
+	//回调期间重新创建了同id的定时器
+	if _, ok := mgr.mapTimer[data.Id]; ok {
+		return
+	}
+
+	if data.CronExpr != "" {
+		if nextData := nextCronDurableTimer(data); nextData != nil {
+			if err := mgr.store.Save(getDurableTimerOwner(serviceName), nextData); err != nil {
+				log.Errorf("save durable timer fail,serviceName:%s,id:%s,err:%s", serviceName, data.Id, err)
+			}
+			m.scheduleDurableTimer(nextData)
+			return
+		}
+	}
+
+	if err := mgr.store.Remove(getDurableTimerOwner(serviceName), data.Id); err != nil {
+		log.Errorf("remove durable timer fail,serviceName:%s,id:%s,err:%s", serviceName, data.Id, err)
+	}
+}
+
+func safeDurableTimerFun(fun DurableTimerFun, data *DurableTimerData) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error(fmt.Sprint(r))
+		}
+	}()
+
+	fun(data)
+}
+
+// nextCronDurableTimer 计算cron定时器的下次触发，错过的触发不再补齐
+func nextCronDurableTimer(data *DurableTimerData) *DurableTimerData {
+	expr, err := timer.NewCronExpr(data.CronExpr)
+	if err != nil {
+		log.Errorf("durable timer cron expr %s is error,id:%s,err:%s", data.CronExpr, data.Id, err)
+		return nil
+	}
+
+	nextTime := expr.Next(timer.Now())
+	if nextTime.IsZero() {
+		return nil
+	}
+
+	nextData := *data
+	nextData.FireTime = nextTime.UnixNano()
+	return &nextData
+}
+
+// loadDurableTimer 服务启动时从存储中恢复定时器
+func (s *Service) loadDurableTimer() {
+	mgr := s.durableTimer
+	if mgr == nil || mgr.store == nil {
+		return
+	}
+
+	dataList, err := mgr.store.Load(getDurableTimerOwner(s.GetName()))
+	if err != nil {
+		log.Errorf("load durable timer fail,serviceName:%s,err:%s", s.GetName(), err)
+		return
+	}
+
+	now := timer.Now().UnixNano()
+	for _, data := range dataList {
+		if _, ok := mgr.mapFun[data.FunName]; ok == false {
+			log.Errorf("durable timer fun %s is not registered,serviceName:%s,id:%s", data.FunName, s.GetName(), data.Id)
+			continue
+		}
+
+		if data.FireTime <= now {
+			log.Infof("durable timer is overdue,serviceName:%s,id:%s,fireTime:%s", s.GetName(), data.Id, time.Unix(0, data.FireTime))
+		}
+		s.scheduleDurableTimer(data)
+	}
+
+	log.Infof("load %d durable timers,serviceName:%s", len(dataList), s.GetName())
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duanhf2012/origin/v2/util/timer"
+)
+
+// memoryTimerStore 内存中的持久化定时器存储，保存数据的副本
+type memoryTimerStore struct {
+	locker   sync.Mutex
+	mapTimer map[string]map[string]DurableTimerData //map[owner]map[id]
+}
+
+func newMemoryTimerStore() *memoryTimerStore {
+	return &memoryTimerStore{mapTimer: map[string]map[string]DurableTimerData{}}
+}
+
+func (ms *memoryTimerStore) Load(owner string) ([]*DurableTimerData, error) {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	dataList := make([]*DurableTimerData, 0, len(ms.mapTimer[owner]))
+	for _, data := range ms.mapTimer[owner] {
+		d := data
+		dataList = append(dataList, &d)
+	}
+
+	return dataList, nil
+}
+
+func (ms *memoryTimerStore) Save(owner string, data *DurableTimerData) error {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	if ms.mapTimer[owner] == nil {
+		ms.mapTimer[owner] = map[string]DurableTimerData{}
+	}
+	ms.mapTimer[owner][data.Id] = *data
+	return nil
+}
+
+func (ms *memoryTimerStore) Remove(owner string, id string) error {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	delete(ms.mapTimer[owner], id)
+	return nil
+}
+
+func (ms *memoryTimerStore) get(owner string, id string) (DurableTimerData, bool) {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	data, ok := ms.mapTimer[owner][id]
+	return data, ok
+}
+
+type durableTimerTestService struct {
+	Service
+
+	store       *memoryTimerStore
+	fireList    []string //只在服务协程中访问，Advance返回后读取
+	recreateNum int
+}
+
+func (s *durableTimerTestService) OnInit() error {
+	s.SetDurableTimerStore(s.store)
+	s.RegDurableTimerFun("Record", func(data *DurableTimerData) {
+		s.fireList = append(s.fireList, data.Id)
+	})
+
+	//第一次触发时在回调中重新创建同id的定时器
+	s.RegDurableTimerFun("Recreate", func(data *DurableTimerData) {
+		s.fireList = append(s.fireList, data.Id)
+		s.recreateNum++
+		if s.recreateNum == 1 {
+			if err := s.DurableAfterFunc(data.Id, time.Minute, "Recreate", nil); err != nil {
+				s.fireList = append(s.fireList, err.Error())
+			}
+		}
+	})
+	return nil
+}
+
+func (s *durableTimerTestService) takeFireList() []string {
+	fireList := s.fireList
+	s.fireList = nil
+	return fireList
+}
+
+func checkFireList(t *testing.T, fireList []string, want ...string) {
+	t.Helper()
+	if len(fireList) != len(want) {
+		t.Fatalf("fire list is %v,expect %v", fireList, want)
+	}
+	for i := range want {
+		if fireList[i] != want[i] {
+			t.Fatalf("fire list is %v,expect %v", fireList, want)
+		}
+	}
+}
+
+func TestDurableTimer(t *testing.T) {
+	const serviceName = "DurableTimerTestService"
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := timer.NewManualClock(start)
+	timer.StartTimer(10*time.Millisecond, 1000)
+	timer.SetClock(clock)
+	SetLocalNodeId("1")
+	t.Cleanup(func() {
+		timer.SetClock(timer.RealClock{})
+		SetLocalNodeId("")
+	})
+
+	//停机期间到期的定时器，其他结点的同名服务的定时器不会被加载
+	store := newMemoryTimerStore()
+	owner := getDurableTimerOwner(serviceName)
+	otherOwner := "2_" + serviceName
+	store.Save(owner, &DurableTimerData{Id: "b", FireTime: start.Add(-time.Hour).UnixNano(), FunName: "Record"})
+	store.Save(owner, &DurableTimerData{Id: "a", FireTime: start.Add(-2 * time.Hour).UnixNano(), FunName: "Record"})
+	store.Save(owner, &DurableTimerData{Id: "cron", FireTime: start.Add(-3 * time.Hour).UnixNano(), FunName: "Record", CronExpr: "TZ=UTC 0 0 * * * *"})
+	store.Save(owner, &DurableTimerData{Id: "recreate", FireTime: start.Add(-30 * time.Minute).UnixNano(), FunName: "Recreate"})
+	store.Save(otherOwner, &DurableTimerData{Id: "other", FireTime: start.Add(-time.Hour).UnixNano(), FunName: "Record"})
+
+	s := &durableTimerTestService{store: store}
+	s.SetName(serviceName)
+	s.Init(s, getTestRpcClient, nil, nil)
+	if err := s.OnInit(); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+
+	//按计划的触发时间先后补触发
+	clock.Advance(0)
+	checkFireList(t, s.takeFireList(), "cron", "a", "b", "recreate")
+	for _, id := range []string{"a", "b"} {
+		if _, ok := store.get(owner, id); ok {
+			t.Fatalf("timer %s is not removed after fired", id)
+		}
+	}
+	if _, ok := store.get(otherOwner, "other"); ok == false {
+		t.Fatal("timer of other node is removed")
+	}
+
+	//cron定时器按当前时间重新计算下次触发，错过的多次触发只补一次
+	if data, ok := store.get(owner, "cron"); ok == false || data.FireTime != start.Add(time.Hour).UnixNano() {
+		t.Fatalf("cron timer is not rescheduled,data:%+v", data)
+	}
+
+	//回调中重新创建的同id定时器不会被删除
+	data, ok := store.get(owner, "recreate")
+	if ok == false || data.FireTime != start.Add(time.Minute).UnixNano() {
+		t.Fatalf("recreated timer is error,data:%+v", data)
+	}
+
+	clock.Advance(time.Minute)
+	checkFireList(t, s.takeFireList(), "recreate")
+	if _, ok := store.get(owner, "recreate"); ok {
+		t.Fatal("recreated timer is not removed after fired")
+	}
+
+	clock.Advance(time.Hour)
+	checkFireList(t, s.takeFireList(), "cron")
+	if data, ok := store.get(owner, "cron"); ok == false || data.FireTime != start.Add(2*time.Hour).UnixNano() {
+		t.Fatalf("cron timer is not rescheduled,data:%+v", data)
+	}
+}
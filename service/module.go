@@ -56,6 +56,7 @@ type Module struct {
 	ancestor     IModule            //始祖
 	seedModuleId uint32             //模块id种子
 	descendants  map[uint32]IModule //始祖的后裔们
+	durableTimer *durableTimerMgr   //持久化定时器
 
 	//事件管道
 	eventHandler event.IEventHandler
@@ -84,6 +85,11 @@ func (m *Module) OnInit() error {
 }
 
 func (m *Module) AddModule(module IModule) (uint32, error) {
+	return m.addModule(module, m.dispatcher)
+}
+
+// addModule 添加子模块，子模块的定时器由dispatcher派发
+func (m *Module) addModule(module IModule, dispatcher *timer.Dispatcher) (uint32, error) {
 	//没有事件处理器不允许加入其他模块
 	if m.GetEventProcessor() == nil {
 		return 0, fmt.Errorf("module %+v Event Processor is nil", m.self)
@@ -101,7 +107,7 @@ func (m *Module) AddModule(module IModule) (uint32, error) {
 	pAddModule.IRpcHandler = m.IRpcHandler
 	pAddModule.self = module
 	pAddModule.parent = m.self
-	pAddModule.dispatcher = m.GetAncestor().getBaseModule().(*Module).dispatcher
+	pAddModule.dispatcher = dispatcher
 	pAddModule.ancestor = m.ancestor
 	pAddModule.moduleName = reflect.Indirect(reflect.ValueOf(module)).Type().Name()
 	pAddModule.eventHandler = event.NewEventHandler()
@@ -260,6 +266,7 @@ func (m *Module) SafeAfterFunc(timerId *uint64, d time.Duration, AdditionData in
 	m.mapActiveIdTimer[*timerId] = t
 }
 
+// SafeCronFunc 按cron表达式循环触发，cronExpr带有时区时按该时区计算触发时间
 func (m *Module) SafeCronFunc(cronId *uint64, cronExpr *timer.CronExpr, AdditionData interface{}, cb func(uint64, interface{})) {
 	if m.mapActiveIdTimer == nil {
 		m.mapActiveIdTimer = map[uint64]timer.ITimer{}
@@ -13,10 +13,59 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var timerDispatcherLen = 100000
 var maxServiceEventChannelNum = 2000000
+var healthCheckInterval = 5 * time.Second
+
+var defaultStopTimeout time.Duration //为0表示不单独限制，只受总超时时间限制
+var stopAllTimeout = DefaultStopAllTimeout
+
+// StopWaitForever 设置停止超时时间为一直等待，不使用默认的停止超时时间
+const StopWaitForever time.Duration = -1
+
+// DefaultStopAllTimeout 所有服务停止的默认总超时时间
+const DefaultStopAllTimeout = 60 * time.Second
+
+// StopPolicy 服务停止时对邮箱中未处理事件的策略
+type StopPolicy int8
+
+const (
+	StopDropEvent  StopPolicy = 0 //丢弃未处理的事件(默认)
+	StopDrainEvent StopPolicy = 1 //在停止超时时间内处理完未处理的事件
+)
+
+const dependOnCheckInterval = 500 * time.Millisecond
+const dependOnWarnInterval = 10 * time.Second
+
+// IHealthCheck 服务实现该接口后，将在服务协程中定时进行健康检查，返回error表示不健康
+type IHealthCheck interface {
+	OnHealthCheck() error
+}
+
+// ISingletonService 单例服务实现该接口，在成为Leader与失去Leader时回调
+type ISingletonService interface {
+	OnBecomeLeader()
+	OnLoseLeadership()
+}
+
+// IConfigChanged 服务实现该接口后，配置中心下发的服务配置变化时在服务协程中回调，
+// 回调时GetServiceCfg与ParseServiceCfg已返回新配置
+type IConfigChanged interface {
+	OnConfigChanged(oldCfg interface{}, newCfg interface{})
+}
+
+// IGlobalConfigChanged 服务实现该接口后，配置中心下发的Global配置变化时在服务协程中回调
+type IGlobalConfigChanged interface {
+	OnGlobalConfigChanged(oldCfg interface{}, newCfg interface{})
+}
+
+// IDependOn 服务声明依赖的服务，本结点的依赖服务先Init与Start，后Stop，不在本结点的依赖为远程服务
+type IDependOn interface {
+	DependsOn() []string
+}
 
 type IService interface {
 	concurrent.IConcurrent
@@ -43,6 +92,12 @@ type IService interface {
 
 	SetRetire()     //设置服务退休状态
 	IsRetire() bool //服务是否退休
+
+	SetLeader(isLeader bool) //设置单例服务的Leader状态
+	IsLeader() bool          //单例服务是否为Leader
+
+	UpdateServiceCfg(serviceCfg interface{})           //更新服务配置，在服务协程中生效并回调OnConfigChanged
+	NotifyGlobalCfgChanged(oldCfg, newCfg interface{}) //通知Global配置变化，在服务协程中回调OnGlobalConfigChanged
 }
 
 type Service struct {
@@ -56,6 +111,7 @@ type Service struct {
 	startStatus            bool
 	isRelease              int32
 	retire                 int32
+	leader                 int32
 	eventProcessor         event.IEventProcessor
 	profiler               *profiler.Profiler //性能分析器
 	nodeConnLister         rpc.INodeConnListener
@@ -63,6 +119,16 @@ type Service struct {
 	discoveryServiceLister rpc.IDiscoveryServiceListener
 	chanEvent              chan event.IEvent
 	closeSig               chan struct{}
+	unhealthy              bool
+	waitDependOn           bool
+	runStateLocker         sync.Mutex
+	runStateList           []*runState //各服务协程当前正在处理的项，用于看门狗检查
+	stopTimeout            time.Duration
+	stopPolicy             StopPolicy
+
+	workerList   []*Worker //并行工作协程，为空表示未打开
+	workerKeyFun WorkerKeyFun
+	workerWg     sync.WaitGroup
 }
 
 // DiscoveryServiceEvent 发现服务结点
@@ -72,6 +138,13 @@ type DiscoveryServiceEvent struct {
 	NodeId      string
 }
 
+// ServiceHealthEvent 结点中服务的健康状态变化
+type ServiceHealthEvent struct {
+	Healthy     bool
+	ServiceName []string
+	NodeId      string
+}
+
 type EtcdServiceRecordEvent struct {
 	NetworkName string
 	TTLSecond   int64
@@ -82,14 +155,39 @@ type EtcdServiceRecordEvent struct {
 type Empty struct {
 }
 
+type configChangedData struct {
+	isGlobal bool
+	oldCfg   interface{}
+	newCfg   interface{}
+}
+
 func SetMaxServiceChannel(maxEventChannel int) {
 	maxServiceEventChannelNum = maxEventChannel
 }
 
+func SetHealthCheckInterval(interval time.Duration) {
+	healthCheckInterval = interval
+}
+
+// SetStopTimeout 设置服务默认的停止超时时间与所有服务停止的总超时时间，
+// allTimeout为0时使用DefaultStopAllTimeout，为StopWaitForever时一直等待
+func SetStopTimeout(serviceTimeout time.Duration, allTimeout time.Duration) {
+	if allTimeout == 0 {
+		allTimeout = DefaultStopAllTimeout
+	}
+
+	defaultStopTimeout = serviceTimeout
+	stopAllTimeout = allTimeout
+}
+
 func (rpcEventData *DiscoveryServiceEvent) GetEventType() event.EventType {
 	return event.Sys_Event_DiscoverService
 }
 
+func (healthEvent *ServiceHealthEvent) GetEventType() event.EventType {
+	return event.Sys_Event_DiscoverService
+}
+
 func (s *Service) OnSetup(iService IService) {
 	if iService.GetName() == "" {
 		s.name = reflect.Indirect(reflect.ValueOf(iService)).Type().Name()
@@ -116,6 +214,91 @@ func (s *Service) SetRetire() {
 	s.pushEvent(ev)
 }
 
+func (s *Service) IsLeader() bool {
+	return atomic.LoadInt32(&s.leader) != 0
+}
+
+func (s *Service) SetLeader(isLeader bool) {
+	var leader int32
+	if isLeader {
+		leader = 1
+	}
+
+	if atomic.SwapInt32(&s.leader, leader) == leader {
+		return
+	}
+
+	ev := event.NewEvent()
+	ev.Type = event.Sys_Event_Leader
+	ev.Data = isLeader
+
+	s.pushEvent(ev)
+}
+
+// SetWaitDependOn 设置为true时，OnStart延迟到DependsOn中的远程服务都被发现后在服务协程中调用，需要在OnInit中设置
+func (s *Service) SetWaitDependOn(wait bool) {
+	s.waitDependOn = wait
+}
+
+// getUndiscoveredDependOn 获取尚未被发现的远程依赖服务
+func (s *Service) getUndiscoveredDependOn() []string {
+	if IsServiceDiscoveredFun == nil {
+		return nil
+	}
+
+	var undiscoveredList []string
+	for _, dependName := range getRemoteDependsOn(s.self.(IService)) {
+		if IsServiceDiscoveredFun(dependName) == false {
+			undiscoveredList = append(undiscoveredList, dependName)
+		}
+	}
+
+	return undiscoveredList
+}
+
+// waitRemoteDependOn 存在未发现的远程依赖服务时，定时检查直到都被发现后再调用OnStart
+func (s *Service) waitRemoteDependOn() bool {
+	undiscoveredList := s.getUndiscoveredDependOn()
+	if len(undiscoveredList) == 0 {
+		return false
+	}
+
+	log.Infof("service is waiting for remote dependency,serviceName:%s,dependOn:%v", s.GetName(), undiscoveredList)
+	lastWarnTime := time.Now()
+	s.NewTicker(dependOnCheckInterval, func(t *timer.Ticker) {
+		undiscoveredList = s.getUndiscoveredDependOn()
+		if len(undiscoveredList) > 0 {
+			if time.Since(lastWarnTime) >= dependOnWarnInterval {
+				lastWarnTime = time.Now()
+				log.Warnf("service is still waiting for remote dependency,serviceName:%s,dependOn:%v", s.GetName(), undiscoveredList)
+			}
+			return
+		}
+
+		t.Cancel()
+		log.Infof("remote dependency is discovered,serviceName:%s", s.GetName())
+		s.self.(IService).OnStart()
+	})
+
+	return true
+}
+
+func (s *Service) UpdateServiceCfg(serviceCfg interface{}) {
+	ev := event.NewEvent()
+	ev.Type = event.Sys_Event_ConfigChanged
+	ev.Data = &configChangedData{newCfg: serviceCfg}
+
+	s.pushEvent(ev)
+}
+
+func (s *Service) NotifyGlobalCfgChanged(oldCfg, newCfg interface{}) {
+	ev := event.NewEvent()
+	ev.Type = event.Sys_Event_ConfigChanged
+	ev.Data = &configChangedData{isGlobal: true, oldCfg: oldCfg, newCfg: newCfg}
+
+	s.pushEvent(ev)
+}
+
 func (s *Service) Init(iService IService, getClientFun rpc.FuncRpcClient, getServerFun rpc.FuncRpcServer, serviceCfg interface{}) {
 	s.closeSig = make(chan struct{})
 	s.dispatcher = timer.NewDispatcher(timerDispatcherLen)
@@ -144,7 +327,16 @@ func (s *Service) Start() {
 	atomic.StoreInt32(&s.isRelease, 0)
 	var waitRun sync.WaitGroup
 	log.Info(s.GetName() + " service is running")
-	s.self.(IService).OnStart()
+	s.loadDurableTimer()
+	if s.waitDependOn == false || s.waitRemoteDependOn() == false {
+		s.self.(IService).OnStart()
+	}
+
+	if healthCheck, ok := s.self.(IHealthCheck); ok {
+		s.NewTicker(healthCheckInterval, func(t *timer.Ticker) {
+			s.checkHealth(healthCheck)
+		})
+	}
 
 	for i := int32(0); i < s.goroutineNum; i++ {
 		s.wg.Add(1)
@@ -154,6 +346,7 @@ func (s *Service) Start() {
 			s.run()
 		}()
 	}
+	s.startWorker(&waitRun)
 
 	waitRun.Wait()
 }
@@ -165,82 +358,38 @@ func (s *Service) run() {
 	cr := s.IConcurrent.(*concurrent.Concurrent)
 	concurrentCBChannel := cr.GetCallBackChannel()
 
+	rs := s.addRunState()
+	defer s.removeRunState(rs)
+
 	for {
-		var analyzer *profiler.Analyzer
 		select {
 		case <-s.closeSig:
 			bStop = true
+			s.handleMailbox(rs, s.chanEvent)
+			//等待其他工作协程退出后再释放模块
+			s.workerWg.Wait()
+			rs.begin("[Release]", s.GetName())
+			cr.SetCloseTimeout(s.getStopTimeout())
 			s.Release()
 			cr.Close()
+			s.doWorkerCallback(cr)
 		case cb := <-concurrentCBChannel:
+			rs.begin("[Callback]", "")
 			cr.DoCallback(cb)
 		case ev := <-s.chanEvent:
-			switch ev.GetEventType() {
-			case event.Sys_Event_Retire:
-				log.Debugf("service OnRetire,serviceName:%s", s.GetName())
-				s.self.(IService).OnRetire()
-			case event.ServiceRpcRequestEvent:
-				cEvent, ok := ev.(*event.Event)
-				if ok == false {
-					log.Error("Type event conversion error")
-					break
-				}
-				rpcRequest, ok := cEvent.Data.(*rpc.RpcRequest)
-				if ok == false {
-					log.Error("Type *rpc.RpcRequest conversion error")
-					break
-				}
-				if s.profiler != nil {
-					analyzer = s.profiler.Push("[Req]" + rpcRequest.RpcRequestData.GetServiceMethod())
-				}
-
-				s.GetRpcHandler().HandlerRpcRequest(rpcRequest)
-				if analyzer != nil {
-					analyzer.Pop()
-					analyzer = nil
-				}
-				event.DeleteEvent(cEvent)
-			case event.ServiceRpcResponseEvent:
-				cEvent, ok := ev.(*event.Event)
-				if ok == false {
-					log.Error("Type event conversion error")
-					break
-				}
-				rpcResponseCB, ok := cEvent.Data.(*rpc.Call)
-				if ok == false {
-					log.Error("Type *rpc.Call conversion error")
-					break
-				}
-				if s.profiler != nil {
-					analyzer = s.profiler.Push("[Res]" + rpcResponseCB.ServiceMethod)
-				}
-				s.GetRpcHandler().HandlerRpcResponseCB(rpcResponseCB)
-				if analyzer != nil {
-					analyzer.Pop()
-					analyzer = nil
-				}
-				event.DeleteEvent(cEvent)
-			default:
-				if s.profiler != nil {
-					analyzer = s.profiler.Push("[SEvent]" + strconv.Itoa(int(ev.GetEventType())))
-				}
-				s.eventProcessor.EventHandler(ev)
-				if analyzer != nil {
-					analyzer.Pop()
-					analyzer = nil
-				}
-			}
-
+			s.handleEvent(rs, ev)
 		case t := <-s.dispatcher.ChanTimer:
+			var analyzer *profiler.Analyzer
+			rs.begin("[timer]", t.GetName())
 			if s.profiler != nil {
 				analyzer = s.profiler.Push("[timer]" + t.GetName())
 			}
 			t.Do()
 			if analyzer != nil {
 				analyzer.Pop()
-				analyzer = nil
 			}
 		}
+		rs.end()
 
 		if bStop == true {
 			break
@@ -248,6 +397,171 @@ func (s *Service) run() {
 	}
 }
 
+// handleMailbox 停止时按策略处理邮箱中剩余的事件
+func (s *Service) handleMailbox(rs *runState, chanEvent chan event.IEvent) {
+	if s.stopPolicy == StopDropEvent {
+		if num := len(chanEvent); num > 0 {
+			log.Warnf("service is stopping and drops events,serviceName:%s,eventNum:%d", s.GetName(), num)
+		}
+		s.dropMailbox(chanEvent)
+		return
+	}
+
+	var deadline time.Time
+	if timeout := s.getStopTimeout(); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		if deadline.IsZero() == false && time.Now().After(deadline) {
+			log.Warnf("service drain events timeout,serviceName:%s,dropped eventNum:%d", s.GetName(), len(chanEvent))
+			s.dropMailbox(chanEvent)
+			return
+		}
+
+		select {
+		case ev := <-chanEvent:
+			s.handleEvent(rs, ev)
+			rs.end()
+		default:
+			return
+		}
+	}
+}
+
+// dropMailbox 丢弃邮箱中剩余的事件，未处理的Rpc请求回复错误并释放，避免在途的入站请求数无法归零
+func (s *Service) dropMailbox(chanEvent chan event.IEvent) {
+	for {
+		select {
+		case ev := <-chanEvent:
+			if wEvent, ok := ev.(*workerEvent); ok {
+				ev = wEvent.IEvent
+			}
+			if ev.GetEventType() != event.ServiceRpcRequestEvent {
+				continue
+			}
+
+			cEvent, ok := ev.(*event.Event)
+			if ok == false {
+				continue
+			}
+			if rpcRequest, ok := cEvent.Data.(*rpc.RpcRequest); ok {
+				rpc.DropRpcRequest(rpcRequest, rpc.RpcError("service is stopping,serviceName:"+s.GetName()))
+			}
+			event.DeleteEvent(cEvent)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Service) handleEvent(rs *runState, ev event.IEvent) {
+	var analyzer *profiler.Analyzer
+	s.beginEvent(rs, ev)
+	if wEvent, ok := ev.(*workerEvent); ok {
+		wEvent.worker.eventProcessor.EventHandler(wEvent.IEvent)
+		return
+	}
+
+	switch ev.GetEventType() {
+	case event.Sys_Event_Retire:
+		log.Debugf("service OnRetire,serviceName:%s", s.GetName())
+		s.self.(IService).OnRetire()
+	case event.Sys_Event_Leader:
+		cEvent, ok := ev.(*event.Event)
+		if ok == false {
+			log.Error("Type event conversion error")
+			break
+		}
+		s.onLeaderChanged(cEvent.Data.(bool))
+		event.DeleteEvent(cEvent)
+	case event.Sys_Event_ConfigChanged:
+		cEvent, ok := ev.(*event.Event)
+		if ok == false {
+			log.Error("Type event conversion error")
+			break
+		}
+		s.onConfigChanged(cEvent.Data.(*configChangedData))
+		event.DeleteEvent(cEvent)
+	case event.ServiceRpcRequestEvent:
+		cEvent, ok := ev.(*event.Event)
+		if ok == false {
+			log.Error("Type event conversion error")
+			break
+		}
+		rpcRequest, ok := cEvent.Data.(*rpc.RpcRequest)
+		if ok == false {
+			log.Error("Type *rpc.RpcRequest conversion error")
+			break
+		}
+		if s.profiler != nil {
+			analyzer = s.profiler.Push("[Req]" + rpcRequest.RpcRequestData.GetServiceMethod())
+		}
+
+		s.GetRpcHandler().HandlerRpcRequest(rpcRequest)
+		if analyzer != nil {
+			analyzer.Pop()
+			analyzer = nil
+		}
+		event.DeleteEvent(cEvent)
+	case event.ServiceRpcResponseEvent:
+		cEvent, ok := ev.(*event.Event)
+		if ok == false {
+			log.Error("Type event conversion error")
+			break
+		}
+		rpcResponseCB, ok := cEvent.Data.(*rpc.Call)
+		if ok == false {
+			log.Error("Type *rpc.Call conversion error")
+			break
+		}
+		if s.profiler != nil {
+			analyzer = s.profiler.Push("[Res]" + rpcResponseCB.ServiceMethod)
+		}
+		s.GetRpcHandler().HandlerRpcResponseCB(rpcResponseCB)
+		if analyzer != nil {
+			analyzer.Pop()
+			analyzer = nil
+		}
+		event.DeleteEvent(cEvent)
+	default:
+		if s.profiler != nil {
+			analyzer = s.profiler.Push("[SEvent]" + strconv.Itoa(int(ev.GetEventType())))
+		}
+		s.eventProcessor.EventHandler(ev)
+		if analyzer != nil {
+			analyzer.Pop()
+			analyzer = nil
+		}
+	}
+}
+
+// beginEvent 记录正在处理的事件，标记与性能分析器一致
+func (s *Service) beginEvent(rs *runState, ev event.IEvent) {
+	if isWatchdogOpen() == false {
+		return
+	}
+
+	switch ev.GetEventType() {
+	case event.ServiceRpcRequestEvent:
+		if cEvent, ok := ev.(*event.Event); ok {
+			if rpcRequest, ok := cEvent.Data.(*rpc.RpcRequest); ok {
+				rs.begin("[Req]", rpcRequest.RpcRequestData.GetServiceMethod())
+				return
+			}
+		}
+	case event.ServiceRpcResponseEvent:
+		if cEvent, ok := ev.(*event.Event); ok {
+			if rpcResponseCB, ok := cEvent.Data.(*rpc.Call); ok {
+				rs.begin("[Res]", rpcResponseCB.ServiceMethod)
+				return
+			}
+		}
+	}
+
+	rs.begin("[SEvent]", strconv.Itoa(int(ev.GetEventType())))
+}
+
 func (s *Service) GetName() string {
 	return s.name
 }
@@ -268,21 +582,147 @@ func (s *Service) Release() {
 		for i := len(s.child) - 1; i >= 0; i-- {
 			s.ReleaseModule(s.child[i].GetModuleId())
 		}
+
+		//服务可在运行期间卸载，需解除事件绑定并取消定时器
+		s.GetEventHandler().Destroy()
+		for pTimer := range s.mapActiveTimer {
+			pTimer.Cancel()
+		}
+		for _, t := range s.mapActiveIdTimer {
+			t.Cancel()
+		}
+		s.mapActiveTimer = nil
+		s.mapActiveIdTimer = nil
+		if s.durableTimer != nil {
+			clear(s.durableTimer.mapTimer)
+		}
 	}
 }
 
 func (s *Service) OnRelease() {
 }
 
+// checkHealth 健康状态有变化时，通知cluster发布到服务发现中
+func (s *Service) checkHealth(healthCheck IHealthCheck) {
+	err := healthCheck.OnHealthCheck()
+	if (err != nil) == s.unhealthy {
+		return
+	}
+
+	s.unhealthy = err != nil
+	if err != nil {
+		log.Warnf("service is unhealthy,serviceName:%s,err:%s", s.GetName(), err)
+	} else {
+		log.Infof("service is healthy again,serviceName:%s", s.GetName())
+	}
+
+	if ServiceHealthFun != nil {
+		ServiceHealthFun(s.GetName(), err)
+	}
+}
+
+func (s *Service) onLeaderChanged(isLeader bool) {
+	singletonService, ok := s.self.(ISingletonService)
+	if ok == false {
+		return
+	}
+
+	if isLeader {
+		log.Infof("service become leader,serviceName:%s", s.GetName())
+		singletonService.OnBecomeLeader()
+	} else {
+		log.Infof("service lose leadership,serviceName:%s", s.GetName())
+		singletonService.OnLoseLeadership()
+	}
+}
+
+func (s *Service) onConfigChanged(data *configChangedData) {
+	if data.isGlobal {
+		if globalCfgChanged, ok := s.self.(IGlobalConfigChanged); ok {
+			log.Infof("service global config changed,serviceName:%s", s.GetName())
+			globalCfgChanged.OnGlobalConfigChanged(data.oldCfg, data.newCfg)
+		}
+		return
+	}
+
+	oldCfg := s.serviceCfg
+	s.serviceCfg = data.newCfg
+	if cfgChanged, ok := s.self.(IConfigChanged); ok {
+		log.Infof("service config changed,serviceName:%s", s.GetName())
+		cfgChanged.OnConfigChanged(oldCfg, data.newCfg)
+	}
+}
+
 func (s *Service) OnInit() error {
 	return nil
 }
 
+// SetStopTimeout 设置本服务的停止超时时间，未设置时使用默认值，StopWaitForever表示一直等待
+func (s *Service) SetStopTimeout(timeout time.Duration) {
+	s.stopTimeout = timeout
+}
+
+// SetStopPolicy 设置本服务停止时对邮箱中未处理事件的策略
+func (s *Service) SetStopPolicy(policy StopPolicy) {
+	s.stopPolicy = policy
+}
+
+// getStopTimeout 返回0表示一直等待
+func (s *Service) getStopTimeout() time.Duration {
+	if s.stopTimeout < 0 {
+		return 0
+	}
+
+	if s.stopTimeout > 0 {
+		return s.stopTimeout
+	}
+
+	return defaultStopTimeout
+}
+
 func (s *Service) Stop() {
+	s.stop(s.getStopTimeout())
+}
+
+// stop 停止服务，超过timeout未停止时打印仍在运行的服务协程并返回false，timeout为0表示一直等待
+func (s *Service) stop(timeout time.Duration) bool {
 	log.Info("stop " + s.GetName() + " service ")
 	close(s.closeSig)
-	s.wg.Wait()
-	log.Info(s.GetName() + " service has been stopped")
+
+	if timeout <= 0 {
+		s.wg.Wait()
+		log.Info(s.GetName() + " service has been stopped")
+		return true
+	}
+
+	chanDone := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(chanDone)
+	}()
+
+	stopTimer := time.NewTimer(timeout)
+	defer stopTimer.Stop()
+	select {
+	case <-chanDone:
+		log.Info(s.GetName() + " service has been stopped")
+		return true
+	case <-stopTimer.C:
+		s.reportRunning(timeout)
+		return false
+	}
+}
+
+// reportRunning 打印停止超时时仍在运行的服务协程
+func (s *Service) reportRunning(timeout time.Duration) {
+	allStack := getAllStack()
+	for _, rs := range s.getRunStateList() {
+		var tag string
+		if pTag := rs.tag.Load(); pTag != nil && atomic.LoadInt64(&rs.startTime) != 0 {
+			tag = *pTag
+		}
+		log.Errorf("service stop timeout,serviceName:%s,timeout:%s,tag:%s,goroutineId:%d\n%s", s.GetName(), timeout, tag, rs.goroutineId, findGoroutineStack(allStack, rs.goroutineId))
+	}
 }
 
 func (s *Service) GetServiceCfg() interface{} {
@@ -345,6 +785,13 @@ func (s *Service) OnNatsConnEvent(ev event.IEvent) {
 }
 
 func (s *Service) OnDiscoverServiceEvent(ev event.IEvent) {
+	if he, ok := ev.(*ServiceHealthEvent); ok {
+		if healthListener, ok := s.discoveryServiceLister.(rpc.IServiceHealthListener); ok {
+			healthListener.OnServiceHealthChanged(he.NodeId, he.ServiceName, he.Healthy)
+		}
+		return
+	}
+
 	de := ev.(*DiscoveryServiceEvent)
 	if de.IsDiscovery {
 		s.discoveryServiceLister.OnDiscoveryService(de.NodeId, de.ServiceName)
@@ -407,6 +854,10 @@ func (s *Service) PushEvent(ev event.IEvent) error {
 }
 
 func (s *Service) pushEvent(ev event.IEvent) error {
+	if len(s.workerList) > 0 {
+		return s.pushWorkerEvent(ev)
+	}
+
 	if len(s.chanEvent) >= maxServiceEventChannelNum {
 		err := errors.New("the event channel in the service is full")
 		log.Error(err.Error())
@@ -418,11 +869,21 @@ func (s *Service) pushEvent(ev event.IEvent) error {
 }
 
 func (s *Service) GetServiceEventChannelNum() int {
-	return len(s.chanEvent)
+	num := len(s.chanEvent)
+	for i := 1; i < len(s.workerList); i++ {
+		num += len(s.workerList[i].chanEvent)
+	}
+
+	return num
 }
 
 func (s *Service) GetServiceTimerChannelNum() int {
-	return len(s.dispatcher.ChanTimer)
+	num := len(s.dispatcher.ChanTimer)
+	for i := 1; i < len(s.workerList); i++ {
+		num += len(s.workerList[i].dispatcher.ChanTimer)
+	}
+
+	return num
 }
 
 func (s *Service) SetEventChannelNum(num int) {
@@ -1,27 +1,55 @@
 package service
 
 import (
+	"fmt"
 	"github.com/duanhf2012/origin/v2/log"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 // 本地所有的service
+var serviceLocker sync.RWMutex //运行期间可安装与卸载服务
 var mapServiceName map[string]IService
 var setupServiceList []IService
 
 type RegRpcEventFunType func(serviceName string)
 type RegDiscoveryServiceEventFunType func(serviceName string)
 
+type ServiceHealthFunType func(serviceName string, err error)
+
 var RegRpcEventFun RegRpcEventFunType
 var UnRegRpcEventFun RegRpcEventFunType
 
+// ServiceHealthFun 服务健康状态变化时回调，由cluster注册
+var ServiceHealthFun ServiceHealthFunType
+
+// IsServiceDiscoveredFun 判断服务是否已被发现，由cluster注册
+var IsServiceDiscoveredFun func(serviceName string) bool
+
+var localNodeId string
+
+// SetLocalNodeId 设置本结点id，由node在安装服务前调用
+func SetLocalNodeId(nodeId string) {
+	localNodeId = nodeId
+}
+
 func init() {
 	mapServiceName = map[string]IService{}
 	setupServiceList = []IService{}
 }
 
 func Init() {
-	for _, s := range setupServiceList {
+	//按依赖关系调整顺序，Init、Start按该顺序，Stop按相反顺序
+	err := sortServiceList()
+	if err != nil {
+		log.Errorf("Failed to sort service by dependency,error:%s", err)
+		os.Exit(1)
+	}
+
+	for _, s := range getServiceList() {
 		err := s.OnInit()
 		if err != nil {
 			log.Errorf("Failed to initialize %s service,error:%s", s.GetName(), err)
@@ -31,6 +59,9 @@ func Init() {
 }
 
 func Setup(s IService) bool {
+	serviceLocker.Lock()
+	defer serviceLocker.Unlock()
+
 	_, ok := mapServiceName[s.GetName()]
 	if ok == true {
 		return false
@@ -41,7 +72,26 @@ func Setup(s IService) bool {
 	return true
 }
 
+// Remove 移除已停止的服务
+func Remove(serviceName string) bool {
+	serviceLocker.Lock()
+	defer serviceLocker.Unlock()
+
+	if _, ok := mapServiceName[serviceName]; ok == false {
+		return false
+	}
+
+	delete(mapServiceName, serviceName)
+	setupServiceList = slices.DeleteFunc(setupServiceList, func(s IService) bool {
+		return s.GetName() == serviceName
+	})
+	return true
+}
+
 func GetService(serviceName string) IService {
+	serviceLocker.RLock()
+	defer serviceLocker.RUnlock()
+
 	s, ok := mapServiceName[serviceName]
 	if ok == false {
 		return nil
@@ -50,20 +100,155 @@ func GetService(serviceName string) IService {
 	return s
 }
 
-func Start() {
+// sortServiceList 按服务声明的依赖进行拓扑排序，没有依赖关系的服务保持安装顺序，存在循环依赖时返回错误
+func sortServiceList() error {
+	serviceLocker.Lock()
+	defer serviceLocker.Unlock()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	mapState := make(map[string]int, len(setupServiceList))
+	sortedList := make([]IService, 0, len(setupServiceList))
+	var path []string
+
+	var visit func(s IService) error
+	visit = func(s IService) error {
+		switch mapState[s.GetName()] {
+		case visited:
+			return nil
+		case visiting:
+			idx := slices.Index(path, s.GetName())
+			return fmt.Errorf("circular dependency %s", strings.Join(append(path[idx:], s.GetName()), " -> "))
+		}
+
+		mapState[s.GetName()] = visiting
+		path = append(path, s.GetName())
+		for _, dependName := range getDependsOn(s) {
+			//不在本结点的依赖为远程服务，不参与排序
+			dependService, ok := mapServiceName[dependName]
+			if ok == false {
+				continue
+			}
+
+			if err := visit(dependService); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		mapState[s.GetName()] = visited
+		sortedList = append(sortedList, s)
+
+		return nil
+	}
+
 	for _, s := range setupServiceList {
+		if err := visit(s); err != nil {
+			return err
+		}
+	}
+
+	setupServiceList = sortedList
+	return nil
+}
+
+func getDependsOn(s IService) []string {
+	dependOn, ok := s.(IDependOn)
+	if ok == false {
+		return nil
+	}
+
+	return dependOn.DependsOn()
+}
+
+// getRemoteDependsOn 获取不在本结点的依赖服务
+func getRemoteDependsOn(s IService) []string {
+	var remoteList []string
+	for _, dependName := range getDependsOn(s) {
+		if GetService(dependName) == nil {
+			remoteList = append(remoteList, dependName)
+		}
+	}
+
+	return remoteList
+}
+
+// getServiceList 获取按依赖与安装顺序排列的服务列表
+func getServiceList() []IService {
+	serviceLocker.RLock()
+	defer serviceLocker.RUnlock()
+
+	return slices.Clone(setupServiceList)
+}
+
+// GetServiceList 获取本结点按依赖与安装顺序排列的服务列表
+func GetServiceList() []IService {
+	return getServiceList()
+}
+
+func Start() {
+	for _, s := range getServiceList() {
 		s.Start()
 	}
 }
 
-func StopAllService() {
-	for i := len(setupServiceList) - 1; i >= 0; i-- {
-		setupServiceList[i].Stop()
+type iStopper interface {
+	getStopTimeout() time.Duration
+	stop(timeout time.Duration) bool
+}
+
+// StopAllService 按相反顺序停止所有服务，每个服务最多等待自己的停止超时时间，
+// 所有服务共享总超时时间，超时后不再等待剩余服务，返回是否所有服务都已停止
+func StopAllService() bool {
+	var deadline time.Time
+	if stopAllTimeout > 0 {
+		deadline = time.Now().Add(stopAllTimeout)
+	}
+
+	allStopped := true
+	serviceList := getServiceList()
+	for i := len(serviceList) - 1; i >= 0; i-- {
+		stopper, ok := serviceList[i].(iStopper)
+		if ok == false {
+			serviceList[i].Stop()
+			continue
+		}
+
+		timeout := stopper.getStopTimeout()
+		if deadline.IsZero() == false {
+			remain := time.Until(deadline)
+			if remain <= 0 {
+				//总超时时间已到，通知停止但不再等待
+				remain = time.Nanosecond
+			}
+			if timeout <= 0 || remain < timeout {
+				timeout = remain
+			}
+		}
+
+		if stopper.stop(timeout) == false {
+			allStopped = false
+		}
 	}
+
+	return allStopped
 }
 
 func NotifyAllServiceRetire() {
-	for i := len(setupServiceList) - 1; i >= 0; i-- {
-		setupServiceList[i].SetRetire()
+	serviceList := getServiceList()
+	for i := len(serviceList) - 1; i >= 0; i-- {
+		serviceList[i].SetRetire()
 	}
 }
+
+// GetPendingEventNum 获取所有服务中等待处理的事件数
+func GetPendingEventNum() int {
+	num := 0
+	for _, s := range getServiceList() {
+		num += s.GetServiceEventChannelNum()
+	}
+
+	return num
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bytes"
+	"github.com/duanhf2012/origin/v2/log"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const maxStackBufLen = 64 * 1024 * 1024
+
+// StuckInfo 服务协程处理某一项超时的信息
+type StuckInfo struct {
+	ServiceName string
+	Tag         string //正在处理的项，如"[Req]TestService.RPC_Test"、"[timer]xxx"
+	GoroutineId uint64
+	Elapsed     time.Duration
+	Stack       string //该协程的调用栈
+}
+
+// WatchdogHook 发现服务卡住时回调，在看门狗协程中执行，同一项每超过阈值的倍数(1、2、4、8...)回调一次
+type WatchdogHook func(info *StuckInfo)
+
+var watchdogOpen int32
+var watchdogLocker sync.RWMutex
+var watchdogHookList []WatchdogHook
+var watchdogExitTimeout time.Duration
+var watchdogExitHook WatchdogHook
+
+// runState 记录服务协程当前正在处理的项
+type runState struct {
+	goroutineId uint64
+	startTime   int64 //开始处理的时间(UnixNano)，为0表示空闲
+	tag         atomic.Pointer[string]
+
+	//以下只在看门狗协程中访问
+	reportStartTime int64
+	nextReport      time.Duration
+}
+
+type iRunStateGetter interface {
+	getRunStateList() []*runState
+}
+
+func isWatchdogOpen() bool {
+	return atomic.LoadInt32(&watchdogOpen) != 0
+}
+
+// StartWatchdog 启动看门狗，服务协程处理一项(rpc、定时器、事件或回调)超过threshold时打印该协程的调用栈
+func StartWatchdog(threshold time.Duration) {
+	if threshold <= 0 || atomic.SwapInt32(&watchdogOpen, 1) != 0 {
+		return
+	}
+
+	interval := threshold / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	} else if interval > time.Second {
+		interval = time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkStuck(threshold)
+		}
+	}()
+}
+
+// RegWatchdogHook 注册看门狗回调，可以用于告警或者重启结点
+func RegWatchdogHook(hook WatchdogHook) {
+	watchdogLocker.Lock()
+	defer watchdogLocker.Unlock()
+
+	watchdogHookList = append(watchdogHookList, hook)
+}
+
+// SetWatchdogExit 服务协程处理一项超过timeout时回调hook，如退出进程。
+// 每次检查都会判断，不受阈值倍数的限制，最迟在超过timeout后一个检查间隔(不超过1秒)内回调
+func SetWatchdogExit(timeout time.Duration, hook WatchdogHook) {
+	watchdogLocker.Lock()
+	defer watchdogLocker.Unlock()
+
+	watchdogExitTimeout = timeout
+	watchdogExitHook = hook
+}
+
+func checkStuck(threshold time.Duration) {
+	watchdogLocker.RLock()
+	exitTimeout := watchdogExitTimeout
+	exitHook := watchdogExitHook
+	watchdogLocker.RUnlock()
+
+	var stuckList []*StuckInfo
+	var exitInfo *StuckInfo
+	now := time.Now().UnixNano()
+	for _, s := range getServiceList() {
+		getter, ok := s.(iRunStateGetter)
+		if ok == false {
+			continue
+		}
+
+		for _, rs := range getter.getRunStateList() {
+			startTime := atomic.LoadInt64(&rs.startTime)
+			if startTime == 0 {
+				continue
+			}
+
+			if rs.reportStartTime != startTime {
+				rs.reportStartTime = startTime
+				rs.nextReport = threshold
+			}
+
+			elapsed := time.Duration(now - startTime)
+			isExit := exitHook != nil && exitTimeout > 0 && elapsed >= exitTimeout
+			if elapsed < rs.nextReport && (isExit == false || exitInfo != nil) {
+				continue
+			}
+
+			var tag string
+			if pTag := rs.tag.Load(); pTag != nil {
+				tag = *pTag
+			}
+			info := &StuckInfo{ServiceName: s.GetName(), Tag: tag, GoroutineId: rs.goroutineId, Elapsed: elapsed}
+			if isExit == true && exitInfo == nil {
+				exitInfo = info
+			}
+
+			if elapsed >= rs.nextReport {
+				rs.nextReport *= 2
+				stuckList = append(stuckList, info)
+			}
+		}
+	}
+
+	if len(stuckList) == 0 && exitInfo == nil {
+		return
+	}
+
+	allStack := getAllStack()
+	watchdogLocker.RLock()
+	hookList := watchdogHookList
+	watchdogLocker.RUnlock()
+
+	for _, info := range stuckList {
+		info.Stack = findGoroutineStack(allStack, info.GoroutineId)
+		log.Errorf("service is stuck,serviceName:%s,tag:%s,goroutineId:%d,elapsed:%s\n%s", info.ServiceName, info.Tag, info.GoroutineId, info.Elapsed, info.Stack)
+		for _, hook := range hookList {
+			hook(info)
+		}
+	}
+
+	if exitInfo != nil {
+		if exitInfo.Stack == "" {
+			exitInfo.Stack = findGoroutineStack(allStack, exitInfo.GoroutineId)
+		}
+		exitHook(exitInfo)
+	}
+}
+
+func getAllStack() []byte {
+	buf := make([]byte, 1024*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackBufLen {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// findGoroutineStack 从所有协程的调用栈中找到指定协程的调用栈
+func findGoroutineStack(allStack []byte, goroutineId uint64) string {
+	prefix := []byte("goroutine " + strconv.FormatUint(goroutineId, 10) + " [")
+	for _, stack := range bytes.Split(allStack, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, prefix) {
+			return string(stack)
+		}
+	}
+
+	return ""
+}
+
+func getGoroutineId() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if idx := bytes.IndexByte(buf, ' '); idx > 0 {
+		buf = buf[:idx]
+	}
+
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+func (rs *runState) begin(tagPrefix string, name string) {
+	if isWatchdogOpen() == false {
+		return
+	}
+
+	tag := tagPrefix + name
+	rs.tag.Store(&tag)
+	atomic.StoreInt64(&rs.startTime, time.Now().UnixNano())
+}
+
+func (rs *runState) end() {
+	atomic.StoreInt64(&rs.startTime, 0)
+}
+
+func (s *Service) addRunState() *runState {
+	rs := &runState{goroutineId: getGoroutineId()}
+
+	s.runStateLocker.Lock()
+	defer s.runStateLocker.Unlock()
+	s.runStateList = append(slices.Clone(s.runStateList), rs)
+
+	return rs
+}
+
+func (s *Service) removeRunState(rs *runState) {
+	s.runStateLocker.Lock()
+	defer s.runStateLocker.Unlock()
+
+	s.runStateList = slices.DeleteFunc(slices.Clone(s.runStateList), func(r *runState) bool {
+		return r == rs
+	})
+}
+
+func (s *Service) getRunStateList() []*runState {
+	s.runStateLocker.Lock()
+	defer s.runStateLocker.Unlock()
+
+	return s.runStateList
+}
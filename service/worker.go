@@ -0,0 +1,255 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/concurrent"
+	"github.com/duanhf2012/origin/v2/event"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"sync"
+)
+
+// IWorkerKey rpc请求参数或者事件实现该接口时，按返回的key分配工作协程
+type IWorkerKey interface {
+	GetWorkerKey() uint64
+}
+
+// WorkerKeyFun 从rpc请求参数或者事件中取出key，返回false表示没有key，由0号工作协程处理
+type WorkerKeyFun func(data interface{}) (uint64, bool)
+
+// Worker 并行工作协程的根模块，拥有独立的邮箱、定时器、事件处理器与回调管道，0号工作协程即服务的主协程。
+// 在分配到该工作协程的处理中，需通过它(或者AddWorkerModule添加的模块)注册定时器、AsyncDo与发起异步调用，回调都返回到该工作协程
+type Worker struct {
+	Module
+
+	workerId       int
+	service        *Service
+	chanEvent      chan event.IEvent
+	chanCallback   chan func(error) //AsyncDo的回调，0号工作协程使用服务的回调管道
+	eventProcessor event.IEventProcessor
+	rpcHandler     rpc.RpcHandler
+	concurrent     workerConcurrent
+}
+
+// workerEvent 投递给工作协程事件处理器的事件，只执行该工作协程中模块注册的接收器
+type workerEvent struct {
+	event.IEvent
+	worker *Worker
+}
+
+// workerConcurrent 与服务共用并发协程池，AsyncDo的回调投递到工作协程的回调管道
+type workerConcurrent struct {
+	*concurrent.Concurrent
+	worker *Worker
+}
+
+func (c *workerConcurrent) AsyncDo(f func() bool, cb func(err error)) {
+	c.AsyncDoByQueue(0, f, cb)
+}
+
+func (c *workerConcurrent) AsyncDoByQueue(queueId int64, fn func() bool, cb func(err error)) {
+	c.Concurrent.AsyncDoByQueueTo(c.worker.chanCallback, queueId, fn, cb)
+}
+
+// GetWorkerId 获取工作协程id，实现rpc.IRpcWorker，异步调用的回调按此返回
+func (w *Worker) GetWorkerId() int {
+	return w.workerId
+}
+
+func (w *Worker) GetName() string {
+	return w.service.GetName()
+}
+
+// PushEvent 工作协程中的模块监听的事件投递到该工作协程
+func (w *Worker) PushEvent(ev event.IEvent) error {
+	if len(w.chanEvent) >= maxServiceEventChannelNum {
+		err := errors.New("the event channel in the service worker is full")
+		log.Error(err.Error())
+		return err
+	}
+
+	w.chanEvent <- &workerEvent{IEvent: ev, worker: w}
+	return nil
+}
+
+// OpenWorker 打开并行工作协程模式，需要在OnInit中调用。
+// rpc请求与事件按key分配到workerNum个工作协程中的一个，相同key的处理保持顺序且在同一协程中，不同key并行处理。
+// 没有key的rpc请求、事件、服务自身的定时器与AsyncDo回调由0号工作协程处理，服务的定时器与AsyncDo只能在0号工作协程中使用，
+// 其他工作协程中通过GetWorker获取的Worker注册定时器、AsyncDo与发起异步调用
+func (s *Service) OpenWorker(workerNum int, keyFun WorkerKeyFun) bool {
+	if s.startStatus == true || s.profiler != nil || s.goroutineNum > 1 || len(s.workerList) > 0 {
+		log.Error("open worker is not allowed after start,with profiler or multi-coroutine mode.")
+		return false
+	}
+
+	if workerNum < 2 {
+		log.Errorf("worker num %d is less than 2", workerNum)
+		return false
+	}
+
+	cr := s.IConcurrent.(*concurrent.Concurrent)
+	workerList := make([]*Worker, workerNum)
+	for i := 0; i < workerNum; i++ {
+		w := &Worker{workerId: i, service: s}
+		dispatcher := s.dispatcher
+		w.chanEvent = s.chanEvent
+		if i > 0 {
+			dispatcher = timer.NewDispatcher(timerDispatcherLen)
+			w.chanEvent = make(chan event.IEvent, cap(s.chanEvent))
+		}
+
+		if _, err := s.addModule(w, dispatcher); err != nil {
+			log.Errorf("open worker %d fail,err:%s", i, err)
+			return false
+		}
+
+		w.eventProcessor = event.NewEventProcessor()
+		w.eventProcessor.Init(w)
+		w.eventHandler.Init(w.eventProcessor)
+		if i > 0 {
+			w.rpcHandler.InitRpcHandler(w, s.rpcHandler.GetRpcClientFun(), s.rpcHandler.GetRpcServer(), s)
+			w.IRpcHandler = &w.rpcHandler
+			w.concurrent = workerConcurrent{Concurrent: cr, worker: w}
+			w.IConcurrent = &w.concurrent
+		}
+		workerList[i] = w
+	}
+
+	s.workerKeyFun = keyFun
+	s.workerList = workerList
+	return true
+}
+
+// GetWorkerNum 获取工作协程数量，未打开时为1
+func (s *Service) GetWorkerNum() int {
+	if len(s.workerList) == 0 {
+		return 1
+	}
+
+	return len(s.workerList)
+}
+
+// GetWorkerId 获取key分配到的工作协程
+func (s *Service) GetWorkerId(key uint64) int {
+	return int(key % uint64(s.GetWorkerNum()))
+}
+
+// GetWorker 获取工作协程，只能在该工作协程的处理中使用，未打开或者workerId超出范围时返回nil
+func (s *Service) GetWorker(workerId int) *Worker {
+	if workerId < 0 || workerId >= len(s.workerList) {
+		return nil
+	}
+
+	return s.workerList[workerId]
+}
+
+// AddWorkerModule 添加模块并分配到工作协程，模块及其子模块的定时器、事件、AsyncDo回调与异步调用回调都在该工作协程中执行
+func (s *Service) AddWorkerModule(workerId int, module IModule) (uint32, error) {
+	if workerId < 0 || workerId >= s.GetWorkerNum() {
+		return 0, fmt.Errorf("worker id %d is out of range", workerId)
+	}
+
+	if len(s.workerList) == 0 {
+		return s.AddModule(module)
+	}
+
+	return s.workerList[workerId].AddModule(module)
+}
+
+// selectWorker 按key选择处理事件的工作协程
+func (s *Service) selectWorker(ev event.IEvent) *Worker {
+	var data interface{} = ev
+	switch ev.GetEventType() {
+	case event.ServiceRpcRequestEvent:
+		if cEvent, ok := ev.(*event.Event); ok {
+			if rpcRequest, ok := cEvent.Data.(*rpc.RpcRequest); ok {
+				data = rpcRequest.GetInParam()
+			}
+		}
+	case event.ServiceRpcResponseEvent:
+		if cEvent, ok := ev.(*event.Event); ok {
+			if call, ok := cEvent.Data.(*rpc.Call); ok {
+				return s.workerList[call.GetWorkerId()%len(s.workerList)]
+			}
+		}
+	case event.Sys_Event_Retire, event.Sys_Event_Leader, event.Sys_Event_ConfigChanged:
+		//服务状态变化由0号工作协程处理
+		return s.workerList[0]
+	}
+
+	if workerKey, ok := data.(IWorkerKey); ok {
+		return s.workerList[s.GetWorkerId(workerKey.GetWorkerKey())]
+	}
+
+	if s.workerKeyFun != nil {
+		if key, ok := s.workerKeyFun(data); ok {
+			return s.workerList[s.GetWorkerId(key)]
+		}
+	}
+
+	return s.workerList[0]
+}
+
+func (s *Service) startWorker(waitRun *sync.WaitGroup) {
+	cr := s.IConcurrent.(*concurrent.Concurrent)
+	for i := 1; i < len(s.workerList); i++ {
+		//与服务的回调管道容量一致，并发协程投递回调时不会因为工作协程已退出而阻塞
+		s.workerList[i].chanCallback = make(chan func(error), cap(cr.GetCallBackChannel()))
+		s.workerWg.Add(1)
+		waitRun.Add(1)
+		go func(workerId int) {
+			waitRun.Done()
+			s.runWorker(workerId)
+		}(i)
+	}
+}
+
+func (s *Service) runWorker(workerId int) {
+	defer s.workerWg.Done()
+
+	w := s.workerList[workerId]
+	cr := s.IConcurrent.(*concurrent.Concurrent)
+	rs := s.addRunState()
+	defer s.removeRunState(rs)
+
+	for {
+		select {
+		case <-s.closeSig:
+			s.handleMailbox(rs, w.chanEvent)
+			return
+		case cb := <-w.chanCallback:
+			rs.begin("[Callback]", "")
+			cr.DoCallback(cb)
+		case ev := <-w.chanEvent:
+			s.handleEvent(rs, ev)
+		case t := <-w.dispatcher.ChanTimer:
+			rs.begin("[timer]", t.GetName())
+			t.Do()
+		}
+		rs.end()
+	}
+}
+
+// doWorkerCallback 所有工作协程退出并关闭Concurrent后，在主协程中执行剩余的回调
+func (s *Service) doWorkerCallback(cr *concurrent.Concurrent) {
+	for i := 1; i < len(s.workerList); i++ {
+		w := s.workerList[i]
+		for len(w.chanCallback) > 0 {
+			cr.DoCallback(<-w.chanCallback)
+		}
+	}
+}
+
+func (s *Service) pushWorkerEvent(ev event.IEvent) error {
+	w := s.selectWorker(ev)
+	if len(w.chanEvent) >= maxServiceEventChannelNum {
+		err := errors.New("the event channel in the service worker is full")
+		log.Error(err.Error())
+		return err
+	}
+
+	w.chanEvent <- ev
+	return nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duanhf2012/origin/v2/event"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/util/timer"
+)
+
+const (
+	keyEventType    event.EventType = event.Sys_Event_User_Define + 1
+	moduleEventType event.EventType = event.Sys_Event_User_Define + 2
+	testKeyNum                      = 2
+	testLoopNum                     = 200
+)
+
+type keyEvent struct {
+	key uint64
+}
+
+func (e *keyEvent) GetEventType() event.EventType {
+	return keyEventType
+}
+
+func (e *keyEvent) GetWorkerKey() uint64 {
+	return e.key
+}
+
+type runResult struct {
+	workerId    int
+	goroutineId uint64
+}
+
+// counterModule 分配到工作协程的模块，mapCount只在该工作协程中访问
+type counterModule struct {
+	Module
+	workerId int
+	mapCount map[string]int
+	result   chan runResult
+}
+
+func (m *counterModule) OnInit() error {
+	m.mapCount = map[string]int{}
+	m.GetService().(*workerTestService).RegEventReceiverFunc(moduleEventType, m.GetEventHandler(), m.onModuleEvent)
+	return nil
+}
+
+func (m *counterModule) onModuleEvent(ev event.IEvent) {
+	m.mapCount["event"]++
+	m.result <- runResult{workerId: m.workerId, goroutineId: getGoroutineId()}
+}
+
+type workerTestService struct {
+	Service
+
+	arrive  sync.WaitGroup
+	modules [testKeyNum]*counterModule
+	result  chan runResult
+}
+
+func (s *workerTestService) OnInit() error {
+	s.OpenConcurrent(2, 4, 1000)
+	if s.OpenWorker(testKeyNum, nil) == false {
+		return errors.New("open worker fail")
+	}
+
+	for i := range s.modules {
+		s.modules[i] = &counterModule{workerId: i, result: s.result}
+		if _, err := s.AddWorkerModule(i, s.modules[i]); err != nil {
+			return err
+		}
+	}
+
+	s.RegEventReceiverFunc(keyEventType, s.GetEventHandler(), s.onKeyEvent)
+	s.RegEventReceiverFunc(moduleEventType, s.GetEventHandler(), s.onModuleEvent)
+	return nil
+}
+
+func (s *workerTestService) onModuleEvent(ev event.IEvent) {
+	s.result <- runResult{workerId: 0, goroutineId: getGoroutineId()}
+}
+
+// onKeyEvent 等待所有key的处理都开始后再继续，不同key没有并行处理时会超时
+func (s *workerTestService) onKeyEvent(ev event.IEvent) {
+	key := ev.(*keyEvent).key
+	workerId := s.GetWorkerId(key)
+	w := s.GetWorker(workerId)
+	m := s.modules[workerId]
+	goroutineId := getGoroutineId()
+
+	s.arrive.Done()
+	if waitTimeout(&s.arrive, time.Second) == false {
+		s.result <- runResult{workerId: -1}
+		return
+	}
+
+	s.result <- runResult{workerId: workerId, goroutineId: goroutineId}
+	for i := 0; i < testLoopNum; i++ {
+		var timerId uint64
+		w.SafeAfterFunc(&timerId, time.Millisecond, nil, func(uint64, interface{}) {
+			m.mapCount["timer"]++
+			w.AsyncDo(func() bool {
+				return true
+			}, func(err error) {
+				m.mapCount["callback"]++
+				s.result <- runResult{workerId: workerId, goroutineId: getGoroutineId()}
+			})
+		})
+	}
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func getTestRpcClient(nodeId string, serviceMethod string, filterRetire bool, client []*rpc.Client) (error, []*rpc.Client) {
+	return errors.New("no client"), nil
+}
+
+func newWorkerTestService(t *testing.T) *workerTestService {
+	timer.StartTimer(10*time.Millisecond, 1000)
+
+	s := &workerTestService{result: make(chan runResult, testKeyNum*testLoopNum*2)}
+	s.SetName("WorkerTestService")
+	s.Init(s, getTestRpcClient, nil, nil)
+	if err := s.OnInit(); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	t.Cleanup(s.Stop)
+
+	return s
+}
+
+func (s *workerTestService) waitResult(t *testing.T) runResult {
+	select {
+	case r := <-s.result:
+		if r.workerId < 0 {
+			t.Fatal("keys are not processed in parallel")
+		}
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait result timeout")
+	}
+
+	return runResult{}
+}
+
+func TestWorkerParallelKey(t *testing.T) {
+	s := newWorkerTestService(t)
+
+	//两个key分配到不同的工作协程并行处理
+	s.arrive.Add(testKeyNum)
+	for key := uint64(0); key < testKeyNum; key++ {
+		if err := s.PushEvent(&keyEvent{key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var workerGoroutine [testKeyNum]uint64
+	for i := 0; i < testKeyNum; i++ {
+		r := s.waitResult(t)
+		workerGoroutine[r.workerId] = r.goroutineId
+	}
+	if workerGoroutine[0] == 0 || workerGoroutine[0] == workerGoroutine[1] {
+		t.Fatalf("keys run in the same goroutine:%v", workerGoroutine)
+	}
+
+	//定时器与AsyncDo回调返回到创建它的工作协程
+	for i := 0; i < testKeyNum*testLoopNum; i++ {
+		r := s.waitResult(t)
+		if r.goroutineId != workerGoroutine[r.workerId] {
+			t.Fatalf("callback of worker %d runs in goroutine %d,expect %d", r.workerId, r.goroutineId, workerGoroutine[r.workerId])
+		}
+	}
+
+	//模块注册的事件接收器只在模块所在的工作协程中执行，服务的接收器在0号工作协程中执行
+	s.NotifyEvent(&event.Event{Type: moduleEventType})
+	for i := 0; i < testKeyNum+1; i++ {
+		r := s.waitResult(t)
+		if r.goroutineId != workerGoroutine[r.workerId] {
+			t.Fatalf("event receiver of worker %d runs in goroutine %d,expect %d", r.workerId, r.goroutineId, workerGoroutine[r.workerId])
+		}
+	}
+
+	for i := range s.modules {
+		w := s.GetWorker(i)
+		if w.GetWorkerId() != i {
+			t.Fatalf("worker id is %d,expect %d", w.GetWorkerId(), i)
+		}
+		if rpcWorker, ok := w.GetRpcHandler().(rpc.IRpcWorker); i > 0 && (ok == false || rpcWorker.GetWorkerId() != i) {
+			t.Fatalf("rpc handler of worker %d does not carry the worker id", i)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/duanhf2012/origin/v2/event"
 	"github.com/duanhf2012/origin/v2/log"
 	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
 	"sync"
 	"time"
 )
@@ -155,12 +156,22 @@ func (ft *FrameTimer) OnInit() error {
 	}
 
 	go func() {
-		preTime := time.Now()
+		preTime := timer.Now()
 		var preFrame FrameNumber
 
 		for {
 			time.Sleep(ft.sleepInterval)
-			frameMax := FrameNumber(time.Now().Sub(preTime) / ft.oneFrameTime)
+			elapsed := timer.Now().Sub(preTime)
+			//时钟往回调整时不重复执行已经执行过的帧
+			if elapsed < 0 {
+				continue
+			}
+
+			frameMax := FrameNumber(elapsed / ft.oneFrameTime)
+			if frameMax <= preFrame {
+				continue
+			}
+
 			for i := preFrame + 1; i <= frameMax; i++ {
 				ft.frameTick()
 			}
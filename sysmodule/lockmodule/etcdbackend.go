@@ -0,0 +1,128 @@
+package lockmodule
+
+import (
+	"context"
+	"errors"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
+	"time"
+)
+
+const etcdLockPrefix = "/origin/lock/"
+const etcdRequestTimeout = 3 * time.Second
+
+// EtcdBackend 基于etcd租约的锁，每个锁使用独立的租约，持有者失联时租约过期自动释放
+type EtcdBackend struct {
+	client *clientv3.Client
+
+	locker   sync.Mutex
+	mapLease map[leaseKey]clientv3.LeaseID //锁使用的租约，同一个后端可以被多个持有者共用
+}
+
+type leaseKey struct {
+	key   string
+	owner string
+}
+
+// NewEtcdBackend client可以使用cluster.GetEtcdClient()获取etcd服务发现的客户端
+func NewEtcdBackend(client *clientv3.Client) *EtcdBackend {
+	return &EtcdBackend{client: client, mapLease: map[leaseKey]clientv3.LeaseID{}}
+}
+
+func ttlSecond(ttl time.Duration) int64 {
+	second := int64((ttl + time.Second - 1) / time.Second)
+	if second <= 0 {
+		return 1
+	}
+
+	return second
+}
+
+func (eb *EtcdBackend) TryLock(key string, owner string, ttl time.Duration) (bool, error) {
+	if eb.client == nil {
+		return false, errors.New("etcd client is nil")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	lease, err := eb.client.Grant(ctx, ttlSecond(ttl))
+	if err != nil {
+		return false, err
+	}
+
+	etcdKey := etcdLockPrefix + key
+	resp, err := eb.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(etcdKey), "=", 0)).
+		Then(clientv3.OpPut(etcdKey, owner, clientv3.WithLease(lease.ID))).
+		Commit()
+	if err != nil || resp.Succeeded == false {
+		eb.client.Revoke(ctx, lease.ID)
+		return false, err
+	}
+
+	eb.locker.Lock()
+	eb.mapLease[leaseKey{key, owner}] = lease.ID
+	eb.locker.Unlock()
+
+	return true, nil
+}
+
+func (eb *EtcdBackend) Renew(key string, owner string, ttl time.Duration) (bool, error) {
+	eb.locker.Lock()
+	leaseID, ok := eb.mapLease[leaseKey{key, owner}]
+	eb.locker.Unlock()
+	if ok == false {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	resp, err := eb.client.KeepAliveOnce(ctx, leaseID)
+	if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		eb.removeLease(leaseKey{key, owner}, leaseID)
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return resp.TTL > 0, nil
+}
+
+func (eb *EtcdBackend) Unlock(key string, owner string) error {
+	eb.locker.Lock()
+	leaseID, ok := eb.mapLease[leaseKey{key, owner}]
+	eb.locker.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	etcdKey := etcdLockPrefix + key
+	_, err := eb.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(etcdKey), "=", owner)).
+		Then(clientv3.OpDelete(etcdKey)).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if ok == true {
+		eb.removeLease(leaseKey{key, owner}, leaseID)
+		eb.client.Revoke(ctx, leaseID)
+	}
+
+	return nil
+}
+
+func (eb *EtcdBackend) removeLease(lk leaseKey, leaseID clientv3.LeaseID) {
+	eb.locker.Lock()
+	defer eb.locker.Unlock()
+
+	if eb.mapLease[lk] == leaseID {
+		delete(eb.mapLease, lk)
+	}
+}
@@ -0,0 +1,319 @@
+package lockmodule
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/cluster"
+	"github.com/duanhf2012/origin/v2/event"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"github.com/duanhf2012/origin/v2/util/uuid"
+	"maps"
+	"sync"
+	"time"
+)
+
+const DefaultTTL = 10 * time.Second
+const lockRetryInterval = 100 * time.Millisecond
+
+var ErrLockTimeout = errors.New("lock timeout")
+
+// ILockBackend 分布式锁的存储后端，owner为持有者标识，只有持有者能续约与释放
+type ILockBackend interface {
+	TryLock(key string, owner string, ttl time.Duration) (bool, error)
+	Renew(key string, owner string, ttl time.Duration) (bool, error) //锁已不属于owner时返回false
+	Unlock(key string, owner string) error
+}
+
+type LockEventType int8
+
+const (
+	LockLost    LockEventType = 0 //续约失败或租约过期，锁已丢失
+	LockElected LockEventType = 1 //竞选成功，成为Leader
+)
+
+type LockEvent struct {
+	Type       LockEventType
+	Key        string
+	lockModule *LockModule
+}
+
+func (le *LockEvent) GetEventType() event.EventType {
+	return event.Sys_Event_Lock
+}
+
+// LockModule 分布式锁模块，后台协程按ttl/3为持有的锁续约，锁丢失与竞选结果以事件投递到所属服务的协程
+type LockModule struct {
+	service.Module
+
+	backend ILockBackend
+	ttl     time.Duration
+	owner   string
+
+	locker      sync.Mutex
+	mapLock     map[string]time.Time        //map[key]最后一次加锁或续约成功的时间
+	mapCampaign map[string]func(key string) //map[key]成为Leader的回调
+	campaignSig chan struct{}
+	closeSig    chan struct{}
+
+	funLockLost func(key string)
+}
+
+// Init 设置存储后端与锁的租约时长，ttl为0时使用DefaultTTL，需要在AddModule之前调用
+func (lm *LockModule) Init(backend ILockBackend, ttl time.Duration) {
+	lm.backend = backend
+	lm.ttl = ttl
+}
+
+// SetLockLostFun 设置锁丢失的回调，在所属服务的协程中执行
+func (lm *LockModule) SetLockLostFun(funLockLost func(key string)) {
+	lm.funLockLost = funLockLost
+}
+
+func (lm *LockModule) OnInit() error {
+	if lm.backend == nil {
+		return errors.New("lock backend is nil")
+	}
+
+	if lm.ttl <= 0 {
+		lm.ttl = DefaultTTL
+	}
+
+	lm.owner = fmt.Sprintf("%s_%s_%s", cluster.GetCluster().GetLocalNodeInfo().NodeId, lm.GetService().GetName(), uuid.Rand().HexEx())
+	lm.mapLock = map[string]time.Time{}
+	lm.mapCampaign = map[string]func(key string){}
+	lm.campaignSig = make(chan struct{}, 1)
+	lm.closeSig = make(chan struct{})
+
+	lm.GetEventProcessor().RegEventReceiverFunc(event.Sys_Event_Lock, lm.GetEventHandler(), lm.onLockEvent)
+	go lm.run()
+
+	return nil
+}
+
+func (lm *LockModule) OnRelease() {
+	close(lm.closeSig)
+
+	lm.locker.Lock()
+	mapLock := lm.mapLock
+	lm.mapLock = map[string]time.Time{}
+	lm.mapCampaign = map[string]func(key string){}
+	lm.locker.Unlock()
+
+	for key := range mapLock {
+		if err := lm.backend.Unlock(key, lm.owner); err != nil {
+			log.Warnf("unlock fail on release,key:%s,err:%s", key, err.Error())
+		}
+	}
+}
+
+// GetOwner 获取本模块作为锁持有者的标识
+func (lm *LockModule) GetOwner() string {
+	return lm.owner
+}
+
+// TryLock 尝试加锁，不等待，本模块已持有时直接返回true
+func (lm *LockModule) TryLock(key string) (bool, error) {
+	if lm.IsLocked(key) {
+		return true, nil
+	}
+
+	now := time.Now()
+	ok, err := lm.backend.TryLock(key, lm.owner, lm.ttl)
+	if err != nil || ok == false {
+		return false, err
+	}
+
+	lm.locker.Lock()
+	lm.mapLock[key] = now
+	lm.locker.Unlock()
+
+	return true, nil
+}
+
+// Lock 异步加锁，未获得时由服务的定时器每100ms重试一次，等待期间不阻塞服务协程。
+// 获得锁或超过timeout(返回ErrLockTimeout)后在所属服务的协程中回调cb
+func (lm *LockModule) Lock(key string, timeout time.Duration, cb func(err error)) {
+	deadline := time.Now().Add(timeout)
+	var tryLock func(*timer.Timer)
+	tryLock = func(*timer.Timer) {
+		ok, err := lm.TryLock(key)
+		if err != nil {
+			cb(err)
+			return
+		}
+
+		if ok == true {
+			cb(nil)
+			return
+		}
+
+		if time.Now().Add(lockRetryInterval).After(deadline) {
+			cb(ErrLockTimeout)
+			return
+		}
+
+		lm.AfterFunc(lockRetryInterval, tryLock)
+	}
+
+	tryLock(nil)
+}
+
+// Unlock 释放锁，锁已丢失时不返回错误
+func (lm *LockModule) Unlock(key string) error {
+	lm.locker.Lock()
+	_, ok := lm.mapLock[key]
+	delete(lm.mapLock, key)
+	lm.locker.Unlock()
+
+	if ok == false {
+		return nil
+	}
+
+	return lm.backend.Unlock(key, lm.owner)
+}
+
+// IsLocked 本模块是否持有该锁
+func (lm *LockModule) IsLocked(key string) bool {
+	lm.locker.Lock()
+	defer lm.locker.Unlock()
+
+	_, ok := lm.mapLock[key]
+	return ok
+}
+
+// Campaign 以key竞选Leader，后台持续尝试加锁，成功后在所属服务的协程中回调onElected
+// 成为Leader后锁丢失会回调SetLockLostFun设置的函数，并继续参与竞选
+func (lm *LockModule) Campaign(key string, onElected func(key string)) {
+	lm.locker.Lock()
+	lm.mapCampaign[key] = onElected
+	lm.locker.Unlock()
+
+	select {
+	case lm.campaignSig <- struct{}{}:
+	default:
+	}
+}
+
+// Resign 退出竞选，已是Leader时释放锁
+func (lm *LockModule) Resign(key string) error {
+	lm.locker.Lock()
+	delete(lm.mapCampaign, key)
+	lm.locker.Unlock()
+
+	return lm.Unlock(key)
+}
+
+func (lm *LockModule) run() {
+	ticker := time.NewTicker(lm.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lm.closeSig:
+			return
+		case <-ticker.C:
+			lm.renewAll()
+			lm.campaignAll()
+		case <-lm.campaignSig:
+			lm.campaignAll()
+		}
+	}
+}
+
+func (lm *LockModule) renewAll() {
+	lm.locker.Lock()
+	mapLock := maps.Clone(lm.mapLock)
+	lm.locker.Unlock()
+
+	for key, lastTime := range mapLock {
+		now := time.Now()
+		ok, err := lm.backend.Renew(key, lm.owner, lm.ttl)
+		if err != nil {
+			log.Warnf("renew lock fail,key:%s,err:%s", key, err.Error())
+			//租约未到期前继续重试
+			if now.Sub(lastTime) < lm.ttl {
+				continue
+			}
+		}
+
+		lm.locker.Lock()
+		_, held := lm.mapLock[key]
+		if held == true {
+			if ok == true {
+				lm.mapLock[key] = now
+			} else {
+				delete(lm.mapLock, key)
+			}
+		}
+		lm.locker.Unlock()
+
+		if held == true && ok == false {
+			log.Warnf("lock lost,key:%s,owner:%s", key, lm.owner)
+			lm.NotifyEvent(&LockEvent{Type: LockLost, Key: key, lockModule: lm})
+		}
+	}
+}
+
+func (lm *LockModule) campaignAll() {
+	lm.locker.Lock()
+	var campaignList []string
+	for key := range lm.mapCampaign {
+		if _, ok := lm.mapLock[key]; ok == false {
+			campaignList = append(campaignList, key)
+		}
+	}
+	lm.locker.Unlock()
+
+	for _, key := range campaignList {
+		now := time.Now()
+		ok, err := lm.backend.TryLock(key, lm.owner, lm.ttl)
+		if err != nil {
+			log.Warnf("campaign fail,key:%s,err:%s", key, err.Error())
+			continue
+		}
+
+		if ok == false {
+			continue
+		}
+
+		lm.locker.Lock()
+		_, campaign := lm.mapCampaign[key]
+		if campaign == true {
+			lm.mapLock[key] = now
+		}
+		lm.locker.Unlock()
+
+		//竞选期间已退出
+		if campaign == false {
+			lm.backend.Unlock(key, lm.owner)
+			continue
+		}
+
+		lm.NotifyEvent(&LockEvent{Type: LockElected, Key: key, lockModule: lm})
+	}
+}
+
+func (lm *LockModule) onLockEvent(ev event.IEvent) {
+	lockEvent, ok := ev.(*LockEvent)
+	if ok == false || lockEvent.lockModule != lm {
+		return
+	}
+
+	switch lockEvent.Type {
+	case LockLost:
+		if lm.funLockLost != nil {
+			lm.funLockLost(lockEvent.Key)
+		}
+	case LockElected:
+		lm.locker.Lock()
+		onElected := lm.mapCampaign[lockEvent.Key]
+		_, held := lm.mapLock[lockEvent.Key]
+		lm.locker.Unlock()
+
+		if onElected != nil && held == true {
+			onElected(lockEvent.Key)
+		}
+	}
+}
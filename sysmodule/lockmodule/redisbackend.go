@@ -0,0 +1,40 @@
+package lockmodule
+
+import (
+	"github.com/duanhf2012/origin/v2/sysmodule/redismodule"
+	"github.com/gomodule/redigo/redis"
+	"time"
+)
+
+const redisLockPrefix = "origin:lock:"
+
+// 只有持有者才能续约与释放，避免误删其他持有者的锁
+var renewScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`)
+var unlockScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`)
+
+// RedisBackend 基于单实例Redis的锁(SET NX PX)，Redis主从切换时可能丢失锁，不适用于需要严格互斥的场景
+type RedisBackend struct {
+	redisModule *redismodule.RedisModule
+}
+
+func NewRedisBackend(redisModule *redismodule.RedisModule) *RedisBackend {
+	return &RedisBackend{redisModule: redisModule}
+}
+
+func (rb *RedisBackend) TryLock(key string, owner string, ttl time.Duration) (bool, error) {
+	return rb.redisModule.SetStringNX(redisLockPrefix+key, owner, ttl.Milliseconds())
+}
+
+func (rb *RedisBackend) Renew(key string, owner string, ttl time.Duration) (bool, error) {
+	ret, err := redis.Int(rb.redisModule.EvalScript(renewScript, redisLockPrefix+key, owner, ttl.Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+
+	return ret == 1, nil
+}
+
+func (rb *RedisBackend) Unlock(key string, owner string) error {
+	_, err := rb.redisModule.EvalScript(unlockScript, redisLockPrefix+key, owner)
+	return err
+}
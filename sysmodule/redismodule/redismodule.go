@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/duanhf2012/origin/v2/log"
+	"io"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/duanhf2012/origin/v2/service"
@@ -1154,3 +1156,146 @@ func (m *RedisModule) OnRelease() {
 		m.redisPool.Close()
 	}
 }
+
+// Publish 向频道发布消息
+func (m *RedisModule) Publish(channel string, message interface{}) error {
+	conn, err := m.getConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", channel, message)
+	if err != nil {
+		log.Errorf("PUBLISH fail, reason:%v", err)
+		return err
+	}
+
+	return nil
+}
+
+// subscribeCloseTimeout 关闭订阅时等待退订回复的时间，超时后直接关闭连接
+const subscribeCloseTimeout = 3 * time.Second
+
+// Subscriber 订阅者，使用不属于连接池的独立连接，由接收协程负责关闭连接
+type Subscriber struct {
+	psc     redis.PubSubConn
+	isClose int32
+	done    chan struct{}
+}
+
+// Subscribe 订阅频道，订阅独占一个不属于连接池的连接并在独立协程中接收消息
+// onMessage在接收协程中回调，连接断开时回调onClose,返回的io.Closer用于关闭订阅，关闭后不再回调
+func (m *RedisModule) Subscribe(channels []string, onMessage func(channel string, data []byte), onClose func(err error)) (io.Closer, error) {
+	if m.redisPool == nil {
+		log.Error("Not Init RedisModule")
+		return nil, fmt.Errorf("not Init RedisModule")
+	}
+
+	conn, err := m.redisPool.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscriber{psc: redis.PubSubConn{Conn: conn}, done: make(chan struct{})}
+	args := make([]interface{}, 0, len(channels))
+	for _, channel := range channels {
+		args = append(args, channel)
+	}
+
+	err = sub.psc.Subscribe(args...)
+	if err != nil {
+		log.Errorf("SUBSCRIBE fail, reason:%v", err)
+		sub.psc.Close()
+		return nil, err
+	}
+
+	go sub.receive(onMessage, onClose)
+	return sub, nil
+}
+
+func (sub *Subscriber) isClosing() bool {
+	return atomic.LoadInt32(&sub.isClose) == 1
+}
+
+func (sub *Subscriber) receive(onMessage func(channel string, data []byte), onClose func(err error)) {
+	defer close(sub.done)
+	defer sub.psc.Close()
+
+	for {
+		switch v := sub.psc.Receive().(type) {
+		case redis.Message:
+			if sub.isClosing() == false {
+				onMessage(v.Channel, v.Data)
+			}
+		case redis.Subscription:
+			//退订所有频道后退出
+			if v.Count == 0 && sub.isClosing() == true {
+				return
+			}
+		case error:
+			if sub.isClosing() == false && onClose != nil {
+				onClose(v)
+			}
+			return
+		}
+	}
+}
+
+// Close 退订所有频道，等待接收协程关闭连接并退出后返回
+func (sub *Subscriber) Close() error {
+	if atomic.SwapInt32(&sub.isClose, 1) == 1 {
+		<-sub.done
+		return nil
+	}
+
+	err := sub.psc.Unsubscribe()
+	if err == nil {
+		select {
+		case <-sub.done:
+			return nil
+		case <-time.After(subscribeCloseTimeout):
+			err = errors.New("wait unsubscribe timeout")
+		}
+	}
+
+	//退订失败时直接关闭连接，接收协程读取出错后退出
+	log.Errorf("UNSUBSCRIBE fail, reason:%v", err)
+	sub.psc.Conn.Close()
+	<-sub.done
+	return err
+}
+
+// SetStringNX 键不存在时设置值并指定过期毫秒数，返回是否设置成功
+func (m *RedisModule) SetStringNX(key, value interface{}, expireMill int64) (bool, error) {
+	conn, err := m.getConn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ret, err := conn.Do("SET", key, value, "PX", expireMill, "NX")
+	if err != nil {
+		log.Errorf("SetStringNX fail, reason:%v", err)
+		return false, err
+	}
+
+	return ret != nil, nil
+}
+
+// EvalScript 执行Lua脚本，优先使用EVALSHA，脚本未缓存时自动使用EVAL
+func (m *RedisModule) EvalScript(script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	conn, err := m.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ret, err := script.Do(conn, keysAndArgs...)
+	if err != nil {
+		log.Errorf("EvalScript fail, reason:%v", err)
+		return nil, err
+	}
+
+	return ret, nil
+}
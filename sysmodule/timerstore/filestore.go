@@ -0,0 +1,140 @@
+package timerstore
+
+import (
+	"github.com/duanhf2012/origin/v2/service"
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// FileStore 本地文件存储，每个结点的每个服务一个文件
+type FileStore struct {
+	dir    string
+	locker sync.Mutex
+	mapSvc map[string]map[string]*service.DurableTimerData //map[owner]map[id]
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir, mapSvc: map[string]map[string]*service.DurableTimerData{}}, nil
+}
+
+func (fs *FileStore) Load(owner string) ([]*service.DurableTimerData, error) {
+	fs.locker.Lock()
+	defer fs.locker.Unlock()
+
+	mapTimer, err := fs.load(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	dataList := make([]*service.DurableTimerData, 0, len(mapTimer))
+	for _, data := range mapTimer {
+		dataList = append(dataList, data)
+	}
+
+	return dataList, nil
+}
+
+func (fs *FileStore) Save(owner string, data *service.DurableTimerData) error {
+	fs.locker.Lock()
+	defer fs.locker.Unlock()
+
+	mapTimer, err := fs.load(owner)
+	if err != nil {
+		return err
+	}
+
+	preData, ok := mapTimer[data.Id]
+	mapTimer[data.Id] = data
+	if err = fs.write(owner, mapTimer); err != nil {
+		//写入失败时恢复，与文件中保持一致
+		if ok {
+			mapTimer[data.Id] = preData
+		} else {
+			delete(mapTimer, data.Id)
+		}
+	}
+
+	return err
+}
+
+func (fs *FileStore) Remove(owner string, id string) error {
+	fs.locker.Lock()
+	defer fs.locker.Unlock()
+
+	mapTimer, err := fs.load(owner)
+	if err != nil {
+		return err
+	}
+
+	preData, ok := mapTimer[id]
+	if ok == false {
+		return nil
+	}
+
+	delete(mapTimer, id)
+	if err = fs.write(owner, mapTimer); err != nil {
+		mapTimer[id] = preData
+	}
+
+	return err
+}
+
+func (fs *FileStore) getFileName(owner string) string {
+	return filepath.Join(fs.dir, owner+".json")
+}
+
+func (fs *FileStore) load(owner string) (map[string]*service.DurableTimerData, error) {
+	if mapTimer, ok := fs.mapSvc[owner]; ok {
+		return mapTimer, nil
+	}
+
+	mapTimer := map[string]*service.DurableTimerData{}
+	content, err := os.ReadFile(fs.getFileName(owner))
+	if err != nil && os.IsNotExist(err) == false {
+		return nil, err
+	}
+
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &mapTimer); err != nil {
+			return nil, err
+		}
+	}
+
+	fs.mapSvc[owner] = mapTimer
+	return mapTimer, nil
+}
+
+// write 先写入临时文件再替换，避免写入过程中宕机损坏文件
+func (fs *FileStore) write(owner string, mapTimer map[string]*service.DurableTimerData) error {
+	content, err := json.Marshal(mapTimer)
+	if err != nil {
+		return err
+	}
+
+	fileName := fs.getFileName(owner)
+	tmpFileName := fileName + ".tmp"
+	f, err := os.OpenFile(tmpFileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(content); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFileName, fileName)
+}
@@ -0,0 +1,83 @@
+package timerstore
+
+import (
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/sysmodule/mongodbmodule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoTimerData struct {
+	Key      string `bson:"_id"` //owner:id
+	Owner    string `bson:"Owner"`
+	Id       string `bson:"Id"`
+	FireTime int64  `bson:"FireTime"`
+	FunName  string `bson:"FunName"`
+	Payload  []byte `bson:"Payload"`
+	CronExpr string `bson:"CronExpr"`
+}
+
+// MongoStore 所有结点与服务的定时器存放在同一个集合中，按Owner查询
+type MongoStore struct {
+	mongoModule *mongodbmodule.MongoModule
+	dbName      string
+	collectName string
+}
+
+// NewMongoStore mongoModule需要已经Start，创建时为Owner建立索引
+func NewMongoStore(mongoModule *mongodbmodule.MongoModule, dbName string, collectName string) (*MongoStore, error) {
+	ms := &MongoStore{mongoModule: mongoModule, dbName: dbName, collectName: collectName}
+	s := mongoModule.TakeSession()
+	if err := s.EnsureIndex(dbName, collectName, [][]string{{"Owner"}}, true, false, true); err != nil {
+		return nil, err
+	}
+
+	return ms, nil
+}
+
+func getMongoTimerKey(owner string, id string) string {
+	return owner + ":" + id
+}
+
+func (ms *MongoStore) Load(owner string) ([]*service.DurableTimerData, error) {
+	s := ms.mongoModule.TakeSession()
+	ctx, cancel := s.GetDefaultContext()
+	defer cancel()
+
+	cursor, err := s.Collection(ms.dbName, ms.collectName).Find(ctx, bson.M{"Owner": owner})
+	if err != nil {
+		return nil, err
+	}
+
+	var mongoDataList []mongoTimerData
+	if err = cursor.All(ctx, &mongoDataList); err != nil {
+		return nil, err
+	}
+
+	dataList := make([]*service.DurableTimerData, 0, len(mongoDataList))
+	for _, data := range mongoDataList {
+		dataList = append(dataList, &service.DurableTimerData{Id: data.Id, FireTime: data.FireTime, FunName: data.FunName, Payload: data.Payload, CronExpr: data.CronExpr})
+	}
+
+	return dataList, nil
+}
+
+func (ms *MongoStore) Save(owner string, data *service.DurableTimerData) error {
+	s := ms.mongoModule.TakeSession()
+	ctx, cancel := s.GetDefaultContext()
+	defer cancel()
+
+	key := getMongoTimerKey(owner, data.Id)
+	mongoData := &mongoTimerData{Key: key, Owner: owner, Id: data.Id, FireTime: data.FireTime, FunName: data.FunName, Payload: data.Payload, CronExpr: data.CronExpr}
+	_, err := s.Collection(ms.dbName, ms.collectName).ReplaceOne(ctx, bson.M{"_id": key}, mongoData, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (ms *MongoStore) Remove(owner string, id string) error {
+	s := ms.mongoModule.TakeSession()
+	ctx, cancel := s.GetDefaultContext()
+	defer cancel()
+
+	_, err := s.Collection(ms.dbName, ms.collectName).DeleteOne(ctx, bson.M{"_id": getMongoTimerKey(owner, id)})
+	return err
+}
@@ -0,0 +1,70 @@
+package timerstore
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/sysmodule/mysqlmodule"
+)
+
+type mysqlTimerData struct {
+	Id       string `json:"timer_id"`
+	FireTime int64  `json:"fire_time"`
+	FunName  string `json:"fun_name"`
+	Payload  string `json:"payload"`
+	CronExpr string `json:"cron_expr"`
+}
+
+// MySQLStore 所有结点与服务的定时器存放在同一张表中，主键为(owner,timer_id)
+type MySQLStore struct {
+	mysqlModule *mysqlmodule.MySQLModule
+	tableName   string
+}
+
+// NewMySQLStore mysqlModule需要已经Init，表不存在时自动创建
+func NewMySQLStore(mysqlModule *mysqlmodule.MySQLModule, tableName string) (*MySQLStore, error) {
+	ms := &MySQLStore{mysqlModule: mysqlModule, tableName: tableName}
+	_, err := mysqlModule.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ("+
+		"`owner` VARCHAR(255) NOT NULL,"+
+		"`timer_id` VARCHAR(255) NOT NULL,"+
+		"`fire_time` BIGINT NOT NULL,"+
+		"`fun_name` VARCHAR(128) NOT NULL,"+
+		"`payload` BLOB,"+
+		"`cron_expr` VARCHAR(128) NOT NULL DEFAULT '',"+
+		"PRIMARY KEY (`owner`,`timer_id`))", tableName))
+	if err != nil {
+		return nil, err
+	}
+
+	return ms, nil
+}
+
+func (ms *MySQLStore) Load(owner string) ([]*service.DurableTimerData, error) {
+	dataSet, err := ms.mysqlModule.Query(fmt.Sprintf("SELECT `timer_id`,`fire_time`,`fun_name`,`payload`,`cron_expr` FROM `%s` WHERE `owner`=?", ms.tableName), owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var mysqlDataList []mysqlTimerData
+	if err = dataSet.UnMarshal(&mysqlDataList); err != nil {
+		return nil, err
+	}
+
+	dataList := make([]*service.DurableTimerData, 0, len(mysqlDataList))
+	for _, data := range mysqlDataList {
+		dataList = append(dataList, &service.DurableTimerData{Id: data.Id, FireTime: data.FireTime, FunName: data.FunName, Payload: []byte(data.Payload), CronExpr: data.CronExpr})
+	}
+
+	return dataList, nil
+}
+
+func (ms *MySQLStore) Save(owner string, data *service.DurableTimerData) error {
+	_, err := ms.mysqlModule.Exec(fmt.Sprintf("INSERT INTO `%s` (`owner`,`timer_id`,`fire_time`,`fun_name`,`payload`,`cron_expr`) VALUES (?,?,?,?,?,?) "+
+		"ON DUPLICATE KEY UPDATE `fire_time`=VALUES(`fire_time`),`fun_name`=VALUES(`fun_name`),`payload`=VALUES(`payload`),`cron_expr`=VALUES(`cron_expr`)", ms.tableName),
+		owner, data.Id, data.FireTime, data.FunName, data.Payload, data.CronExpr)
+	return err
+}
+
+func (ms *MySQLStore) Remove(owner string, id string) error {
+	_, err := ms.mysqlModule.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE `owner`=? AND `timer_id`=?", ms.tableName), owner, id)
+	return err
+}
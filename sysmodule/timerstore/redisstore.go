@@ -0,0 +1,48 @@
+package timerstore
+
+import (
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/sysmodule/redismodule"
+)
+
+const redisTimerPrefix = "origin:durabletimer:"
+
+// RedisStore 每个结点的每个服务一个hash，field为定时器id，value为json
+type RedisStore struct {
+	redisModule *redismodule.RedisModule
+}
+
+func NewRedisStore(redisModule *redismodule.RedisModule) *RedisStore {
+	return &RedisStore{redisModule: redisModule}
+}
+
+func (rs *RedisStore) Load(owner string) ([]*service.DurableTimerData, error) {
+	mapValue, err := rs.redisModule.GetAllHashJSON(redisTimerPrefix + owner)
+	if err != nil {
+		return nil, err
+	}
+
+	dataList := make([]*service.DurableTimerData, 0, len(mapValue))
+	for _, value := range mapValue {
+		data := &service.DurableTimerData{}
+		if err = json.Unmarshal([]byte(value), data); err != nil {
+			return nil, err
+		}
+		dataList = append(dataList, data)
+	}
+
+	return dataList, nil
+}
+
+func (rs *RedisStore) Save(owner string, data *service.DurableTimerData) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return rs.redisModule.SetHash(redisTimerPrefix+owner, data.Id, value)
+}
+
+func (rs *RedisStore) Remove(owner string, id string) error {
+	return rs.redisModule.DelHash(redisTimerPrefix+owner, id)
+}
@@ -0,0 +1,168 @@
+package entityservice
+
+import (
+	"fmt"
+	"github.com/duanhf2012/origin/v2/rpc"
+	jsoniter "github.com/json-iterator/go"
+	"sync"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const maxEntityCallRetry = 3
+const maxLocationNum = 100000
+
+// 本结点缓存的实体位置，实体迁移后由实体服务返回Moved时更新
+var locationLocker sync.RWMutex
+var mapLocation = map[string]string{} //map[entityType/entityId]nodeId
+
+func locationKey(entityType string, entityId string) string {
+	return entityType + "/" + entityId
+}
+
+func getLocation(entityType string, entityId string) string {
+	locationLocker.RLock()
+	defer locationLocker.RUnlock()
+
+	return mapLocation[locationKey(entityType, entityId)]
+}
+
+func setLocation(entityType string, entityId string, nodeId string) {
+	locationLocker.Lock()
+	defer locationLocker.Unlock()
+
+	if nodeId == "" {
+		delete(mapLocation, locationKey(entityType, entityId))
+		return
+	}
+
+	if len(mapLocation) >= maxLocationNum {
+		mapLocation = map[string]string{}
+	}
+	mapLocation[locationKey(entityType, entityId)] = nodeId
+}
+
+func locateEntity(rpcHandler rpc.IRpcHandler, entityType string, entityId string) (string, error) {
+	if nodeId := getLocation(entityType, entityId); nodeId != "" {
+		return nodeId, nil
+	}
+
+	var resp LocateResp
+	err := rpcHandler.Call(EntityDirectoryName+".RPC_Locate", &LocateReq{EntityType: entityType, EntityId: entityId}, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	setLocation(entityType, entityId, resp.NodeId)
+	return resp.NodeId, nil
+}
+
+func newEntityCallReq(entityId string, method string, req interface{}) (*EntityCallReq, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EntityCallReq{EntityId: entityId, Method: method, Data: data}, nil
+}
+
+// CallEntity 同步调用实体的方法，entityType为承载实体的服务名，实体未激活时会在承载服务的某个结点上激活
+func CallEntity(rpcHandler rpc.IRpcHandler, entityType string, entityId string, method string, req interface{}, resp interface{}) error {
+	callReq, err := newEntityCallReq(entityId, method, req)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < maxEntityCallRetry; i++ {
+		nodeId, err := locateEntity(rpcHandler, entityType, entityId)
+		if err != nil {
+			return err
+		}
+
+		var callResp EntityCallResp
+		err = rpcHandler.CallNode(nodeId, entityType+".RPC_EntityCall", callReq, &callResp)
+		if err != nil {
+			setLocation(entityType, entityId, "")
+			return err
+		}
+
+		if callResp.Moved == true {
+			setLocation(entityType, entityId, callResp.NodeId)
+			continue
+		}
+
+		if resp == nil || len(callResp.Data) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(callResp.Data, resp)
+	}
+
+	return fmt.Errorf("entity %s/%s is moving", entityType, entityId)
+}
+
+// AsyncCallEntity 异步调用实体的方法，callback在调用者的服务协程中回调
+func AsyncCallEntity[T any](rpcHandler rpc.IRpcHandler, entityType string, entityId string, method string, req interface{}, callback func(resp *T, err error)) error {
+	callReq, err := newEntityCallReq(entityId, method, req)
+	if err != nil {
+		return err
+	}
+
+	return asyncCallEntity(rpcHandler, entityType, callReq, 0, func(data []byte, err error) {
+		if err != nil {
+			callback(nil, err)
+			return
+		}
+
+		var resp T
+		if len(data) > 0 {
+			if err = json.Unmarshal(data, &resp); err != nil {
+				callback(nil, err)
+				return
+			}
+		}
+
+		callback(&resp, nil)
+	})
+}
+
+func asyncCallEntity(rpcHandler rpc.IRpcHandler, entityType string, callReq *EntityCallReq, retry int, callback func(data []byte, err error)) error {
+	if retry >= maxEntityCallRetry {
+		return fmt.Errorf("entity %s/%s is moving", entityType, callReq.EntityId)
+	}
+
+	callEntity := func(nodeId string) error {
+		return rpcHandler.AsyncCallNode(nodeId, entityType+".RPC_EntityCall", callReq, func(callResp *EntityCallResp, err error) {
+			if err != nil {
+				setLocation(entityType, callReq.EntityId, "")
+				callback(nil, err)
+				return
+			}
+
+			if callResp.Moved == false {
+				callback(callResp.Data, nil)
+				return
+			}
+
+			setLocation(entityType, callReq.EntityId, callResp.NodeId)
+			if err = asyncCallEntity(rpcHandler, entityType, callReq, retry+1, callback); err != nil {
+				callback(nil, err)
+			}
+		})
+	}
+
+	if nodeId := getLocation(entityType, callReq.EntityId); nodeId != "" {
+		return callEntity(nodeId)
+	}
+
+	return rpcHandler.AsyncCall(EntityDirectoryName+".RPC_Locate", &LocateReq{EntityType: entityType, EntityId: callReq.EntityId}, func(resp *LocateResp, err error) {
+		if err == nil {
+			setLocation(entityType, callReq.EntityId, resp.NodeId)
+			err = callEntity(resp.NodeId)
+		}
+
+		if err != nil {
+			callback(nil, err)
+		}
+	})
+}
@@ -0,0 +1,264 @@
+package entityservice
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"time"
+)
+
+const EntityDirectoryName = "EntityDirectory"
+
+const entityHeartbeatInterval = 3 * time.Second
+const hostExpireTime = 3 * entityHeartbeatInterval
+
+// directoryRecoverTime 目录启动后等待实体服务上报已激活的实体，期间不分配新的实体，避免重复激活
+const directoryRecoverTime = 2 * entityHeartbeatInterval
+
+var ErrDirectoryRecovering = errors.New("entity directory is recovering")
+
+type hostInfo struct {
+	lastHeartbeatTime time.Time
+	retire            bool
+	entityNum         int
+}
+
+type entityTypeInfo struct {
+	mapEntity map[string]string    //map[entityId]nodeId
+	mapHost   map[string]*hostInfo //map[nodeId]hostInfo
+}
+
+// EntityDirectory 实体位置目录，记录每个实体当前所在的结点。部署在多个结点时需要配置为单例服务
+// 目录只保存在内存中，重启或切换Leader后由各实体服务上报重建
+type EntityDirectory struct {
+	service.Service
+
+	epoch      int64
+	activeTime time.Time
+	mapType    map[string]*entityTypeInfo //map[entityType]
+}
+
+type LocateReq struct {
+	EntityType string
+	EntityId   string
+}
+
+type LocateResp struct {
+	NodeId string
+}
+
+type ActivateReq struct {
+	EntityType string
+	EntityId   string
+	NodeId     string
+}
+
+type ActivateResp struct {
+	NodeId string //实体所在的结点，与请求的NodeId不同时表示实体已在其他结点激活
+}
+
+type DeactivateReq struct {
+	EntityType   string
+	NodeId       string
+	EntityIdList []string
+}
+
+type HeartbeatReq struct {
+	EntityType string
+	NodeId     string
+	Retire     bool
+}
+
+type HeartbeatResp struct {
+	Epoch int64 //目录的版本，变化时实体服务需要重新上报已激活的实体
+}
+
+type ReportReq struct {
+	EntityType   string
+	NodeId       string
+	Epoch        int64
+	EntityIdList []string
+}
+
+func (ed *EntityDirectory) OnInit() error {
+	ed.reset()
+	ed.NewTicker(entityHeartbeatInterval, ed.checkHost)
+
+	return nil
+}
+
+func (ed *EntityDirectory) OnBecomeLeader() {
+	ed.reset()
+}
+
+func (ed *EntityDirectory) OnLoseLeadership() {
+	ed.mapType = map[string]*entityTypeInfo{}
+}
+
+func (ed *EntityDirectory) reset() {
+	ed.activeTime = time.Now()
+	ed.epoch = ed.activeTime.UnixNano()
+	ed.mapType = map[string]*entityTypeInfo{}
+}
+
+func (ed *EntityDirectory) isRecovering() bool {
+	return time.Since(ed.activeTime) < directoryRecoverTime
+}
+
+func (ed *EntityDirectory) getTypeInfo(entityType string) *entityTypeInfo {
+	typeInfo, ok := ed.mapType[entityType]
+	if ok == false {
+		typeInfo = &entityTypeInfo{mapEntity: map[string]string{}, mapHost: map[string]*hostInfo{}}
+		ed.mapType[entityType] = typeInfo
+	}
+
+	return typeInfo
+}
+
+func (ti *entityTypeInfo) getHost(nodeId string) *hostInfo {
+	host, ok := ti.mapHost[nodeId]
+	if ok == false {
+		host = &hostInfo{lastHeartbeatTime: time.Now()}
+		ti.mapHost[nodeId] = host
+	}
+
+	return host
+}
+
+func (ti *entityTypeInfo) addEntity(entityId string, nodeId string) {
+	ti.mapEntity[entityId] = nodeId
+	ti.getHost(nodeId).entityNum++
+}
+
+func (ti *entityTypeInfo) removeEntity(entityId string, nodeId string) {
+	if ti.mapEntity[entityId] != nodeId {
+		return
+	}
+
+	delete(ti.mapEntity, entityId)
+	if host, ok := ti.mapHost[nodeId]; ok == true {
+		host.entityNum--
+	}
+}
+
+// selectHost 选择未退休且实体数量最少的结点
+func (ti *entityTypeInfo) selectHost() string {
+	var selectNodeId string
+	var minNum int
+	for nodeId, host := range ti.mapHost {
+		if host.retire == true {
+			continue
+		}
+
+		if selectNodeId == "" || host.entityNum < minNum || (host.entityNum == minNum && nodeId < selectNodeId) {
+			selectNodeId = nodeId
+			minNum = host.entityNum
+		}
+	}
+
+	return selectNodeId
+}
+
+// RPC_Locate 查询实体所在的结点，未激活时为其分配结点
+func (ed *EntityDirectory) RPC_Locate(req *LocateReq, resp *LocateResp) error {
+	typeInfo := ed.getTypeInfo(req.EntityType)
+	if nodeId, ok := typeInfo.mapEntity[req.EntityId]; ok == true {
+		resp.NodeId = nodeId
+		return nil
+	}
+
+	if ed.isRecovering() {
+		return ErrDirectoryRecovering
+	}
+
+	nodeId := typeInfo.selectHost()
+	if nodeId == "" {
+		return fmt.Errorf("no available node for entity type %s", req.EntityType)
+	}
+
+	typeInfo.addEntity(req.EntityId, nodeId)
+	resp.NodeId = nodeId
+
+	return nil
+}
+
+// RPC_Activate 实体服务激活实体前登记，实体已在其他结点时返回其所在结点
+func (ed *EntityDirectory) RPC_Activate(req *ActivateReq, resp *ActivateResp) error {
+	typeInfo := ed.getTypeInfo(req.EntityType)
+	if nodeId, ok := typeInfo.mapEntity[req.EntityId]; ok == true {
+		resp.NodeId = nodeId
+		return nil
+	}
+
+	if ed.isRecovering() {
+		return ErrDirectoryRecovering
+	}
+
+	typeInfo.addEntity(req.EntityId, req.NodeId)
+	resp.NodeId = req.NodeId
+
+	return nil
+}
+
+// RPC_Deactivate 实体钝化或迁移后注销
+func (ed *EntityDirectory) RPC_Deactivate(req *DeactivateReq) error {
+	typeInfo := ed.getTypeInfo(req.EntityType)
+	for _, entityId := range req.EntityIdList {
+		typeInfo.removeEntity(entityId, req.NodeId)
+	}
+
+	return nil
+}
+
+// RPC_Heartbeat 实体服务定时上报，超时未上报的结点上的实体会被清除
+func (ed *EntityDirectory) RPC_Heartbeat(req *HeartbeatReq, resp *HeartbeatResp) error {
+	host := ed.getTypeInfo(req.EntityType).getHost(req.NodeId)
+	host.lastHeartbeatTime = time.Now()
+	host.retire = req.Retire
+	resp.Epoch = ed.epoch
+
+	return nil
+}
+
+// RPC_Report 目录版本变化后，实体服务上报已激活的全部实体
+func (ed *EntityDirectory) RPC_Report(req *ReportReq) error {
+	if req.Epoch != ed.epoch {
+		return nil
+	}
+
+	typeInfo := ed.getTypeInfo(req.EntityType)
+	for _, entityId := range req.EntityIdList {
+		nodeId, ok := typeInfo.mapEntity[entityId]
+		if ok == false {
+			typeInfo.addEntity(entityId, req.NodeId)
+			continue
+		}
+
+		if nodeId != req.NodeId {
+			log.Warnf("entity is activated on multiple nodes,entityType:%s,entityId:%s,nodeId:%s,reportNodeId:%s", req.EntityType, entityId, nodeId, req.NodeId)
+		}
+	}
+
+	return nil
+}
+
+func (ed *EntityDirectory) checkHost(_ *timer.Ticker) {
+	now := time.Now()
+	for entityType, typeInfo := range ed.mapType {
+		for nodeId, host := range typeInfo.mapHost {
+			if now.Sub(host.lastHeartbeatTime) < hostExpireTime {
+				continue
+			}
+
+			log.Warnf("entity host is expired,entityType:%s,nodeId:%s,entityNum:%d", entityType, nodeId, host.entityNum)
+			delete(typeInfo.mapHost, nodeId)
+			for entityId, entityNodeId := range typeInfo.mapEntity {
+				if entityNodeId == nodeId {
+					delete(typeInfo.mapEntity, entityId)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package entityservice
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/cluster"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const DefaultIdleTimeout = 10 * time.Minute
+
+// IEntity 实体接口，实体中以RPC_开头的方法可以被调用，格式为func(req *T) error或func(req *T, resp *U) error
+type IEntity interface {
+	OnActivate(entityId string) error //激活时加载状态，返回错误时激活失败
+	OnDeactivate()                    //钝化或迁移前保存状态
+}
+
+type entityInfo struct {
+	entity         IEntity
+	lastActiveTime time.Time
+}
+
+// entityCall 等待实体激活的调用
+type entityCall struct {
+	req       *EntityCallReq
+	resp      *EntityCallResp
+	responder rpc.Responder
+}
+
+type entityMethod struct {
+	method   reflect.Method
+	reqType  reflect.Type
+	respType reflect.Type //没有返回参数时为nil
+}
+
+// EntityService 实体服务，同类型的实体由同名服务承载，服务名即实体类型。实体在服务协程中执行
+// 重写OnRetire或OnRelease时需要调用EntityService的对应方法
+type EntityService struct {
+	service.Service
+
+	newEntity      func(entityId string) IEntity
+	idleTimeout    time.Duration
+	mapEntity      map[string]*entityInfo
+	mapActivating  map[string][]*entityCall //map[entityId]正在激活的实体上排队的调用
+	mapMethod      map[reflect.Type]map[string]*entityMethod
+	localNodeId    string
+	directoryEpoch int64
+}
+
+type EntityCallReq struct {
+	EntityId string
+	Method   string
+	Data     []byte
+}
+
+type EntityCallResp struct {
+	Moved  bool   //实体不在本结点，需要重新定位
+	NodeId string //实体所在的结点，为空时需要向目录查询
+	Data   []byte
+}
+
+// InitEntityService 在OnInit中调用，newEntity用于创建实体，idleTimeout为0时使用DefaultIdleTimeout
+func (es *EntityService) InitEntityService(newEntity func(entityId string) IEntity, idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	es.newEntity = newEntity
+	es.idleTimeout = idleTimeout
+	es.mapEntity = map[string]*entityInfo{}
+	es.mapActivating = map[string][]*entityCall{}
+	es.mapMethod = map[reflect.Type]map[string]*entityMethod{}
+	es.localNodeId = cluster.GetCluster().GetLocalNodeInfo().NodeId
+
+	es.NewTicker(entityHeartbeatInterval, func(_ *timer.Ticker) {
+		es.heartbeat()
+	})
+	es.NewTicker(idleTimeout/2, func(_ *timer.Ticker) {
+		es.checkIdle()
+	})
+}
+
+// GetEntity 获取本结点已激活的实体
+func (es *EntityService) GetEntity(entityId string) IEntity {
+	info, ok := es.mapEntity[entityId]
+	if ok == false {
+		return nil
+	}
+
+	return info.entity
+}
+
+// GetEntityNum 获取本结点已激活的实体数量
+func (es *EntityService) GetEntityNum() int {
+	return len(es.mapEntity)
+}
+
+// OnRetire 结点退休时钝化所有实体，之后的调用会在其他结点重新激活
+func (es *EntityService) OnRetire() {
+	es.deactivateAll()
+	es.heartbeat()
+}
+
+func (es *EntityService) OnRelease() {
+	es.deactivateAll()
+}
+
+// RPC_EntityCall 实体未激活时异步向目录登记，激活期间到达的调用排队等待，激活完成后按顺序执行
+func (es *EntityService) RPC_EntityCall(responder rpc.Responder, req *EntityCallReq, resp *EntityCallResp) {
+	call := &entityCall{req: req, resp: resp, responder: responder}
+	if info, ok := es.mapEntity[req.EntityId]; ok == true {
+		es.doCall(info, call)
+		return
+	}
+
+	es.activate(call)
+}
+
+func (call *entityCall) done(err error) {
+	if call.responder.IsInvalid() == false {
+		call.responder(call.resp, rpc.ConvertError(err))
+	}
+}
+
+func (call *entityCall) moved(nodeId string) {
+	call.resp.Moved = true
+	call.resp.NodeId = nodeId
+	call.done(nil)
+}
+
+func (es *EntityService) doCall(info *entityInfo, call *entityCall) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("call entity panic,entityType:%s,entityId:%s,method:%s,err:%v", es.GetName(), call.req.EntityId, call.req.Method, r)
+			err = fmt.Errorf("call entity method %s panic", call.req.Method)
+		}
+		call.done(err)
+	}()
+
+	info.lastActiveTime = time.Now()
+	err = es.callEntity(info.entity, call.req, call.resp)
+}
+
+func (es *EntityService) callEntity(entity IEntity, req *EntityCallReq, resp *EntityCallResp) error {
+	method, err := es.getMethod(entity, req.Method)
+	if err != nil {
+		return err
+	}
+
+	reqValue := reflect.New(method.reqType)
+	if len(req.Data) > 0 {
+		if err = json.Unmarshal(req.Data, reqValue.Interface()); err != nil {
+			return err
+		}
+	}
+
+	in := []reflect.Value{reflect.ValueOf(entity), reqValue}
+	var respValue reflect.Value
+	if method.respType != nil {
+		respValue = reflect.New(method.respType)
+		in = append(in, respValue)
+	}
+
+	if errValue := method.method.Func.Call(in)[0].Interface(); errValue != nil {
+		return errValue.(error)
+	}
+
+	if method.respType != nil {
+		resp.Data, err = json.Marshal(respValue.Interface())
+	}
+
+	return err
+}
+
+// activate 实体未激活时先向目录登记，同一实体只发起一次登记，实体已在其他结点或本结点正在退休时返回Moved
+func (es *EntityService) activate(call *entityCall) {
+	if es.newEntity == nil {
+		call.done(errors.New("entity service is not initialized"))
+		return
+	}
+
+	entityId := call.req.EntityId
+	callList, activating := es.mapActivating[entityId]
+	es.mapActivating[entityId] = append(callList, call)
+	if activating == true {
+		return
+	}
+
+	//目录中可能仍有分配到本结点但未激活的记录，注销后重新分配
+	if es.IsRetire() {
+		es.unregister([]string{entityId})
+		es.onActivate(entityId, nil, nil)
+		return
+	}
+
+	err := es.AsyncCall(EntityDirectoryName+".RPC_Activate", &ActivateReq{EntityType: es.GetName(), EntityId: entityId, NodeId: es.localNodeId}, func(activateResp *ActivateResp, err error) {
+		es.onActivate(entityId, activateResp, err)
+	})
+	if err != nil {
+		es.onActivate(entityId, nil, err)
+	}
+}
+
+// onActivate 目录返回登记结果后激活实体，并执行排队的调用
+func (es *EntityService) onActivate(entityId string, activateResp *ActivateResp, err error) {
+	callList, ok := es.mapActivating[entityId]
+	if ok == false {
+		return
+	}
+	delete(es.mapActivating, entityId)
+
+	if err != nil {
+		for _, call := range callList {
+			call.done(err)
+		}
+		return
+	}
+
+	//等待目录返回期间本结点开始退休
+	if activateResp != nil && activateResp.NodeId == es.localNodeId && es.IsRetire() {
+		es.unregister([]string{entityId})
+		activateResp = nil
+	}
+
+	if activateResp == nil || activateResp.NodeId != es.localNodeId {
+		var nodeId string
+		if activateResp != nil {
+			nodeId = activateResp.NodeId
+		}
+		for _, call := range callList {
+			call.moved(nodeId)
+		}
+		return
+	}
+
+	entity := es.newEntity(entityId)
+	if err = entity.OnActivate(entityId); err != nil {
+		log.Errorf("activate entity fail,entityType:%s,entityId:%s,err:%s", es.GetName(), entityId, err.Error())
+		es.unregister([]string{entityId})
+		for _, call := range callList {
+			call.done(err)
+		}
+		return
+	}
+
+	info := &entityInfo{entity: entity, lastActiveTime: time.Now()}
+	es.mapEntity[entityId] = info
+	for _, call := range callList {
+		es.doCall(info, call)
+	}
+}
+
+func (es *EntityService) deactivate(entityId string) {
+	info, ok := es.mapEntity[entityId]
+	if ok == false {
+		return
+	}
+
+	delete(es.mapEntity, entityId)
+	es.safeDeactivate(entityId, info.entity)
+}
+
+func (es *EntityService) safeDeactivate(entityId string, entity IEntity) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("deactivate entity panic,entityType:%s,entityId:%s,err:%v", es.GetName(), entityId, r)
+		}
+	}()
+
+	entity.OnDeactivate()
+}
+
+func (es *EntityService) deactivateAll() {
+	if len(es.mapEntity) == 0 {
+		return
+	}
+
+	entityIdList := make([]string, 0, len(es.mapEntity))
+	for entityId := range es.mapEntity {
+		es.deactivate(entityId)
+		entityIdList = append(entityIdList, entityId)
+	}
+
+	es.unregister(entityIdList)
+}
+
+// checkIdle 钝化空闲超时的实体
+func (es *EntityService) checkIdle() {
+	now := time.Now()
+	var entityIdList []string
+	for entityId, info := range es.mapEntity {
+		if now.Sub(info.lastActiveTime) < es.idleTimeout {
+			continue
+		}
+
+		es.deactivate(entityId)
+		entityIdList = append(entityIdList, entityId)
+	}
+
+	if len(entityIdList) > 0 {
+		es.unregister(entityIdList)
+	}
+}
+
+// unregister 先保存状态再注销，保证其他结点激活时能加载到最新状态
+func (es *EntityService) unregister(entityIdList []string) {
+	err := es.Go(EntityDirectoryName+".RPC_Deactivate", &DeactivateReq{EntityType: es.GetName(), NodeId: es.localNodeId, EntityIdList: entityIdList})
+	if err != nil {
+		log.Errorf("unregister entity fail,entityType:%s,entityNum:%d,err:%s", es.GetName(), len(entityIdList), err.Error())
+	}
+}
+
+func (es *EntityService) heartbeat() {
+	req := HeartbeatReq{EntityType: es.GetName(), NodeId: es.localNodeId, Retire: es.IsRetire()}
+	err := es.AsyncCall(EntityDirectoryName+".RPC_Heartbeat", &req, func(resp *HeartbeatResp, err error) {
+		if err != nil || resp.Epoch == es.directoryEpoch {
+			return
+		}
+
+		es.directoryEpoch = resp.Epoch
+		es.report()
+	})
+
+	if err != nil {
+		log.Debugf("entity heartbeat fail,entityType:%s,err:%s", es.GetName(), err.Error())
+	}
+}
+
+// report 目录重建后上报本结点已激活的实体
+func (es *EntityService) report() {
+	entityIdList := make([]string, 0, len(es.mapEntity))
+	for entityId := range es.mapEntity {
+		entityIdList = append(entityIdList, entityId)
+	}
+
+	err := es.Go(EntityDirectoryName+".RPC_Report", &ReportReq{EntityType: es.GetName(), NodeId: es.localNodeId, Epoch: es.directoryEpoch, EntityIdList: entityIdList})
+	if err != nil {
+		log.Errorf("report entity fail,entityType:%s,err:%s", es.GetName(), err.Error())
+	}
+}
+
+func (es *EntityService) getMethod(entity IEntity, methodName string) (*entityMethod, error) {
+	typ := reflect.TypeOf(entity)
+	mapMethod, ok := es.mapMethod[typ]
+	if ok == false {
+		mapMethod = parseEntityMethod(typ)
+		es.mapMethod[typ] = mapMethod
+	}
+
+	method, ok := mapMethod[methodName]
+	if ok == false {
+		return nil, fmt.Errorf("entity method %s.%s is not found", es.GetName(), methodName)
+	}
+
+	return method, nil
+}
+
+func parseEntityMethod(typ reflect.Type) map[string]*entityMethod {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	mapMethod := map[string]*entityMethod{}
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		if strings.HasPrefix(method.Name, "RPC_") == false {
+			continue
+		}
+
+		mType := method.Type
+		if mType.NumIn() < 2 || mType.NumIn() > 3 || mType.NumOut() != 1 || mType.Out(0) != errorType {
+			log.Warnf("entity method %s has unsupported format", method.Name)
+			continue
+		}
+
+		em := &entityMethod{method: method}
+		for j := 1; j < mType.NumIn(); j++ {
+			if mType.In(j).Kind() != reflect.Pointer {
+				em = nil
+				break
+			}
+		}
+
+		if em == nil {
+			log.Warnf("entity method %s parameters must be pointers", method.Name)
+			continue
+		}
+
+		em.reqType = mType.In(1).Elem()
+		if mType.NumIn() == 3 {
+			em.respType = mType.In(2).Elem()
+		}
+
+		mapMethod[method.Name] = em
+	}
+
+	return mapMethod
+}
@@ -0,0 +1,370 @@
+package jobservice
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duanhf2012/origin/v2/cluster"
+	"github.com/duanhf2012/origin/v2/log"
+	"github.com/duanhf2012/origin/v2/service"
+	"github.com/duanhf2012/origin/v2/sysmodule/lockmodule"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"github.com/duanhf2012/origin/v2/util/uuid"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultClaimTTL = 5 * time.Minute
+const DefaultHistoryNum = 100
+const maxMissedRecordNum = 100
+const jobLockPrefix = "job/"
+
+type JobStatus int8
+
+const (
+	JobSuccess JobStatus = 0 //执行成功
+	JobFail    JobStatus = 1 //调用ServiceMethod失败
+	JobMissed  JobStatus = 2 //所有结点都没有执行，如集群停机期间
+)
+
+// Job 集群定时任务，到期时由集群中的一个结点调用ServiceMethod，格式为func(req *JobReq) error或func(req *JobReq, res *JobRes) error
+type Job struct {
+	Name          string
+	Cron          string //cron表达式，格式与timer.NewCronExpr一致
+	ServiceMethod string //如"GameService.RPC_DailyReset"
+
+	cronExpr *timer.CronExpr
+	cron     *timer.Cron
+}
+
+type JobReq struct {
+	JobName  string
+	FireTime int64 //计划的触发时间(UnixNano)，手动触发时为触发的时间
+	Manual   bool  //是否手动触发
+}
+
+type JobRes struct {
+}
+
+// JobHistory 任务执行记录
+type JobHistory struct {
+	JobName   string
+	FireTime  int64 //计划的触发时间(UnixNano)
+	NodeId    string
+	Status    JobStatus
+	Manual    bool
+	StartTime int64
+	EndTime   int64
+	Err       string
+}
+
+// IJobStore 集群共享的任务状态，用于记录最后一次触发时间与执行记录，在并发协程中调用
+type IJobStore interface {
+	GetLastFireTime(jobName string) (int64, error) //没有记录时返回0
+	SetLastFireTime(jobName string, fireTime int64) error
+	AddHistory(history *JobHistory) error
+	GetHistory(jobName string, limit int) ([]*JobHistory, error) //按时间从新到旧
+}
+
+// JobService 集群定时任务服务，每个结点都运行同样的任务，每次触发通过锁保证只有一个结点执行。
+// 锁以任务名与计划的触发时间为key，持有claimTTL后自动过期，各结点的时间误差需要小于claimTTL
+type JobService struct {
+	service.Service
+
+	backend      lockmodule.ILockBackend
+	store        IJobStore
+	detectMissed bool //只有共享的存储才检测错过的触发
+	claimTTL     time.Duration
+	owner        string
+	mapJob       map[string]*Job
+}
+
+type JobInfo struct {
+	Name          string
+	Cron          string
+	ServiceMethod string
+	NextFireTime  int64
+}
+
+// InitJobService 在OnInit中调用，backend用于争抢每次触发，claimTTL为0时使用DefaultClaimTTL。
+// 多个结点运行JobService时store需要使用集群共享的存储，如RedisJobStore。store为nil时使用本结点内存记录，
+// 内存记录只包含本结点执行的触发，无法判断其他结点是否执行过，所以不检测错过的触发。
+// 服务配置中有JobList时注册其中的任务，如{"JobList":[{"Name":"DailyReset","Cron":"0 0 0 * * *","ServiceMethod":"GameService.RPC_DailyReset"}]}
+func (js *JobService) InitJobService(backend lockmodule.ILockBackend, store IJobStore, claimTTL time.Duration) error {
+	if backend == nil {
+		return errors.New("job lock backend is nil")
+	}
+
+	if store == nil {
+		store = NewMemoryJobStore(DefaultHistoryNum)
+	}
+
+	if claimTTL <= 0 {
+		claimTTL = DefaultClaimTTL
+	}
+
+	_, isMemoryStore := store.(*MemoryJobStore)
+	js.backend = backend
+	js.store = store
+	js.detectMissed = isMemoryStore == false
+	js.claimTTL = claimTTL
+	js.owner = fmt.Sprintf("%s_%s", cluster.GetCluster().GetLocalNodeInfo().NodeId, uuid.Rand().HexEx())
+	js.mapJob = map[string]*Job{}
+	js.OpenConcurrent(1, 4, 1000)
+
+	return js.regCfgJob()
+}
+
+func (js *JobService) regCfgJob() error {
+	mapCfg, ok := js.GetServiceCfg().(map[string]interface{})
+	if ok == false {
+		return nil
+	}
+
+	jobList, ok := mapCfg["JobList"].([]interface{})
+	if ok == false {
+		return nil
+	}
+
+	for _, jobCfg := range jobList {
+		mapJobCfg, ok := jobCfg.(map[string]interface{})
+		if ok == false {
+			return errors.New("JobList config is error")
+		}
+
+		job := &Job{}
+		job.Name, _ = mapJobCfg["Name"].(string)
+		job.Cron, _ = mapJobCfg["Cron"].(string)
+		job.ServiceMethod, _ = mapJobCfg["ServiceMethod"].(string)
+		if err := js.RegJob(job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegJob 注册任务，集群中所有运行JobService的结点需要注册同样的任务
+func (js *JobService) RegJob(job *Job) error {
+	if job.Name == "" || job.ServiceMethod == "" {
+		return errors.New("job name or service method is empty")
+	}
+
+	if _, ok := js.mapJob[job.Name]; ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+
+	cronExpr, err := timer.NewCronExpr(job.Cron)
+	if err != nil {
+		return fmt.Errorf("job %s cron expr is error:%s", job.Name, err)
+	}
+
+	job.cronExpr = cronExpr
+	job.cron = js.CronFunc(cronExpr, func(cron *timer.Cron) {
+		js.onJobFire(job, cron.GetFireTime())
+	})
+	if job.cron == nil {
+		return fmt.Errorf("job %s cron expr has no next time", job.Name)
+	}
+
+	js.mapJob[job.Name] = job
+	return nil
+}
+
+// RemoveJob 移除任务
+func (js *JobService) RemoveJob(jobName string) {
+	job, ok := js.mapJob[jobName]
+	if ok == false {
+		return
+	}
+
+	job.cron.Cancel()
+	delete(js.mapJob, jobName)
+}
+
+// onJobFire 争抢本次触发，成功时记录错过的触发并执行
+func (js *JobService) onJobFire(job *Job, fireTime time.Time) {
+	var missedList []*JobHistory
+	var claimed bool
+	lockKey := jobLockPrefix + job.Name + "/" + strconv.FormatInt(fireTime.UnixNano(), 10)
+	js.AsyncDo(func() bool {
+		var err error
+		claimed, err = js.backend.TryLock(lockKey, js.owner, js.claimTTL)
+		if err != nil {
+			log.Errorf("claim job fail,jobName:%s,err:%s", job.Name, err)
+			return false
+		}
+
+		if claimed == false {
+			return false
+		}
+
+		if js.detectMissed == true {
+			lastFireTime, err := js.store.GetLastFireTime(job.Name)
+			if err != nil {
+				log.Errorf("get job last fire time fail,jobName:%s,err:%s", job.Name, err)
+			} else if lastFireTime != 0 {
+				missedList = js.getMissedList(job, time.Unix(0, lastFireTime), fireTime)
+			}
+		}
+
+		for _, missed := range missedList {
+			if err = js.store.AddHistory(missed); err != nil {
+				log.Errorf("add job history fail,jobName:%s,err:%s", job.Name, err)
+			}
+		}
+
+		if err = js.store.SetLastFireTime(job.Name, fireTime.UnixNano()); err != nil {
+			log.Errorf("set job last fire time fail,jobName:%s,err:%s", job.Name, err)
+		}
+		return true
+	}, func(err error) {
+		//争抢时发生panic或者任务队列已满时也会回调，只有抢到的结点才能执行
+		if err != nil {
+			log.Errorf("claim job fail,jobName:%s,err:%s", job.Name, err)
+			if claimed == true {
+				js.releaseClaim(lockKey)
+			}
+			return
+		}
+
+		if claimed == false {
+			return
+		}
+
+		for _, missed := range missedList {
+			log.Warnf("job is missed,jobName:%s,fireTime:%s", missed.JobName, time.Unix(0, missed.FireTime))
+		}
+
+		js.execJob(job, fireTime, false)
+		js.releaseClaim(lockKey)
+	})
+}
+
+// releaseClaim 锁过期后再释放，清理后端中记录的租约，过期前释放会使时间落后的结点再次执行
+func (js *JobService) releaseClaim(lockKey string) {
+	js.AfterFunc(js.claimTTL+time.Second, func(_ *timer.Timer) {
+		js.AsyncDo(func() bool {
+			if err := js.backend.Unlock(lockKey, js.owner); err != nil {
+				log.Warnf("release job claim fail,key:%s,err:%s", lockKey, err)
+			}
+			return false
+		}, nil)
+	})
+}
+
+// getMissedList 获取lastFireTime与fireTime之间没有执行的触发
+func (js *JobService) getMissedList(job *Job, lastFireTime time.Time, fireTime time.Time) []*JobHistory {
+	var missedList []*JobHistory
+	for next := job.cronExpr.Next(lastFireTime); next.IsZero() == false && next.Before(fireTime); next = job.cronExpr.Next(next) {
+		missedList = append(missedList, &JobHistory{JobName: job.Name, FireTime: next.UnixNano(), Status: JobMissed})
+		if len(missedList) >= maxMissedRecordNum {
+			break
+		}
+	}
+
+	return missedList
+}
+
+func (js *JobService) execJob(job *Job, fireTime time.Time, manual bool) {
+	history := &JobHistory{
+		JobName:   job.Name,
+		FireTime:  fireTime.UnixNano(),
+		NodeId:    cluster.GetCluster().GetLocalNodeInfo().NodeId,
+		Manual:    manual,
+		StartTime: timer.Now().UnixNano(),
+	}
+
+	req := &JobReq{JobName: job.Name, FireTime: fireTime.UnixNano(), Manual: manual}
+	err := js.AsyncCall(job.ServiceMethod, req, func(_ *JobRes, err error) {
+		js.addHistory(job, history, err)
+	})
+	if err != nil {
+		js.addHistory(job, history, err)
+	}
+}
+
+func (js *JobService) addHistory(job *Job, history *JobHistory, err error) {
+	history.EndTime = timer.Now().UnixNano()
+	if err != nil {
+		history.Status = JobFail
+		history.Err = err.Error()
+		log.Errorf("exec job fail,jobName:%s,serviceMethod:%s,err:%s", job.Name, job.ServiceMethod, err)
+	}
+
+	js.AsyncDo(func() bool {
+		if err := js.store.AddHistory(history); err != nil {
+			log.Errorf("add job history fail,jobName:%s,err:%s", history.JobName, err)
+		}
+		return false
+	}, nil)
+}
+
+// GetJobList 获取已注册的任务
+func (js *JobService) GetJobList() []JobInfo {
+	jobList := make([]JobInfo, 0, len(js.mapJob))
+	for _, job := range js.mapJob {
+		jobList = append(jobList, JobInfo{Name: job.Name, Cron: job.Cron, ServiceMethod: job.ServiceMethod, NextFireTime: job.cron.GetFireTime().UnixNano()})
+	}
+
+	slices.SortFunc(jobList, func(a, b JobInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return jobList
+}
+
+type JobListReq struct {
+}
+
+type JobListRes struct {
+	JobList []JobInfo
+}
+
+// RPC_GetJobList 获取已注册的任务以及下次触发时间
+func (js *JobService) RPC_GetJobList(_ *JobListReq, res *JobListRes) error {
+	res.JobList = js.GetJobList()
+	return nil
+}
+
+type JobHistoryReq struct {
+	JobName string
+	Limit   int //为0时使用DefaultHistoryNum
+}
+
+type JobHistoryRes struct {
+	HistoryList []*JobHistory
+}
+
+// RPC_GetJobHistory 获取任务的执行记录，包括错过的触发
+func (js *JobService) RPC_GetJobHistory(req *JobHistoryReq, res *JobHistoryRes) error {
+	if _, ok := js.mapJob[req.JobName]; ok == false {
+		return fmt.Errorf("job %s is not registered", req.JobName)
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryNum
+	}
+
+	var err error
+	res.HistoryList, err = js.store.GetHistory(req.JobName, limit)
+	return err
+}
+
+type TriggerJobReq struct {
+	JobName string
+}
+
+// RPC_TriggerJob 在本结点立即执行一次任务，不影响定时触发
+func (js *JobService) RPC_TriggerJob(req *TriggerJobReq) error {
+	job, ok := js.mapJob[req.JobName]
+	if ok == false {
+		return fmt.Errorf("job %s is not registered", req.JobName)
+	}
+
+	log.Infof("trigger job manually,jobName:%s", job.Name)
+	js.execJob(job, timer.Now(), true)
+	return nil
+}
@@ -0,0 +1,190 @@
+package jobservice
+
+import (
+	"errors"
+	"github.com/duanhf2012/origin/v2/rpc"
+	"github.com/duanhf2012/origin/v2/util/timer"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeLockBackend struct {
+	claim    bool
+	err      error
+	panicMsg string
+	block    chan struct{}
+
+	tryNum int32
+}
+
+func (b *fakeLockBackend) TryLock(key string, owner string, ttl time.Duration) (bool, error) {
+	atomic.AddInt32(&b.tryNum, 1)
+	if b.block != nil {
+		<-b.block
+	}
+
+	if b.panicMsg != "" {
+		panic(b.panicMsg)
+	}
+
+	return b.claim, b.err
+}
+
+func (b *fakeLockBackend) Renew(key string, owner string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (b *fakeLockBackend) Unlock(key string, owner string) error {
+	return nil
+}
+
+type testJobService struct {
+	JobService
+	execNum int32 //调用任务ServiceMethod的次数
+}
+
+var startTimerOnce sync.Once
+
+func (js *testJobService) getClient(nodeId string, serviceMethod string, filterRetire bool, client []*rpc.Client) (error, []*rpc.Client) {
+	if serviceMethod == "GameService.RPC_Daily" {
+		atomic.AddInt32(&js.execNum, 1)
+	}
+	return errors.New("no client"), nil
+}
+
+// sharedJobStore 模拟集群共享的存储
+type sharedJobStore struct {
+	*MemoryJobStore
+}
+
+func newTestJobService(t *testing.T, name string, backend *fakeLockBackend) *testJobService {
+	return newTestJobServiceWithStore(t, name, backend, NewMemoryJobStore(DefaultHistoryNum))
+}
+
+func newTestJobServiceWithStore(t *testing.T, name string, backend *fakeLockBackend, store IJobStore) *testJobService {
+	startTimerOnce.Do(func() {
+		timer.StartTimer(10*time.Millisecond, 1000)
+	})
+
+	js := &testJobService{}
+	js.SetName(name)
+	js.Init(js, js.getClient, nil, nil)
+	if err := js.InitJobService(backend, store, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.RegJob(&Job{Name: "Daily", Cron: "0 0 0 * * *", ServiceMethod: "GameService.RPC_Daily"}); err != nil {
+		t.Fatal(err)
+	}
+	js.Start()
+	t.Cleanup(js.Stop)
+
+	return js
+}
+
+func (js *testJobService) waitExecNum(num int32, timeout time.Duration) int32 {
+	deadline := time.Now().Add(timeout)
+	for {
+		execNum := atomic.LoadInt32(&js.execNum)
+		if execNum >= num || time.Now().After(deadline) {
+			return execNum
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobFireClaimed(t *testing.T) {
+	backend := &fakeLockBackend{claim: true}
+	js := newTestJobService(t, "JobClaimed", backend)
+	js.onJobFire(js.mapJob["Daily"], time.Now())
+
+	if num := js.waitExecNum(1, time.Second); num != 1 {
+		t.Fatalf("claimed job should be executed once,exec num:%d", num)
+	}
+
+	historyList, _ := js.store.GetHistory("Daily", DefaultHistoryNum)
+	if len(historyList) != 1 || historyList[0].Status != JobFail {
+		t.Fatalf("history is error:%+v", historyList)
+	}
+}
+
+func TestJobFireMissed(t *testing.T) {
+	testCases := []struct {
+		name      string
+		store     IJobStore
+		missedNum int
+	}{
+		//内存记录只包含本结点执行的触发，不检测错过的触发
+		{"MemoryStore", NewMemoryJobStore(DefaultHistoryNum), 0},
+		{"SharedStore", &sharedJobStore{NewMemoryJobStore(DefaultHistoryNum)}, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fireTime := time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local)
+			tc.store.SetLastFireTime("Daily", fireTime.AddDate(0, 0, -3).UnixNano())
+			js := newTestJobServiceWithStore(t, "JobMissed"+tc.name, &fakeLockBackend{claim: true}, tc.store)
+			js.onJobFire(js.mapJob["Daily"], fireTime)
+
+			if num := js.waitExecNum(1, time.Second); num != 1 {
+				t.Fatalf("claimed job should be executed once,exec num:%d", num)
+			}
+
+			historyList, _ := tc.store.GetHistory("Daily", DefaultHistoryNum)
+			missedNum := 0
+			for _, history := range historyList {
+				if history.Status == JobMissed {
+					missedNum++
+				}
+			}
+			if missedNum != tc.missedNum {
+				t.Fatalf("missed num is %d,expect %d,history:%+v", missedNum, tc.missedNum, historyList)
+			}
+		})
+	}
+}
+
+func TestJobFireNotClaimed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backend *fakeLockBackend
+	}{
+		{"ClaimLost", &fakeLockBackend{claim: false}},
+		{"BackendError", &fakeLockBackend{claim: true, err: errors.New("backend is down")}},
+		{"BackendPanic", &fakeLockBackend{claim: true, panicMsg: "backend panic"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			js := newTestJobService(t, "Job"+tc.name, tc.backend)
+			js.onJobFire(js.mapJob["Daily"], time.Now())
+
+			if num := js.waitExecNum(1, 200*time.Millisecond); num != 0 {
+				t.Fatalf("job should not be executed,exec num:%d", num)
+			}
+			if atomic.LoadInt32(&tc.backend.tryNum) != 1 {
+				t.Fatalf("try lock num is %d", tc.backend.tryNum)
+			}
+		})
+	}
+}
+
+func TestJobFireQueueFull(t *testing.T) {
+	backend := &fakeLockBackend{claim: false, block: make(chan struct{})}
+	js := newTestJobService(t, "JobQueueFull", backend)
+
+	//并发协程与任务队列都被阻塞的争抢占满后，之后的触发因队列已满直接回调错误
+	job := js.mapJob["Daily"]
+	for i := 0; i < 1100; i++ {
+		js.onJobFire(job, time.Now().Add(time.Duration(i)*time.Second))
+	}
+	close(backend.block)
+
+	if num := js.waitExecNum(1, 500*time.Millisecond); num != 0 {
+		t.Fatalf("job should not be executed when the queue is full,exec num:%d", num)
+	}
+	if tryNum := atomic.LoadInt32(&backend.tryNum); tryNum >= 1100 {
+		t.Fatalf("queue is not full,try lock num:%d", tryNum)
+	}
+}
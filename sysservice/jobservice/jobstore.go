@@ -0,0 +1,119 @@
+package jobservice
+
+import (
+	"github.com/duanhf2012/origin/v2/sysmodule/redismodule"
+	jsoniter "github.com/json-iterator/go"
+	"strconv"
+	"sync"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const redisJobLastFireKey = "origin:job:lastfire"
+const redisJobHistoryPrefix = "origin:job:history:"
+
+// MemoryJobStore 本结点内存中的记录，只适用于单结点或测试，多结点时各结点只记录自己执行的触发，不检测错过的触发
+type MemoryJobStore struct {
+	locker         sync.Mutex
+	historyNum     int
+	mapLastFire    map[string]int64
+	mapHistoryList map[string][]*JobHistory //按时间从新到旧
+}
+
+func NewMemoryJobStore(historyNum int) *MemoryJobStore {
+	return &MemoryJobStore{historyNum: historyNum, mapLastFire: map[string]int64{}, mapHistoryList: map[string][]*JobHistory{}}
+}
+
+func (ms *MemoryJobStore) GetLastFireTime(jobName string) (int64, error) {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	return ms.mapLastFire[jobName], nil
+}
+
+func (ms *MemoryJobStore) SetLastFireTime(jobName string, fireTime int64) error {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	ms.mapLastFire[jobName] = fireTime
+	return nil
+}
+
+func (ms *MemoryJobStore) AddHistory(history *JobHistory) error {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	historyList := append([]*JobHistory{history}, ms.mapHistoryList[history.JobName]...)
+	if len(historyList) > ms.historyNum {
+		historyList = historyList[:ms.historyNum]
+	}
+	ms.mapHistoryList[history.JobName] = historyList
+	return nil
+}
+
+func (ms *MemoryJobStore) GetHistory(jobName string, limit int) ([]*JobHistory, error) {
+	ms.locker.Lock()
+	defer ms.locker.Unlock()
+
+	historyList := ms.mapHistoryList[jobName]
+	if len(historyList) > limit {
+		historyList = historyList[:limit]
+	}
+
+	return append([]*JobHistory{}, historyList...), nil
+}
+
+// RedisJobStore 集群共享的记录，最后一次触发时间存放在hash中，执行记录存放在每个任务的list中
+type RedisJobStore struct {
+	redisModule *redismodule.RedisModule
+	historyNum  int
+}
+
+func NewRedisJobStore(redisModule *redismodule.RedisModule, historyNum int) *RedisJobStore {
+	return &RedisJobStore{redisModule: redisModule, historyNum: historyNum}
+}
+
+func (rs *RedisJobStore) GetLastFireTime(jobName string) (int64, error) {
+	mapLastFire, err := rs.redisModule.GetAllHashJSON(redisJobLastFireKey)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := mapLastFire[jobName]
+	if ok == false {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (rs *RedisJobStore) SetLastFireTime(jobName string, fireTime int64) error {
+	return rs.redisModule.SetHash(redisJobLastFireKey, jobName, fireTime)
+}
+
+func (rs *RedisJobStore) AddHistory(history *JobHistory) error {
+	key := redisJobHistoryPrefix + history.JobName
+	if err := rs.redisModule.LPushListJSON(key, history); err != nil {
+		return err
+	}
+
+	return rs.redisModule.LTrimList(key, 0, rs.historyNum-1)
+}
+
+func (rs *RedisJobStore) GetHistory(jobName string, limit int) ([]*JobHistory, error) {
+	valueList, err := rs.redisModule.LRangeList(redisJobHistoryPrefix+jobName, 0, limit-1)
+	if err != nil {
+		return nil, err
+	}
+
+	historyList := make([]*JobHistory, 0, len(valueList))
+	for _, value := range valueList {
+		history := &JobHistory{}
+		if err = json.Unmarshal([]byte(value), history); err != nil {
+			return nil, err
+		}
+		historyList = append(historyList, history)
+	}
+
+	return historyList, nil
+}
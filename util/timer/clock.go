@@ -0,0 +1,192 @@
+package timer
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock 时钟，定时器、cron、帧定时器与rpc超时均通过timer.Now获取当前时间
+type Clock interface {
+	Now() time.Time
+}
+
+type clockHolder struct {
+	clock Clock
+}
+
+var currentClock atomic.Pointer[clockHolder]
+
+// RealClock 系统时钟，默认使用
+type RealClock struct {
+}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// OffsetClock 在系统时间上增加偏移，用于测试服通过GM指令调整时间，如验证跨天重置
+type OffsetClock struct {
+	offset int64
+}
+
+func NewOffsetClock(offset time.Duration) *OffsetClock {
+	return &OffsetClock{offset: int64(offset)}
+}
+
+func (c *OffsetClock) Now() time.Time {
+	return time.Now().Add(c.GetOffset())
+}
+
+// SetOffset 设置偏移，向后调整时已到期的定时器会立即触发，向前调整时定时器延后触发
+func (c *OffsetClock) SetOffset(offset time.Duration) {
+	atomic.StoreInt64(&c.offset, int64(offset))
+}
+
+func (c *OffsetClock) GetOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.offset))
+}
+
+// ManualClock 手动推进的时钟，用于单元测试。时间只在Advance与Set时变化，
+// 到期的定时器按触发时间顺序依次投递，并等待其执行完成后再推进
+type ManualClock struct {
+	now           int64
+	advanceLocker sync.Mutex
+}
+
+// manualTimer 由ManualClock投递的定时器，执行完成后通知ManualClock
+type manualTimer struct {
+	ITimer
+	done chan struct{}
+}
+
+func (t *manualTimer) Do() {
+	defer close(t.done)
+	t.ITimer.Do()
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now.UnixNano()}
+}
+
+func (c *ManualClock) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.now))
+}
+
+// Advance 推进时间d，期间到期的定时器(包括循环定时器与cron的每一次触发)都会被执行。
+// 定时器在其所属服务的协程中执行，所以服务需要已经启动，并且不能在定时器回调中调用Advance
+func (c *ManualClock) Advance(d time.Duration) {
+	c.advanceLocker.Lock()
+	defer c.advanceLocker.Unlock()
+
+	c.advanceTo(c.Now().Add(d))
+}
+
+// Set 设置当前时间，设置为更早的时间时不触发定时器
+func (c *ManualClock) Set(now time.Time) {
+	c.advanceLocker.Lock()
+	defer c.advanceLocker.Unlock()
+
+	if now.After(c.Now()) {
+		c.advanceTo(now)
+	} else {
+		atomic.StoreInt64(&c.now, now.UnixNano())
+	}
+}
+
+func (c *ManualClock) advanceTo(target time.Time) {
+	for {
+		dueList := popDueTimers(target)
+		if len(dueList) == 0 {
+			break
+		}
+
+		if fireTime := dueList[0].GetFireTime(); fireTime.After(c.Now()) {
+			atomic.StoreInt64(&c.now, fireTime.UnixNano())
+		}
+
+		doneList := make([]chan struct{}, 0, len(dueList))
+		for _, t := range dueList {
+			//已取消的定时器所属服务可能已经停止，不等待其执行
+			if t.IsActive() == false {
+				t.AppendChannel(t)
+				continue
+			}
+
+			done := make(chan struct{})
+			doneList = append(doneList, done)
+			t.AppendChannel(&manualTimer{ITimer: t, done: done})
+		}
+
+		for _, done := range doneList {
+			<-done
+		}
+	}
+
+	atomic.StoreInt64(&c.now, target.UnixNano())
+}
+
+// popDueTimers 取出不晚于target的最早一批同一触发时间的定时器
+func popDueTimers(target time.Time) []ITimer {
+	timerQueueLock.Lock()
+	dueList := timerQueue.popEarliest(target)
+	timerQueueLock.Unlock()
+
+	for _, t := range dueList {
+		t.Open(false)
+	}
+
+	return dueList
+}
+
+// SetClock 替换时钟，需要在创建定时器之前调用
+func SetClock(clock Clock) {
+	currentClock.Store(&clockHolder{clock: clock})
+}
+
+func GetClock() Clock {
+	holder := currentClock.Load()
+	if holder == nil {
+		return RealClock{}
+	}
+
+	return holder.clock
+}
+
+// SetTimeOffset 设置GM时间偏移，未使用OffsetClock时替换为OffsetClock
+func SetTimeOffset(offset time.Duration) error {
+	switch clock := GetClock().(type) {
+	case *OffsetClock:
+		clock.SetOffset(offset)
+	case RealClock:
+		SetClock(NewOffsetClock(offset))
+	default:
+		return errors.New("time offset is not supported by the current clock")
+	}
+
+	return nil
+}
+
+// GetTimeOffset 获取GM时间偏移
+func GetTimeOffset() time.Duration {
+	if clock, ok := GetClock().(*OffsetClock); ok {
+		return clock.GetOffset()
+	}
+
+	return 0
+}
+
+func isManualClock() bool {
+	_, ok := GetClock().(*ManualClock)
+	return ok
+}
+
+func Now() time.Time {
+	holder := currentClock.Load()
+	if holder == nil {
+		return time.Now()
+	}
+
+	return holder.clock.Now()
+}
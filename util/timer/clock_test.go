@@ -0,0 +1,161 @@
+package timer
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+type fireRecord struct {
+	name string
+	now  time.Time
+}
+
+// runManualDispatcher 模拟服务协程执行投递到dispatcher的定时器
+func runManualDispatcher(t *testing.T, dispatcher *Dispatcher) {
+	closeSig := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-closeSig:
+				return
+			case timer := <-dispatcher.ChanTimer:
+				timer.Do()
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		close(closeSig)
+		<-exited
+	})
+}
+
+// replaceTimerQueue 直接替换定时器容器，丢弃其他测试遗留的定时器
+func replaceTimerQueue(queue iTimerQueue) {
+	timerQueueLock.Lock()
+	timerQueue = queue
+	timerQueueLock.Unlock()
+}
+
+func setManualClock(t *testing.T, clock *ManualClock, queue iTimerQueue) {
+	SetClock(clock)
+	replaceTimerQueue(queue)
+	t.Cleanup(func() {
+		replaceTimerQueue(&_TimerHeap{})
+		SetClock(RealClock{})
+	})
+}
+
+func TestManualClockAdvance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		newQueue func() iTimerQueue
+	}{
+		{"Heap", func() iTimerQueue {
+			h := &_TimerHeap{}
+			heap.Init(h)
+			return h
+		}},
+		{"TimingWheel", func() iTimerQueue {
+			return newTimingWheel(10*time.Millisecond, start)
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := NewManualClock(start)
+			setManualClock(t, clock, tc.newQueue())
+			dispatcher := NewDispatcher(100)
+			runManualDispatcher(t, dispatcher)
+
+			//只在定时器协程中访问
+			var recordList []fireRecord
+			record := func(name string) {
+				recordList = append(recordList, fireRecord{name: name, now: Now()})
+			}
+			onAddTimer := func(timer ITimer) {}
+
+			dispatcher.AfterFunc(5*time.Second, nil, func(*Timer) { record("after") }, nil, onAddTimer)
+			dispatcher.TickerFunc(2*time.Second, nil, func(*Ticker) { record("ticker") }, nil, onAddTimer)
+			cronExpr, err := NewCronExpr("*/10 * * * * *")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dispatcher.CronFunc(cronExpr, nil, func(*Cron) { record("cron") }, nil, onAddTimer)
+			cancelTimer := dispatcher.AfterFunc(3*time.Second, nil, func(*Timer) { record("cancel") }, nil, onAddTimer)
+			cancelTimer.Cancel()
+
+			clock.Advance(21 * time.Second)
+			if now := Now(); now.Equal(start.Add(21*time.Second)) == false {
+				t.Fatalf("now is %s after advance", now)
+			}
+
+			mapCount := map[string]int{}
+			var lastTime time.Time
+			for _, r := range recordList {
+				mapCount[r.name]++
+				if r.now.Before(lastTime) {
+					t.Fatalf("%s fires at %s,before the previous timer at %s", r.name, r.now, lastTime)
+				}
+				lastTime = r.now
+
+				//每次触发时时钟都推进到该定时器的触发时间
+				interval := map[string]time.Duration{"after": 5 * time.Second, "ticker": 2 * time.Second, "cron": 10 * time.Second}[r.name]
+				if interval == 0 || r.now.Sub(start)%interval != 0 {
+					t.Fatalf("%s fires at %s", r.name, r.now)
+				}
+			}
+
+			wantCount := map[string]int{"after": 1, "ticker": 10, "cron": 2}
+			for name, num := range wantCount {
+				if mapCount[name] != num {
+					t.Fatalf("%s fires %d times,expect %d,records:%v", name, mapCount[name], num, recordList)
+				}
+			}
+			if mapCount["cancel"] != 0 {
+				t.Fatal("cancelled timer fires")
+			}
+
+			//cron与循环定时器已重新注册，继续推进时按新的时间触发
+			recordList = recordList[:0]
+			clock.Advance(10 * time.Second)
+			mapCount = map[string]int{}
+			for _, r := range recordList {
+				mapCount[r.name]++
+			}
+			if mapCount["cron"] != 1 || mapCount["ticker"] != 5 || mapCount["after"] != 0 {
+				t.Fatalf("fire count after re-arm is error:%v", mapCount)
+			}
+		})
+	}
+}
+
+func TestManualClockSet(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	setManualClock(t, clock, &_TimerHeap{})
+	dispatcher := NewDispatcher(100)
+	runManualDispatcher(t, dispatcher)
+
+	var fireNum int
+	dispatcher.AfterFunc(time.Hour, nil, func(*Timer) { fireNum++ }, nil, nil)
+
+	//设置为更早的时间时不触发定时器
+	clock.Set(start.Add(-time.Hour))
+	if fireNum != 0 || Now().Equal(start.Add(-time.Hour)) == false {
+		t.Fatalf("set back,fire num:%d,now:%s", fireNum, Now())
+	}
+
+	clock.Set(start.Add(time.Hour))
+	if fireNum != 1 || Now().Equal(start.Add(time.Hour)) == false {
+		t.Fatalf("set forward,fire num:%d,now:%s", fireNum, Now())
+	}
+
+	if err := SetTimeOffset(time.Hour); err == nil {
+		t.Fatal("time offset should not be supported by the manual clock")
+	}
+}
@@ -14,9 +14,15 @@ import (
 // Seconds      | No         | 0-59           | * / , -
 // Minutes      | Yes        | 0-59           | * / , -
 // Hours        | Yes        | 0-23           | * / , -
-// Day of month | Yes        | 1-31           | * / , -
+// Day of month | Yes        | 1-31           | * / , - L W
 // Month        | Yes        | 1-12           | * / , -
-// Day of week  | Yes        | 0-6            | * / , -
+// Day of week  | Yes        | 0-6            | * / , - L #
+//
+// Day of month中L为当月最后一天，LW为当月最后一个工作日，15W为离15号最近的工作日(不跨月)；
+// Day of week中5L为当月最后一个星期五，1#2为当月第二个星期一。
+// 表达式前可以加时区，如"TZ=Asia/Shanghai 0 0 4 * * *"(也可以写作CRON_TZ=)，不加时区时使用服务器本地时间。
+// 支持预定义的表达式@yearly(@annually)、@monthly、@weekly、@daily(@midnight)、@hourly，
+// 以及@every 90s，按固定间隔触发，间隔最小为1秒
 type CronExpr struct {
 	sec   uint64
 	min   uint64
@@ -24,13 +30,104 @@ type CronExpr struct {
 	dom   uint64
 	month uint64
 	dow   uint64
+
+	domLast        bool     // L
+	domLastWeekday bool     // LW
+	domWeekday     uint64   // nW
+	dowLast        uint64   // nL
+	dowNth         [7]uint8 // n#m
+
+	loc   *time.Location //为nil时使用Next参数的时区
+	every time.Duration  //@every的间隔
+}
+
+const maxCronSearchYears = 8 //2月29日最多间隔8年
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
 }
 
 // goroutine safe
 func NewCronExpr(expr string) (cronExpr *CronExpr, err error) {
-	fields := strings.Fields(expr)
+	var loc *time.Location
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		idx := strings.IndexAny(spec, " \t")
+		if idx == -1 {
+			return nil, fmt.Errorf("invalid expr %v: missing fields after time zone", expr)
+		}
+
+		tz := spec[strings.IndexByte(spec, '=')+1 : idx]
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expr %v: %v", expr, err)
+		}
+		spec = strings.TrimSpace(spec[idx:])
+	}
+
+	cronExpr, err = parseCronSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expr %v: %v", expr, err)
+	}
+
+	cronExpr.loc = loc
+	return cronExpr, nil
+}
+
+// NewCronExprInLocation 按指定时区解析表达式，如按玩家所在地区的时间触发活动
+func NewCronExprInLocation(expr string, loc *time.Location) (*CronExpr, error) {
+	cronExpr, err := NewCronExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if loc != nil {
+		cronExpr.loc = loc
+	}
+
+	return cronExpr, nil
+}
+
+// Location 获取表达式的时区，没有指定时区时返回nil
+func (e *CronExpr) Location() *time.Location {
+	return e.loc
+}
+
+func parseCronSpec(spec string) (cronExpr *CronExpr, err error) {
+	if strings.HasPrefix(spec, "@every ") {
+		var every time.Duration
+		every, err = time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return
+		}
+
+		every = every.Truncate(time.Second)
+		if every < time.Second {
+			err = fmt.Errorf("every duration must be at least 1s")
+			return
+		}
+
+		return &CronExpr{every: every}, nil
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		macro, ok := cronMacros[spec]
+		if ok == false {
+			err = fmt.Errorf("unknown macro %v", spec)
+			return
+		}
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
 	if len(fields) != 5 && len(fields) != 6 {
-		err = fmt.Errorf("invalid expr %v: expected 5 or 6 fields, got %v", expr, len(fields))
+		err = fmt.Errorf("expected 5 or 6 fields, got %v", len(fields))
 		return
 	}
 
@@ -40,40 +137,91 @@ func NewCronExpr(expr string) (cronExpr *CronExpr, err error) {
 
 	cronExpr = new(CronExpr)
 	// Seconds
-	cronExpr.sec, err = parseCronField(fields[0], 0, 59)
-	if err != nil {
-		goto onError
+	if cronExpr.sec, err = parseCronField(fields[0], 0, 59); err != nil {
+		return
 	}
 	// Minutes
-	cronExpr.min, err = parseCronField(fields[1], 0, 59)
-	if err != nil {
-		goto onError
+	if cronExpr.min, err = parseCronField(fields[1], 0, 59); err != nil {
+		return
 	}
 	// Hours
-	cronExpr.hour, err = parseCronField(fields[2], 0, 23)
-	if err != nil {
-		goto onError
+	if cronExpr.hour, err = parseCronField(fields[2], 0, 23); err != nil {
+		return
 	}
 	// Day of month
-	cronExpr.dom, err = parseCronField(fields[3], 1, 31)
-	if err != nil {
-		goto onError
+	if err = cronExpr.parseDomField(fields[3]); err != nil {
+		return
 	}
 	// Month
-	cronExpr.month, err = parseCronField(fields[4], 1, 12)
-	if err != nil {
-		goto onError
+	if cronExpr.month, err = parseCronField(fields[4], 1, 12); err != nil {
+		return
 	}
 	// Day of week
-	cronExpr.dow, err = parseCronField(fields[5], 0, 6)
-	if err != nil {
-		goto onError
-	}
+	err = cronExpr.parseDowField(fields[5])
 	return
+}
 
-onError:
-	err = fmt.Errorf("invalid expr %v: %v", expr, err)
-	return
+// parseDomField 解析Day of month，L、LW与nW单独记录，其余按普通字段解析
+func (e *CronExpr) parseDomField(field string) error {
+	var normal []string
+	for _, item := range strings.Split(field, ",") {
+		switch {
+		case item == "L":
+			e.domLast = true
+		case item == "LW":
+			e.domLastWeekday = true
+		case strings.HasSuffix(item, "W"):
+			day, err := strconv.Atoi(strings.TrimSuffix(item, "W"))
+			if err != nil || day < 1 || day > 31 {
+				return fmt.Errorf("invalid weekday: %v", item)
+			}
+			e.domWeekday |= 1 << uint(day)
+		default:
+			normal = append(normal, item)
+		}
+	}
+
+	if len(normal) == 0 {
+		return nil
+	}
+
+	var err error
+	e.dom, err = parseCronField(strings.Join(normal, ","), 1, 31)
+	return err
+}
+
+// parseDowField 解析Day of week，nL与n#m单独记录，其余按普通字段解析
+func (e *CronExpr) parseDowField(field string) error {
+	var normal []string
+	for _, item := range strings.Split(field, ",") {
+		if strings.HasSuffix(item, "L") {
+			dow, err := strconv.Atoi(strings.TrimSuffix(item, "L"))
+			if err != nil || dow < 0 || dow > 6 {
+				return fmt.Errorf("invalid last day of week: %v", item)
+			}
+			e.dowLast |= 1 << uint(dow)
+		} else if dowAndNth := strings.Split(item, "#"); len(dowAndNth) == 2 {
+			dow, err := strconv.Atoi(dowAndNth[0])
+			if err != nil || dow < 0 || dow > 6 {
+				return fmt.Errorf("invalid nth day of week: %v", item)
+			}
+			nth, err := strconv.Atoi(dowAndNth[1])
+			if err != nil || nth < 1 || nth > 5 {
+				return fmt.Errorf("invalid nth day of week: %v", item)
+			}
+			e.dowNth[dow] |= 1 << uint(nth)
+		} else {
+			normal = append(normal, item)
+		}
+	}
+
+	if len(normal) == 0 {
+		return nil
+	}
+
+	var err error
+	e.dow, err = parseCronField(strings.Join(normal, ","), 0, 6)
+	return err
 }
 
 // 1. *
@@ -171,98 +319,168 @@ func parseCronField(field string, min int, max int) (cronField uint64, err error
 	return
 }
 
-func (e *CronExpr) matchDay(t time.Time) bool {
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nearestWeekday 离day最近的工作日，不跨月
+func nearestWeekday(year int, month time.Month, day int, lastDay int) int {
+	switch time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+		return day + 1
+	}
+
+	return day
+}
+
+func (e *CronExpr) matchDom(year int, month time.Month, day int, lastDay int) bool {
+	if 1<<uint(day)&e.dom != 0 {
+		return true
+	}
+
+	if e.domLast && day == lastDay {
+		return true
+	}
+
+	if e.domLastWeekday && day == nearestWeekday(year, month, lastDay, lastDay) {
+		return true
+	}
+
+	for n := 1; n <= lastDay && e.domWeekday != 0; n++ {
+		if 1<<uint(n)&e.domWeekday != 0 && nearestWeekday(year, month, n, lastDay) == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *CronExpr) matchDow(weekday time.Weekday, day int, lastDay int) bool {
+	if 1<<uint(weekday)&e.dow != 0 {
+		return true
+	}
+
+	if 1<<uint(weekday)&e.dowLast != 0 && day+7 > lastDay {
+		return true
+	}
+
+	return 1<<uint((day-1)/7+1)&e.dowNth[weekday] != 0
+}
+
+// matchDay day为按UTC表示的日期
+func (e *CronExpr) matchDay(day time.Time) bool {
+	year, month, d := day.Date()
+	lastDay := daysInMonth(year, month)
+
 	// day-of-month blank
 	if e.dom == 0xfffffffe {
-		return 1<<uint(t.Weekday())&e.dow != 0
+		return e.matchDow(day.Weekday(), d, lastDay)
 	}
 
 	// day-of-week blank
 	if e.dow == 0x7f {
-		return 1<<uint(t.Day())&e.dom != 0
+		return e.matchDom(year, month, d, lastDay)
 	}
 
-	return 1<<uint(t.Weekday())&e.dow != 0 ||
-		1<<uint(t.Day())&e.dom != 0
+	return e.matchDow(day.Weekday(), d, lastDay) || e.matchDom(year, month, d, lastDay)
 }
 
-// goroutine safe
-func (e *CronExpr) Next(t time.Time) time.Time {
-	// the upcoming second
-	t = t.Truncate(time.Second).Add(time.Second)
+// cronTime 计算loc中墙上时间对应的时刻。夏令时结束时重复的时间取第一次出现的时刻，
+// 夏令时开始时跳过的时间取时钟调整的时刻，保证每个墙上时间只触发一次且不会错过
+func cronTime(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	start, end := t.ZoneBounds()
+	if got.Equal(wall) == false {
+		if got.Before(wall) && end.IsZero() == false {
+			return end
+		} else if got.After(wall) && start.IsZero() == false {
+			return start
+		}
+		return t
+	}
 
-	year := t.Year()
-	initFlag := false
+	if start.IsZero() {
+		return t
+	}
 
-retry:
-	// Year
-	if t.Year() > year+1 {
-		return time.Time{}
+	_, offset := t.Zone()
+	_, prevOffset := start.Add(-time.Second).Zone()
+	if prev := t.Add(time.Duration(offset-prevOffset) * time.Second); prev.Before(start) {
+		return prev
 	}
 
-	// Month
-	for 1<<uint(t.Month())&e.month == 0 {
-		if !initFlag {
-			initFlag = true
-			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
-		}
+	return t
+}
 
-		t = t.AddDate(0, 1, 0)
-		if t.Month() == time.January {
-			goto retry
+// nextInDay 在day这一天中查找晚于t的触发时刻，lt为t在表达式时区中的时间。
+// 墙上时间到时刻的映射是单调的，同一天中早于lt的墙上时间不会晚于t，可以直接跳过
+func (e *CronExpr) nextInDay(day time.Time, t time.Time, lt time.Time, loc *time.Location) (time.Time, bool) {
+	sameDay := day.Year() == lt.Year() && day.YearDay() == lt.YearDay()
+	for hour := 0; hour < 24; hour++ {
+		if 1<<uint(hour)&e.hour == 0 || (sameDay && hour < lt.Hour()) {
+			continue
 		}
-	}
 
-	// Day
-	for !e.matchDay(t) {
-		if !initFlag {
-			initFlag = true
-			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-		}
+		sameHour := sameDay && hour == lt.Hour()
+		for minute := 0; minute < 60; minute++ {
+			if 1<<uint(minute)&e.min == 0 || (sameHour && minute < lt.Minute()) {
+				continue
+			}
+
+			sameMinute := sameHour && minute == lt.Minute()
+			for sec := 0; sec < 60; sec++ {
+				if 1<<uint(sec)&e.sec == 0 || (sameMinute && sec < lt.Second()) {
+					continue
+				}
 
-		t = t.AddDate(0, 0, 1)
-		if t.Day() == 1 {
-			goto retry
+				next := cronTime(day.Add(time.Duration(hour)*time.Hour+time.Duration(minute)*time.Minute+time.Duration(sec)*time.Second), loc)
+				if next.After(t) {
+					return next, true
+				}
+			}
 		}
 	}
 
-	// Hours
-	for 1<<uint(t.Hour())&e.hour == 0 {
-		if !initFlag {
-			initFlag = true
-			t = t.Truncate(time.Hour)
-		}
+	return time.Time{}, false
+}
 
-		t = t.Add(time.Hour)
-		if t.Hour() == 0 {
-			goto retry
-		}
+// Next 获取晚于t的下次触发时间，没有时返回零值。按表达式的时区计算，夏令时切换时不会重复或错过触发
+// goroutine safe
+func (e *CronExpr) Next(t time.Time) time.Time {
+	if e.every > 0 {
+		return t.Add(e.every - time.Duration(t.Nanosecond()))
 	}
 
-	// Minutes
-	for 1<<uint(t.Minute())&e.min == 0 {
-		if !initFlag {
-			initFlag = true
-			t = t.Truncate(time.Minute)
-		}
-
-		t = t.Add(time.Minute)
-		if t.Minute() == 0 {
-			goto retry
-		}
+	loc := e.loc
+	if loc == nil {
+		loc = t.Location()
 	}
 
-	// Seconds
-	for 1<<uint(t.Second())&e.sec == 0 {
-		if !initFlag {
-			initFlag = true
+	lt := t.In(loc)
+	day := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, time.UTC)
+	endDay := day.AddDate(maxCronSearchYears, 0, 0)
+	for day.Before(endDay) {
+		if 1<<uint(day.Month())&e.month == 0 {
+			day = time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
 		}
 
-		t = t.Add(time.Second)
-		if t.Second() == 0 {
-			goto retry
+		if e.matchDay(day) {
+			if next, ok := e.nextInDay(day, t, lt, loc); ok {
+				return next
+			}
 		}
+		day = day.AddDate(0, 0, 1)
 	}
 
-	return t
+	return time.Time{}
 }
@@ -0,0 +1,123 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+func parseTestTime(t *testing.T, value string) time.Time {
+	tm, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tm
+}
+
+func TestCronExprNext(t *testing.T) {
+	testCases := []struct {
+		name string
+		expr string
+		from string
+		want []string //依次触发的时间
+	}{
+		//夏令时开始时跳过的时间在时钟调整的时刻触发，夏令时结束时重复的时间只触发一次
+		{"NewYorkSpringForward", "TZ=America/New_York 0 30 2 * * *", "2024-03-09T03:00:00-05:00",
+			[]string{"2024-03-10T03:00:00-04:00", "2024-03-11T02:30:00-04:00"}},
+		{"NewYorkFallBack", "TZ=America/New_York 0 30 1 * * *", "2024-11-02T12:00:00-04:00",
+			[]string{"2024-11-03T01:30:00-04:00", "2024-11-04T01:30:00-05:00"}},
+		{"NewYorkHourlyFallBack", "TZ=America/New_York 0 0 * * * *", "2024-11-03T00:30:00-04:00",
+			[]string{"2024-11-03T01:00:00-04:00", "2024-11-03T02:00:00-05:00"}},
+		//Lord_Howe的夏令时只调整30分钟
+		{"LordHoweSpringForward", "TZ=Australia/Lord_Howe 0 15 2 * * *", "2024-10-05T12:00:00+10:30",
+			[]string{"2024-10-06T02:30:00+11:00", "2024-10-07T02:15:00+11:00"}},
+		{"LordHoweFallBack", "TZ=Australia/Lord_Howe 0 45 1 * * *", "2024-04-06T12:00:00+11:00",
+			[]string{"2024-04-07T01:45:00+11:00", "2024-04-08T01:45:00+10:30"}},
+		{"LeapDay", "0 0 0 29 2 *", "2024-03-01T00:00:00Z",
+			[]string{"2028-02-29T00:00:00Z", "2032-02-29T00:00:00Z"}},
+		{"LastDayOfMonth", "0 0 0 L * *", "2024-02-01T00:00:00Z",
+			[]string{"2024-02-29T00:00:00Z", "2024-03-31T00:00:00Z", "2024-04-30T00:00:00Z"}},
+		{"LastWeekday", "0 0 0 LW * *", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-28T00:00:00Z", "2024-07-31T00:00:00Z", "2024-08-30T00:00:00Z"}},
+		{"NearestWeekdayNotCrossMonth", "0 0 0 1W * *", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-03T00:00:00Z", "2024-07-01T00:00:00Z"}},
+		{"NearestWeekdayOnSaturday", "0 0 0 15W * *", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-14T00:00:00Z", "2024-07-15T00:00:00Z"}},
+		{"LastFriday", "0 0 0 * * 5L", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-28T00:00:00Z", "2024-07-26T00:00:00Z"}},
+		{"SecondMonday", "0 0 0 * * 1#2", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-10T00:00:00Z", "2024-07-08T00:00:00Z"}},
+		{"Monthly", "@monthly", "2024-01-15T00:00:00Z",
+			[]string{"2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"}},
+		{"Weekly", "@weekly", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-02T00:00:00Z", "2024-06-09T00:00:00Z"}},
+		{"Hourly", "@hourly", "2024-06-01T00:00:00Z",
+			[]string{"2024-06-01T01:00:00Z", "2024-06-01T02:00:00Z"}},
+		{"Every", "@every 90s", "2024-01-01T00:00:00.5Z",
+			[]string{"2024-01-01T00:01:30Z", "2024-01-01T00:03:00Z"}},
+		{"TimeZone", "TZ=Asia/Shanghai 0 0 4 * * *", "2024-01-01T00:00:00Z",
+			[]string{"2024-01-01T20:00:00Z", "2024-01-02T20:00:00Z"}},
+		{"CronTimeZone", "CRON_TZ=Asia/Shanghai 0 4 * * *", "2024-01-01T00:00:00Z",
+			[]string{"2024-01-01T20:00:00Z"}},
+		{"NoTimeZone", "0 0 4 * * *", "2024-01-01T00:00:00+08:00",
+			[]string{"2024-01-01T04:00:00+08:00", "2024-01-02T04:00:00+08:00"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cronExpr, err := NewCronExpr(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next := parseTestTime(t, tc.from)
+			for _, want := range tc.want {
+				next = cronExpr.Next(next)
+				if wantTime := parseTestTime(t, want); next.Equal(wantTime) == false {
+					t.Fatalf("next is %s,expect %s", next.Format(time.RFC3339), wantTime.Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
+
+func TestCronExprInvalid(t *testing.T) {
+	testCases := []string{
+		"TZ=Bad/Zone 0 0 * * *",
+		"TZ=UTC",
+		"@unknown",
+		"@every 500ms",
+		"0 0 0 32W * *",
+		"0 0 0 * * 7L",
+		"0 0 0 * * 1#6",
+		"0 0 * *",
+		"0 60 * * * *",
+	}
+
+	for _, expr := range testCases {
+		if _, err := NewCronExpr(expr); err == nil {
+			t.Fatalf("expr %s should be invalid", expr)
+		}
+	}
+}
+
+func TestCronExprInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//表达式中指定了时区时，以参数为准
+	cronExpr, err := NewCronExprInLocation("TZ=Asia/Shanghai 0 0 4 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cronExpr.Location() != loc {
+		t.Fatalf("location is %s,expect %s", cronExpr.Location(), loc)
+	}
+
+	next := cronExpr.Next(parseTestTime(t, "2024-01-01T00:00:00Z"))
+	if want := parseTestTime(t, "2024-01-01T04:00:00-05:00"); next.Equal(want) == false {
+		t.Fatalf("next is %s,expect %s", next.Format(time.RFC3339), want.Format(time.RFC3339))
+	}
+}
@@ -12,9 +12,9 @@ func SetupTimer(timer ITimer) ITimer{
 	}
 
 	timer.Open(true)
-	timerHeapLock.Lock() // 使用锁规避竞争条件
-	heap.Push(&timerHeap,timer)
-	timerHeapLock.Unlock()
+	timerQueueLock.Lock() // 使用锁规避竞争条件
+	timerQueue.push(timer)
+	timerQueueLock.Unlock()
 	return timer
 }
 
@@ -56,56 +56,105 @@ func (h *_TimerHeap) Pop() (ret interface{}) {
 	return
 }
 
+func (h *_TimerHeap) push(t ITimer) {
+	heap.Push(h, t)
+}
+
+func (h *_TimerHeap) popExpired(now time.Time, dueList []ITimer) []ITimer {
+	for h.Len() > 0 && h.timers[0].GetFireTime().After(now) == false {
+		dueList = append(dueList, heap.Pop(h).(ITimer))
+	}
+
+	return dueList
+}
+
+func (h *_TimerHeap) popEarliest(target time.Time) []ITimer {
+	if h.Len() == 0 || h.timers[0].GetFireTime().After(target) {
+		return nil
+	}
+
+	fireTime := h.timers[0].GetFireTime()
+	var dueList []ITimer
+	for h.Len() > 0 && h.timers[0].GetFireTime().After(fireTime) == false {
+		dueList = append(dueList, heap.Pop(h).(ITimer))
+	}
+
+	return dueList
+}
+
+func (h *_TimerHeap) popAll() []ITimer {
+	timers := h.timers
+	h.timers = nil
+	return timers
+}
+
+// iTimerQueue 全局定时器容器，默认使用最小堆，定时器数量很大时可以使用时间轮
+type iTimerQueue interface {
+	push(t ITimer)
+	popExpired(now time.Time, dueList []ITimer) []ITimer // 取出所有不晚于now的定时器，追加到dueList
+	popEarliest(target time.Time) []ITimer              // 取出不晚于target的最早一批同一触发时间的定时器
+	popAll() []ITimer
+}
+
 var (
-	timerHeap     _TimerHeap // 定时器heap对象
-	timerHeapLock sync.Mutex // 一个全局的锁
-	timeOffset    time.Duration
+	timerQueue     iTimerQueue = &_TimerHeap{} // 定时器容器
+	timerQueueLock sync.Mutex                  // 一个全局的锁
 )
 
+// StartTimer 使用最小堆启动定时器，每次添加与取出为O(logN)
 func StartTimer(minTimerInterval time.Duration,maxTimerNum int){
-	timerHeap.timers = make([]ITimer,0,maxTimerNum)
-	heap.Init(&timerHeap) // 初始化定时器heap
+	timerHeap := &_TimerHeap{timers: make([]ITimer,0,maxTimerNum)}
+	heap.Init(timerHeap) // 初始化定时器heap
+	setTimerQueue(timerHeap)
 
 	go  tickRoutine(minTimerInterval)
 }
 
+// StartTimingWheel 使用分层时间轮启动定时器，每次添加与取出为O(1)，适合同时存在大量定时器的场景。
+// 定时器按tickInterval的精度触发，不会早于设置的触发时间
+func StartTimingWheel(tickInterval time.Duration) {
+	setTimerQueue(newTimingWheel(tickInterval, Now()))
+
+	go tickRoutine(tickInterval)
+}
+
+// setTimerQueue 替换定时器容器，已经添加的定时器转移到新的容器中
+func setTimerQueue(queue iTimerQueue) {
+	timerQueueLock.Lock()
+	defer timerQueueLock.Unlock()
+
+	for _, t := range timerQueue.popAll() {
+		queue.push(t)
+	}
+	timerQueue = queue
+}
+
 func tickRoutine(minTimerInterval time.Duration){
-	var bContinue bool
+	var dueList []ITimer
 	for{
-		bContinue = tick()
-		if bContinue == false {
+		dueList = tick(dueList[:0])
+		if len(dueList) == 0 {
 			time.Sleep(minTimerInterval)
 		}
+		clear(dueList)
 	}
 }
 
-func tick() bool{
-	now := Now()
-	timerHeapLock.Lock()
-	if timerHeap.Len() <= 0 { // 没有任何定时器，立刻返回
-		timerHeapLock.Unlock()
-		return false
-	}
-	nextFireTime := timerHeap.timers[0].GetFireTime()
-	if nextFireTime.After(now) { // 没有到时间的定时器，返回
-		timerHeapLock.Unlock()
-		return false
+// tick 投递所有到期的定时器，返回本次投递的定时器
+func tick(dueList []ITimer) []ITimer{
+	if isManualClock() { // 手动时钟由Advance投递到期的定时器
+		return dueList
 	}
 
-	t := heap.Pop(&timerHeap).(ITimer)
-	timerHeapLock.Unlock()
-	t.Open(false)
-	t.AppendChannel(t)
-
-	return true
-}
+	now := Now()
+	timerQueueLock.Lock()
+	dueList = timerQueue.popExpired(now, dueList)
+	timerQueueLock.Unlock()
 
-func Now() time.Time{
-	if timeOffset == 0 {
-		return time.Now()
+	for _, t := range dueList {
+		t.Open(false)
+		t.AppendChannel(t)
 	}
 
-	return time.Now().Add(timeOffset)
+	return dueList
 }
-
-
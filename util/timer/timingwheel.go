@@ -0,0 +1,199 @@
+package timer
+
+import (
+	"time"
+)
+
+const (
+	wheelRootBits  = 8
+	wheelLevelBits = 6
+	wheelLevelNum  = 5
+	wheelRootMask  = 1<<wheelRootBits - 1
+	wheelLevelMask = 1<<wheelLevelBits - 1
+	wheelMaxDelta  = 1 << (wheelRootBits + (wheelLevelNum-1)*wheelLevelBits) //超出范围的定时器先放在最高层，下沉时重新计算
+)
+
+// timingWheel 分层时间轮，第0层256个槽，每槽一个刻度，之后每层64个槽，每槽为下一层一圈的跨度。
+// 第0层转完一圈时将上一层当前槽的定时器下沉到下层，只在tickRoutine与setTimerQueue中访问，由timerQueueLock保护
+type timingWheel struct {
+	tickInterval int64
+	startTime    int64 //第0个刻度的时间(UnixNano)
+	currentTick  int64 //下一个要处理的刻度
+
+	levels     [wheelLevelNum][][]ITimer
+	levelCount [wheelLevelNum]int
+	count      int
+}
+
+func newTimingWheel(tickInterval time.Duration, now time.Time) *timingWheel {
+	if tickInterval <= 0 {
+		tickInterval = time.Millisecond
+	}
+
+	w := &timingWheel{tickInterval: int64(tickInterval), startTime: now.UnixNano()}
+	w.levels[0] = make([][]ITimer, 1<<wheelRootBits)
+	for level := 1; level < wheelLevelNum; level++ {
+		w.levels[level] = make([][]ITimer, 1<<wheelLevelBits)
+	}
+
+	return w
+}
+
+// expireTick 计算触发刻度，向上取整，保证不会早于触发时间
+func (w *timingWheel) expireTick(t ITimer) int64 {
+	d := t.GetFireTime().UnixNano() - w.startTime
+	if d <= 0 {
+		return 0
+	}
+
+	return (d + w.tickInterval - 1) / w.tickInterval
+}
+
+func (w *timingWheel) slotIndex(level int, tick int64) int64 {
+	if level == 0 {
+		return tick & wheelRootMask
+	}
+
+	return (tick >> (wheelRootBits + (level-1)*wheelLevelBits)) & wheelLevelMask
+}
+
+func (w *timingWheel) place(t ITimer, expire int64) {
+	delta := expire - w.currentTick
+	if delta < 0 {
+		//已经到期，放到下一个要处理的刻度
+		delta = 0
+		expire = w.currentTick
+	} else if delta >= wheelMaxDelta {
+		delta = wheelMaxDelta - 1
+		expire = w.currentTick + delta
+	}
+
+	level := 0
+	for level < wheelLevelNum-1 && delta >= 1<<(wheelRootBits+level*wheelLevelBits) {
+		level++
+	}
+
+	idx := w.slotIndex(level, expire)
+	w.levels[level][idx] = append(w.levels[level][idx], t)
+	w.levelCount[level]++
+}
+
+func (w *timingWheel) push(t ITimer) {
+	w.place(t, w.expireTick(t))
+	w.count++
+}
+
+// cascade 第0层转完一圈，将上层当前槽的定时器下沉，上层也转完一圈时继续下沉更上一层
+func (w *timingWheel) cascade() {
+	for level := 1; level < wheelLevelNum; level++ {
+		idx := w.slotIndex(level, w.currentTick)
+		slot := w.levels[level][idx]
+		w.levels[level][idx] = nil
+		w.levelCount[level] -= len(slot)
+		for _, t := range slot {
+			w.place(t, w.expireTick(t))
+		}
+
+		if idx != 0 {
+			break
+		}
+	}
+}
+
+func (w *timingWheel) popExpired(now time.Time, dueList []ITimer) []ITimer {
+	target := (now.UnixNano() - w.startTime) / w.tickInterval
+	if target < w.currentTick-1 {
+		//时间被向前调整，以当前时间为起点重建时间轮
+		w.rebuild(now)
+		target = 0
+	}
+
+	for w.currentTick <= target {
+		if w.count == 0 {
+			w.currentTick = target + 1
+			break
+		}
+
+		idx := w.currentTick & wheelRootMask
+		if idx == 0 {
+			w.cascade()
+		}
+
+		//第0层为空时直接跳到下一圈
+		if w.levelCount[0] == 0 {
+			w.currentTick = min((w.currentTick|wheelRootMask)+1, target+1)
+			continue
+		}
+
+		slot := w.levels[0][idx]
+		dueList = append(dueList, slot...)
+		clear(slot)
+		w.levels[0][idx] = slot[:0]
+		w.levelCount[0] -= len(slot)
+		w.count -= len(slot)
+		w.currentTick++
+	}
+
+	return dueList
+}
+
+// popEarliest 遍历所有槽找出最早的一批定时器，只用于ManualClock
+func (w *timingWheel) popEarliest(target time.Time) []ITimer {
+	var fireTime time.Time
+	for level := range w.levels {
+		for _, slot := range w.levels[level] {
+			for _, t := range slot {
+				if fireTime.IsZero() || t.GetFireTime().Before(fireTime) {
+					fireTime = t.GetFireTime()
+				}
+			}
+		}
+	}
+
+	if fireTime.IsZero() || fireTime.After(target) {
+		return nil
+	}
+
+	var dueList []ITimer
+	for level := range w.levels {
+		for idx, slot := range w.levels[level] {
+			remain := slot[:0]
+			for _, t := range slot {
+				if t.GetFireTime().After(fireTime) {
+					remain = append(remain, t)
+				} else {
+					dueList = append(dueList, t)
+				}
+			}
+			clear(slot[len(remain):])
+			w.levels[level][idx] = remain
+			w.levelCount[level] -= len(slot) - len(remain)
+		}
+	}
+	w.count -= len(dueList)
+
+	return dueList
+}
+
+func (w *timingWheel) popAll() []ITimer {
+	timers := make([]ITimer, 0, w.count)
+	for level := range w.levels {
+		for idx, slot := range w.levels[level] {
+			timers = append(timers, slot...)
+			w.levels[level][idx] = nil
+		}
+		w.levelCount[level] = 0
+	}
+	w.count = 0
+
+	return timers
+}
+
+func (w *timingWheel) rebuild(now time.Time) {
+	timers := w.popAll()
+	w.startTime = now.UnixNano()
+	w.currentTick = 0
+	for _, t := range timers {
+		w.push(t)
+	}
+}
@@ -0,0 +1,116 @@
+package timer
+
+import (
+	"container/heap"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+const benchTimerNum = 1000000
+
+func newBenchTimers(now time.Time, num int, maxDelay time.Duration) []ITimer {
+	r := rand.New(rand.NewSource(1))
+	timers := make([]ITimer, num)
+	for i := range timers {
+		timers[i] = &Timer{fireTime: now.Add(time.Duration(r.Int63n(int64(maxDelay))))}
+	}
+
+	return timers
+}
+
+func newBenchHeap() iTimerQueue {
+	h := &_TimerHeap{timers: make([]ITimer, 0, benchTimerNum)}
+	heap.Init(h)
+	return h
+}
+
+func TestTimingWheelExpire(t *testing.T) {
+	now := time.Now()
+	timers := newBenchTimers(now, 100000, 48*time.Hour)
+	wheel := newTimingWheel(10*time.Millisecond, now)
+	for _, timer := range timers {
+		wheel.push(timer)
+	}
+
+	var dueNum int
+	var dueList []ITimer
+	for cur := now; dueNum < len(timers); cur = cur.Add(time.Second) {
+		dueList = wheel.popExpired(cur, dueList[:0])
+		for _, timer := range dueList {
+			fireTime := timer.GetFireTime()
+			if fireTime.After(cur) {
+				t.Fatalf("timer fires early,fireTime:%s,now:%s", fireTime, cur)
+			}
+			if cur.Sub(fireTime) > time.Second+10*time.Millisecond {
+				t.Fatalf("timer fires late,fireTime:%s,now:%s", fireTime, cur)
+			}
+		}
+		dueNum += len(dueList)
+	}
+
+	if wheel.count != 0 {
+		t.Fatalf("wheel is not empty,count:%d", wheel.count)
+	}
+}
+
+func TestTimingWheelClockBack(t *testing.T) {
+	now := time.Now()
+	wheel := newTimingWheel(10*time.Millisecond, now)
+	wheel.popExpired(now.Add(time.Hour), nil)
+
+	timer := &Timer{fireTime: now.Add(time.Minute)}
+	wheel.push(timer)
+	if dueList := wheel.popExpired(now.Add(59*time.Second), nil); len(dueList) != 0 {
+		t.Fatal("timer fires early after clock back")
+	}
+	if dueList := wheel.popExpired(now.Add(time.Minute), nil); len(dueList) != 1 {
+		t.Fatal("timer does not fire after clock back")
+	}
+}
+
+func benchmarkPush(b *testing.B, newQueue func(now time.Time) iTimerQueue) {
+	now := time.Now()
+	timers := newBenchTimers(now, benchTimerNum, time.Hour)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		queue := newQueue(now)
+		for _, timer := range timers {
+			queue.push(timer)
+		}
+	}
+}
+
+// benchmarkPushExpire 添加1M个一小时内触发的定时器，再按10ms推进时间取出所有定时器
+func benchmarkPushExpire(b *testing.B, newQueue func(now time.Time) iTimerQueue) {
+	now := time.Now()
+	timers := newBenchTimers(now, benchTimerNum, time.Hour)
+	dueList := make([]ITimer, 0, benchTimerNum)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		queue := newQueue(now)
+		for _, timer := range timers {
+			queue.push(timer)
+		}
+
+		for cur := now; cur.Before(now.Add(time.Hour + time.Second)); cur = cur.Add(10 * time.Millisecond) {
+			dueList = queue.popExpired(cur, dueList[:0])
+		}
+	}
+}
+
+func BenchmarkHeapPush1M(b *testing.B) {
+	benchmarkPush(b, func(time.Time) iTimerQueue { return newBenchHeap() })
+}
+
+func BenchmarkTimingWheelPush1M(b *testing.B) {
+	benchmarkPush(b, func(now time.Time) iTimerQueue { return newTimingWheel(10*time.Millisecond, now) })
+}
+
+func BenchmarkHeapPushExpire1M(b *testing.B) {
+	benchmarkPushExpire(b, func(time.Time) iTimerQueue { return newBenchHeap() })
+}
+
+func BenchmarkTimingWheelPushExpire1M(b *testing.B) {
+	benchmarkPushExpire(b, func(now time.Time) iTimerQueue { return newTimingWheel(10*time.Millisecond, now) })
+}